@@ -0,0 +1,292 @@
+package telekit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// AdminRights lists administrator rights a command can require.
+// Unset fields are not required.
+type AdminRights struct {
+	ChangeInfo     bool
+	PostMessages   bool
+	EditMessages   bool
+	DeleteMessages bool
+	BanUsers       bool
+	InviteUsers    bool
+	PinMessages    bool
+	ManageTopics   bool
+	AddAdmins      bool
+	ManageCall     bool
+}
+
+// allowedBy reports whether granted includes every right set in r.
+func (r AdminRights) allowedBy(granted tg.ChatAdminRights) bool {
+	checks := []struct{ need, have bool }{
+		{r.ChangeInfo, granted.ChangeInfo},
+		{r.PostMessages, granted.PostMessages},
+		{r.EditMessages, granted.EditMessages},
+		{r.DeleteMessages, granted.DeleteMessages},
+		{r.BanUsers, granted.BanUsers},
+		{r.InviteUsers, granted.InviteUsers},
+		{r.PinMessages, granted.PinMessages},
+		{r.ManageTopics, granted.ManageTopics},
+		{r.AddAdmins, granted.AddAdmins},
+		{r.ManageCall, granted.ManageCall},
+	}
+	for _, c := range checks {
+		if c.need && !c.have {
+			return false
+		}
+	}
+	return true
+}
+
+// chatAdminRights returns r as Telegram admin rights.
+func (r AdminRights) chatAdminRights() tg.ChatAdminRights {
+	return tg.ChatAdminRights{
+		ChangeInfo:     r.ChangeInfo,
+		PostMessages:   r.PostMessages,
+		EditMessages:   r.EditMessages,
+		DeleteMessages: r.DeleteMessages,
+		BanUsers:       r.BanUsers,
+		InviteUsers:    r.InviteUsers,
+		PinMessages:    r.PinMessages,
+		ManageTopics:   r.ManageTopics,
+		AddAdmins:      r.AddAdmins,
+		ManageCall:     r.ManageCall,
+	}
+}
+
+// allRights is granted to chat creators, basic group admins and anonymous admins.
+var allRights = tg.ChatAdminRights{
+	ChangeInfo:     true,
+	PostMessages:   true,
+	EditMessages:   true,
+	DeleteMessages: true,
+	BanUsers:       true,
+	InviteUsers:    true,
+	PinMessages:    true,
+	ManageTopics:   true,
+	AddAdmins:      true,
+	ManageCall:     true,
+}
+
+// adminStatus is the administrator status of a user in a chat.
+type adminStatus struct {
+	admin   bool
+	rights  tg.ChatAdminRights
+	expires time.Time
+}
+
+type adminKey struct {
+	chatID int64
+	userID int64
+}
+
+// adminCache keeps participant lookups for Config.AdminCacheTTL.
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[adminKey]adminStatus
+	sets    int
+}
+
+func newAdminCache() *adminCache {
+	return &adminCache{
+		entries: make(map[adminKey]adminStatus),
+	}
+}
+
+func (c *adminCache) get(key adminKey, now time.Time) (adminStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.entries[key]
+	if !ok || now.After(status.expires) {
+		delete(c.entries, key)
+		return adminStatus{}, false
+	}
+	return status, true
+}
+
+func (c *adminCache) set(key adminKey, status adminStatus, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sets++
+	if c.sets%pruneEvery == 0 {
+		c.prune(now)
+	}
+	c.entries[key] = status
+}
+
+// prune removes expired entries of users who did not come back. The caller
+// must hold c.mu.
+func (c *adminCache) prune(now time.Time) {
+	for key, status := range c.entries {
+		if now.After(status.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// IsAdmin reports whether the sender is an administrator of the current
+// group or channel. Anonymous admins posting as the group and unsigned
+// posts in broadcast channels count as admins. It is always false in
+// private chats.
+func (c *Context) IsAdmin() bool {
+	status, err := c.adminStatus()
+	if err != nil {
+		c.bot.config.Logger.Warn("failed to check admin rights", "error", err)
+		return false
+	}
+	return status.admin
+}
+
+// HasAdminRights reports whether the sender is an administrator of the
+// current group or channel with all rights set in rights.
+func (c *Context) HasAdminRights(rights AdminRights) bool {
+	status, err := c.adminStatus()
+	if err != nil {
+		c.bot.config.Logger.Warn("failed to check admin rights", "error", err)
+		return false
+	}
+	return status.admin && rights.allowedBy(status.rights)
+}
+
+// adminStatus returns the cached or freshly fetched admin status of the sender.
+func (c *Context) adminStatus() (adminStatus, error) {
+	if c.message == nil || c.IsPrivate() {
+		return adminStatus{}, nil
+	}
+
+	chatID := c.ChatID()
+	if from, ok := c.message.FromID.(*tg.PeerChannel); ok && from.ChannelID == chatID {
+		return adminStatus{admin: true, rights: allRights}, nil
+	}
+	// only admins post in broadcast channels; unsigned posts have no sender
+	if c.message.Post && c.message.FromID == nil {
+		return adminStatus{admin: true, rights: allRights}, nil
+	}
+	userID := c.SenderID()
+	if userID == 0 {
+		return adminStatus{}, nil
+	}
+
+	key := adminKey{chatID: chatID, userID: userID}
+	now := time.Now()
+	if status, ok := c.bot.admins.get(key, now); ok {
+		return status, nil
+	}
+	if c.bot.api == nil {
+		return adminStatus{}, ErrBotNotRunning
+	}
+
+	var (
+		status adminStatus
+		err    error
+	)
+	if c.IsGroup() {
+		status, err = c.bot.fetchChatAdmin(c, chatID, userID)
+	} else {
+		channel := &tg.InputChannel{ChannelID: chatID}
+		if ch, ok := c.entities.Channels[chatID]; ok {
+			channel.AccessHash = ch.AccessHash
+		}
+		status, err = c.bot.fetchChannelAdmin(c, channel, c.senderInputPeer())
+	}
+	if err != nil {
+		return adminStatus{}, err
+	}
+
+	status.expires = now.Add(c.bot.config.AdminCacheTTL)
+	c.bot.admins.set(key, status, now)
+	return status, nil
+}
+
+// senderInputPeer returns the sender as an input peer, using the access
+// hash from the update's entities.
+func (c *Context) senderInputPeer() tg.InputPeerClass {
+	userID := c.SenderID()
+	if u, ok := c.entities.Users[userID]; ok {
+		return &tg.InputPeerUser{UserID: userID, AccessHash: u.AccessHash}
+	}
+	return &tg.InputPeerUser{UserID: userID}
+}
+
+func (b *Bot) fetchChannelAdmin(ctx context.Context, channel *tg.InputChannel, user tg.InputPeerClass) (adminStatus, error) {
+	res, err := b.api.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+		Channel:     channel,
+		Participant: user,
+	})
+	if tg.IsUserNotParticipant(err) {
+		return adminStatus{}, nil
+	}
+	if err != nil {
+		return adminStatus{}, fmt.Errorf("failed to get participant: %w", err)
+	}
+
+	switch p := res.Participant.(type) {
+	case *tg.ChannelParticipantCreator:
+		return adminStatus{admin: true, rights: allRights}, nil
+	case *tg.ChannelParticipantAdmin:
+		return adminStatus{admin: true, rights: p.AdminRights}, nil
+	}
+	return adminStatus{}, nil
+}
+
+// fetchChatAdmin checks a basic group, where admins have all rights.
+func (b *Bot) fetchChatAdmin(ctx context.Context, chatID, userID int64) (adminStatus, error) {
+	full, err := b.api.MessagesGetFullChat(ctx, chatID)
+	if err != nil {
+		return adminStatus{}, fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	chatFull, ok := full.FullChat.(*tg.ChatFull)
+	if !ok {
+		return adminStatus{}, nil
+	}
+	participants, ok := chatFull.Participants.(*tg.ChatParticipants)
+	if !ok {
+		return adminStatus{}, nil
+	}
+	for _, p := range participants.Participants {
+		switch p := p.(type) {
+		case *tg.ChatParticipantCreator:
+			if p.UserID == userID {
+				return adminStatus{admin: true, rights: allRights}, nil
+			}
+		case *tg.ChatParticipantAdmin:
+			if p.UserID == userID {
+				return adminStatus{admin: true, rights: allRights}, nil
+			}
+		}
+	}
+	return adminStatus{}, nil
+}
+
+// adminAllowed reports whether the sender satisfies the admin requirements of h.
+func adminAllowed(ctx *Context, h commandHandler) bool {
+	switch {
+	case h.requireRights != nil:
+		return ctx.HasAdminRights(*h.requireRights)
+	case h.requireAdmin:
+		return ctx.IsAdmin()
+	}
+	return true
+}
+
+// replyAdminRequired tells the sender the command needs admin rights.
+func (b *Bot) replyAdminRequired(ctx *Context, h commandHandler) {
+	b.config.Logger.Debug("command requires admin rights",
+		"command", h.path,
+		"sender_id", ctx.SenderID(),
+		"chat_id", ctx.ChatID())
+
+	m := b.messagesFor(ctx.LangCode())
+	b.sendErrorReply(ctx, renderMessage(m.AdminRequired, map[string]string{"Command": h.path}))
+}
@@ -0,0 +1,95 @@
+package telekit
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestAdminRightsAllowedBy(t *testing.T) {
+	granted := tg.ChatAdminRights{BanUsers: true, DeleteMessages: true}
+
+	tests := []struct {
+		name   string
+		rights AdminRights
+		want   bool
+	}{
+		{"none required", AdminRights{}, true},
+		{"granted", AdminRights{BanUsers: true}, true},
+		{"all granted", AdminRights{BanUsers: true, DeleteMessages: true}, true},
+		{"missing", AdminRights{BanUsers: true, PinMessages: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rights.allowedBy(granted); got != tt.want {
+				t.Errorf("allowedBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminAllowed(t *testing.T) {
+	b := &Bot{
+		config: Config{AdminCacheTTL: time.Minute, Logger: slog.Default()},
+		admins: newAdminCache(),
+	}
+	b.admins.set(adminKey{chatID: 5, userID: 1}, adminStatus{
+		admin:   true,
+		rights:  tg.ChatAdminRights{DeleteMessages: true},
+		expires: time.Now().Add(time.Minute),
+	}, time.Now())
+	b.admins.set(adminKey{chatID: 5, userID: 2}, adminStatus{expires: time.Now().Add(time.Minute)}, time.Now())
+
+	newCtx := func(peer, from tg.PeerClass) *Context {
+		return &Context{
+			Context: context.Background(),
+			bot:     b,
+			message: &tg.Message{PeerID: peer, FromID: from},
+		}
+	}
+	admin := commandHandler{requireAdmin: true}
+	post := newCtx(&tg.PeerChannel{ChannelID: 5}, nil)
+	post.message.Post = true
+	banner := commandHandler{requireRights: &AdminRights{BanUsers: true}}
+
+	tests := []struct {
+		name string
+		ctx  *Context
+		h    commandHandler
+		want bool
+	}{
+		{"no requirement", newCtx(&tg.PeerUser{UserID: 2}, nil), commandHandler{}, true},
+		{"private chat", newCtx(&tg.PeerUser{UserID: 1}, nil), admin, false},
+		{"cached admin", newCtx(&tg.PeerChannel{ChannelID: 5}, &tg.PeerUser{UserID: 1}), admin, true},
+		{"cached member", newCtx(&tg.PeerChannel{ChannelID: 5}, &tg.PeerUser{UserID: 2}), admin, false},
+		{"missing right", newCtx(&tg.PeerChannel{ChannelID: 5}, &tg.PeerUser{UserID: 1}), banner, false},
+		{"anonymous admin", newCtx(&tg.PeerChannel{ChannelID: 5}, &tg.PeerChannel{ChannelID: 5}), banner, true},
+		{"channel post", post, banner, true},
+		{"no sender", newCtx(&tg.PeerChannel{ChannelID: 5}, nil), admin, false},
+		{"not running", newCtx(&tg.PeerChannel{ChannelID: 5}, &tg.PeerUser{UserID: 3}), admin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adminAllowed(tt.ctx, tt.h); got != tt.want {
+				t.Errorf("adminAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminCachePrune(t *testing.T) {
+	c := newAdminCache()
+	now := time.Unix(1700000000, 0)
+	for i := range pruneEvery - 1 {
+		c.set(adminKey{chatID: 1, userID: int64(i)}, adminStatus{expires: now.Add(time.Minute)}, now)
+	}
+
+	later := now.Add(2 * time.Minute)
+	c.set(adminKey{chatID: 2, userID: 1}, adminStatus{expires: later.Add(time.Minute)}, later)
+	if len(c.entries) != 1 {
+		t.Errorf("cache keeps %d entries after pruning, want 1", len(c.entries))
+	}
+}
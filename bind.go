@@ -0,0 +1,440 @@
+package telekit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParamsOf derives a parameter schema from the struct tags of v,
+// which must be a struct or a pointer to one. The same schema is used by
+// Bind and CommandT:
+//
+//	type Args struct {
+//	    Limit int           `param:"limit,required,min=1,max=100" desc:"Items per page"`
+//	    Mode  string        `param:"mode,enum=fast|slow,default=fast"`
+//	    Name  string        `param:"name,maxlen=32" pattern:"^[a-z_]+$"`
+//	    Tags  []string      `param:"tags,maxlen=5"`
+//	    Wait  time.Duration `param:"wait,default=30s"`
+//	    Group telekit.Peer  `param:"group,type=chat"`
+//	    Skip  string        `param:"-"`
+//	}
+//
+// The param tag holds the parameter name followed by options:
+// required, default=V, enum=A|B|C, min=N, max=N, minlen=N, maxlen=N and
+// type=T to override the inferred ParamType (e.g. type=chat for Peer).
+// List defaults separate items with |. The name defaults to the lowercased
+// field name. Descriptions and patterns use their own desc and pattern tags
+// because they may contain commas.
+//
+// Supported field types are string, bool, all integer and float kinds,
+// time.Duration, time.Time, url.URL, *url.URL, Peer and slices of these.
+func ParamsOf(v any) (Params, error) {
+	info, err := bindInfoOf(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return info.params, nil
+}
+
+// Bind copies the current command parameters into the struct pointed to by dst.
+// Values of commands registered without a schema are converted and validated
+// using the schema derived from dst's struct tags.
+func Bind(ctx *Context, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("telekit: Bind requires a non-nil pointer to a struct")
+	}
+	info, err := bindInfoOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	params := ctx.Params()
+	if needsConversion(params, info) {
+		if params, err = convertForBind(ctx, params, info); err != nil {
+			return err
+		}
+	}
+
+	target := rv.Elem()
+	for _, f := range info.fields {
+		value, ok := params[f.name]
+		if !ok {
+			continue
+		}
+		if err := assignValue(target.FieldByIndex(f.index), value); err != nil {
+			return fmt.Errorf("telekit: bind parameter %q: %w", f.name, err)
+		}
+	}
+
+	if v, ok := dst.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ValidationErrors{{Kind: ValidationCommand, Err: err, Msg: err.Error()}}
+		}
+	}
+	return nil
+}
+
+// CommandT registers a command whose parameters are described by the struct
+// tags of Args and bound into a new Args value for each invocation.
+// If *Args has a Validate() error method, it runs once per invocation after
+// binding. Binding and validation errors are replied to like other
+// parameter errors. It panics if Args has invalid tags or def.Params is
+// set, since the parameters come from Args.
+func CommandT[Args any](b *Bot, def CommandDef, fn func(ctx *Context, args Args) error) {
+	CommandTWithFilter(b, def, Filter{Incoming: true}, fn)
+}
+
+// CommandTWithFilter is CommandT with a custom filter.
+func CommandTWithFilter[Args any](b *Bot, def CommandDef, filter Filter, fn func(ctx *Context, args Args) error) {
+	if len(def.Params) > 0 {
+		panic(fmt.Errorf("%w: command %q: Params must be empty, parameters come from the Args struct tags",
+			ErrInvalidParamSchema, def.Name))
+	}
+	var zero Args
+	params, err := ParamsOf(&zero)
+	if err != nil {
+		panic(err)
+	}
+	def.Params = params
+
+	b.CommandWithFilter(def, filter, func(ctx *Context) error {
+		var args Args
+		if err := Bind(ctx, &args); err != nil {
+			return &bindError{err: err}
+		}
+		return fn(ctx, args)
+	})
+}
+
+// bindError marks a Bind failure of a CommandT handler, which runCommand
+// replies to like a parameter error.
+type bindError struct {
+	err error
+}
+
+func (e *bindError) Error() string { return e.err.Error() }
+func (e *bindError) Unwrap() error { return e.err }
+
+type boundField struct {
+	name  string
+	index []int
+}
+
+type bindInfo struct {
+	params Params
+	fields []boundField
+}
+
+var bindInfoCache sync.Map // reflect.Type -> *bindInfo
+
+func bindInfoOf(t reflect.Type) (*bindInfo, error) {
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("telekit: parameters must be a struct, got %v", t)
+	}
+	if info, ok := bindInfoCache.Load(t); ok {
+		return info.(*bindInfo), nil
+	}
+
+	info := &bindInfo{params: make(Params)}
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get("param")
+		if tag == "-" {
+			continue
+		}
+
+		name, schema, err := schemaFromField(sf, tag)
+		if err != nil {
+			return nil, fmt.Errorf("telekit: field %s.%s: %w", t.Name(), sf.Name, err)
+		}
+		if _, dup := info.params[name]; dup {
+			return nil, fmt.Errorf("telekit: field %s.%s: duplicate parameter %q", t.Name(), sf.Name, name)
+		}
+		info.params[name] = schema
+		info.fields = append(info.fields, boundField{name: name, index: sf.Index})
+	}
+	if err := checkSchema(info.params); err != nil {
+		return nil, fmt.Errorf("telekit: %v: %w", t, err)
+	}
+
+	bindInfoCache.Store(t, info)
+	return info, nil
+}
+
+func schemaFromField(sf reflect.StructField, tag string) (string, ParamSchema, error) {
+	parts := strings.Split(tag, ",")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+
+	s := ParamSchema{
+		Description: sf.Tag.Get("desc"),
+		Pattern:     sf.Tag.Get("pattern"),
+	}
+
+	typ, elem, ok := paramTypeOf(sf.Type)
+	if !ok {
+		return "", s, fmt.Errorf("unsupported type %v", sf.Type)
+	}
+	s.Type, s.Elem = typ, elem
+
+	var defaultRaw, minRaw, maxRaw string
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		var err error
+		switch key {
+		case "":
+		case "required":
+			s.Required = true
+		case "default":
+			defaultRaw = value
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+			if s.Type == TypeString {
+				s.Type = TypeEnum
+			} else if s.Elem == TypeString {
+				s.Elem = TypeEnum
+			}
+		case "min":
+			minRaw = value
+		case "max":
+			maxRaw = value
+		case "minlen":
+			s.MinLen, err = strconv.Atoi(value)
+		case "maxlen":
+			s.MaxLen, err = strconv.Atoi(value)
+		case "type":
+			if s.Type == TypeList {
+				s.Elem = ParamType(value)
+			} else {
+				s.Type = ParamType(value)
+			}
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return "", s, fmt.Errorf("option %q: %w", key, err)
+		}
+	}
+
+	scalar := s.Type
+	if scalar == TypeList {
+		scalar = s.Elem
+	}
+	if minRaw != "" {
+		v, perr := convertValue(scalar, nil, minRaw)
+		if perr != nil {
+			return "", s, fmt.Errorf("min %q %s", minRaw, perr.Msg)
+		}
+		s.Min = v
+	}
+	if maxRaw != "" {
+		v, perr := convertValue(scalar, nil, maxRaw)
+		if perr != nil {
+			return "", s, fmt.Errorf("max %q %s", maxRaw, perr.Msg)
+		}
+		s.Max = v
+	}
+	if defaultRaw != "" {
+		if s.Type == TypeList {
+			defaultRaw = strings.ReplaceAll(defaultRaw, "|", ",")
+		}
+		v, perr := convertParam(s, defaultRaw)
+		if perr != nil {
+			return "", s, fmt.Errorf("default %q %s", defaultRaw, perr.Msg)
+		}
+		s.Default = v
+	}
+
+	return name, s, nil
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	timeType     = reflect.TypeFor[time.Time]()
+	urlType      = reflect.TypeFor[url.URL]()
+	peerType     = reflect.TypeFor[Peer]()
+)
+
+// paramTypeOf maps a Go type to a ParamType (and element type for slices).
+func paramTypeOf(t reflect.Type) (ParamType, ParamType, bool) {
+	if t.Kind() == reflect.Slice {
+		elem, _, ok := paramTypeOf(t.Elem())
+		if !ok || elem == TypeList {
+			return "", "", false
+		}
+		return TypeList, elem, true
+	}
+
+	switch t {
+	case durationType:
+		return TypeDuration, "", true
+	case timeType:
+		return TypeTime, "", true
+	case urlType, reflect.PointerTo(urlType):
+		return TypeURL, "", true
+	case peerType:
+		return TypeUser, "", true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString, "", true
+	case reflect.Bool:
+		return TypeBool, "", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt, "", true
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat, "", true
+	}
+	return "", "", false
+}
+
+// needsConversion reports whether params still hold raw strings for
+// non-string fields, i.e. the command was registered without this schema.
+func needsConversion(params ParsedParams, info *bindInfo) bool {
+	for _, f := range info.fields {
+		if _, ok := params[f.name].(string); !ok {
+			continue
+		}
+		s := info.params[f.name]
+		if s.Type != TypeString || s.MinLen > 0 || s.MaxLen > 0 || s.Pattern != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func convertForBind(ctx *Context, params ParsedParams, info *bindInfo) (ParsedParams, error) {
+	converted := make(ParsedParams, len(params))
+	for name, v := range params {
+		converted[name] = v
+	}
+
+	raw := make(map[string]string)
+	var errs ValidationErrors
+	for _, f := range info.fields {
+		s := info.params[f.name]
+		str, ok := params[f.name].(string)
+		if !ok {
+			if _, present := params[f.name]; !present && s.Required {
+				errs = append(errs, &ParamError{
+					Kind:  ValidationRequired,
+					Param: f.name,
+					Msg:   fmt.Sprintf("parameter %q is required", f.name),
+				})
+			}
+			continue
+		}
+
+		value, perr := convertParam(s, str)
+		if perr != nil {
+			perr.Param = f.name
+			perr.Value = str
+			perr.Msg = fmt.Sprintf("parameter %q %s", f.name, perr.Msg)
+			errs = append(errs, perr)
+			continue
+		}
+		converted[f.name] = value
+		raw[f.name] = str
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if ctx.bot != nil {
+		if err := ctx.bot.resolvePeerParams(ctx, converted); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateParams(converted, raw, info.params, nil); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
+
+// assignValue stores a parsed parameter value into a struct field.
+func assignValue(field reflect.Value, value any) error {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return nil
+	}
+	ft := field.Type()
+
+	if rv.Type().AssignableTo(ft) {
+		field.Set(rv)
+		return nil
+	}
+
+	switch {
+	case ft == urlType && rv.Type() == reflect.PointerTo(urlType):
+		if !rv.IsNil() {
+			field.Set(rv.Elem())
+		}
+		return nil
+
+	case ft.Kind() == reflect.Slice && rv.Kind() == reflect.Slice:
+		out := reflect.MakeSlice(ft, rv.Len(), rv.Len())
+		for i := range rv.Len() {
+			if err := assignValue(out.Index(i), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		field.Set(out)
+		return nil
+	}
+
+	switch ft.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt64(value)
+		if !ok || field.OverflowInt(n) {
+			return fmt.Errorf("value %v does not fit %v", value, ft)
+		}
+		field.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toInt64(value)
+		if !ok || n < 0 || field.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %v does not fit %v", value, ft)
+		}
+		field.SetUint(uint64(n))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("cannot assign %T to %v", value, ft)
+		}
+		field.SetFloat(f)
+		return nil
+	}
+
+	return fmt.Errorf("cannot assign %T to %v", value, ft)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	}
+	return 0, false
+}
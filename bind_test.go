@@ -0,0 +1,227 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+type bindArgs struct {
+	Limit   int           `param:"limit,required,min=1,max=100" desc:"Items per page"`
+	Mode    string        `param:"mode,enum=fast|slow,default=fast"`
+	Name    string        `param:"name,maxlen=8" pattern:"^[a-z_]+$"`
+	Tags    []string      `param:"tags,default=a|b"`
+	IDs     []int32       `param:"ids"`
+	Wait    time.Duration `param:"wait,default=30s,max=1h"`
+	Ratio   float32       `param:"ratio"`
+	Link    *url.URL      `param:"link"`
+	Group   Peer          `param:"group,type=chat"`
+	Verbose bool
+	Ignored string `param:"-"`
+}
+
+func TestParamsOf(t *testing.T) {
+	params, err := ParamsOf(bindArgs{})
+	if err != nil {
+		t.Fatalf("ParamsOf() error = %v", err)
+	}
+
+	limit := params["limit"]
+	if limit.Type != TypeInt || !limit.Required || limit.Min != int64(1) || limit.Max != int64(100) {
+		t.Errorf("limit schema = %+v", limit)
+	}
+	if limit.Description != "Items per page" {
+		t.Errorf("limit description = %q", limit.Description)
+	}
+	if mode := params["mode"]; mode.Type != TypeEnum || !slices.Equal(mode.Enum, []string{"fast", "slow"}) || mode.Default != "fast" {
+		t.Errorf("mode schema = %+v", mode)
+	}
+	if name := params["name"]; name.MaxLen != 8 || name.Pattern != "^[a-z_]+$" {
+		t.Errorf("name schema = %+v", name)
+	}
+	if tags := params["tags"]; tags.Type != TypeList || tags.Elem != TypeString || !slices.Equal(tags.Default.([]string), []string{"a", "b"}) {
+		t.Errorf("tags schema = %+v", tags)
+	}
+	if ids := params["ids"]; ids.Type != TypeList || ids.Elem != TypeInt {
+		t.Errorf("ids schema = %+v", ids)
+	}
+	if wait := params["wait"]; wait.Type != TypeDuration || wait.Default != 30*time.Second || wait.Max != time.Hour {
+		t.Errorf("wait schema = %+v", wait)
+	}
+	if params["link"].Type != TypeURL || params["group"].Type != TypeChat || params["verbose"].Type != TypeBool {
+		t.Errorf("link/group/verbose = %v/%v/%v", params["link"].Type, params["group"].Type, params["verbose"].Type)
+	}
+	if _, ok := params["ignored"]; ok {
+		t.Error("field tagged with - should be skipped")
+	}
+}
+
+func TestParamsOfErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not a struct", 42},
+		{"unsupported type", struct {
+			M map[string]string
+		}{}},
+		{"unknown option", struct {
+			A int `param:"a,bogus"`
+		}{}},
+		{"bad default", struct {
+			A int `param:"a,default=x"`
+		}{}},
+		{"duplicate name", struct {
+			A int `param:"x"`
+			B int `param:"x"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParamsOf(tt.v); err == nil {
+				t.Error("ParamsOf() expected error")
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	params, _ := ParamsOf(bindArgs{})
+	cmd, err := parseCommand(`/cmd limit=10 name=abc ids=1,2 ratio=0.5 link=https://example.com --verbose`, params)
+	if err != nil {
+		t.Fatalf("parseCommand() error = %v", err)
+	}
+
+	var args bindArgs
+	if err := Bind(&Context{params: cmd.params}, &args); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	if args.Limit != 10 || args.Mode != "fast" || args.Name != "abc" || !args.Verbose {
+		t.Errorf("args = %+v", args)
+	}
+	if !slices.Equal(args.IDs, []int32{1, 2}) || !slices.Equal(args.Tags, []string{"a", "b"}) {
+		t.Errorf("ids = %v, tags = %v", args.IDs, args.Tags)
+	}
+	if args.Wait != 30*time.Second || args.Ratio != 0.5 || args.Link == nil || args.Link.Host != "example.com" {
+		t.Errorf("wait = %v, ratio = %v, link = %v", args.Wait, args.Ratio, args.Link)
+	}
+}
+
+func TestBindConvertsRawParams(t *testing.T) {
+	var args struct {
+		Limit int `param:"limit,max=5"`
+	}
+
+	if err := Bind(&Context{params: ParsedParams{"limit": "3"}}, &args); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	if args.Limit != 3 {
+		t.Errorf("limit = %d, want 3", args.Limit)
+	}
+
+	err := Bind(&Context{params: ParsedParams{"limit": "9"}}, &args)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || errs[0].Kind != ValidationMax {
+		t.Errorf("Bind() error = %v, want max violation", err)
+	}
+}
+
+type rangeArgs struct {
+	From int `param:"from"`
+	To   int `param:"to"`
+}
+
+func (a *rangeArgs) Validate() error {
+	if a.From >= a.To {
+		return errors.New("from must be less than to")
+	}
+	return nil
+}
+
+func TestBindValidateMethod(t *testing.T) {
+	var args rangeArgs
+	err := Bind(&Context{params: ParsedParams{"from": int64(5), "to": int64(1)}}, &args)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || errs[0].Kind != ValidationCommand {
+		t.Errorf("Bind() error = %v, want command validation error", err)
+	}
+}
+
+type countedArgs struct {
+	N int `param:"n"`
+}
+
+var countedValidations int
+
+func (a *countedArgs) Validate() error {
+	countedValidations++
+	if a.N < 0 {
+		return errors.New("n must not be negative")
+	}
+	return nil
+}
+
+func TestCommandTValidatesOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantRun   bool
+		wantReply bool
+	}{
+		{"valid", "/count n=1", true, false},
+		{"invalid", "/count n=-1", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{invocations: newInvocationRegistry(), config: Config{Logger: slog.Default()}}
+			var replied error
+			b.OnParamError(func(_ *Context, err error) error {
+				replied = err
+				return nil
+			})
+			ran := false
+			CommandTWithFilter(b, CommandDef{Name: "count"}, Filter{Users: []int64{1}}, func(*Context, countedArgs) error {
+				ran = true
+				return nil
+			})
+			h := b.commandHandlers[0]
+			if !slices.Equal(h.filter.Users, []int64{1}) {
+				t.Errorf("filter users = %v, want [1]", h.filter.Users)
+			}
+
+			countedValidations = 0
+			ctx := &Context{
+				Context: context.Background(),
+				bot:     b,
+				message: &tg.Message{Message: tt.text, PeerID: &tg.PeerUser{UserID: 1}},
+			}
+			if err := b.runCommand(ctx, h); err != nil {
+				t.Fatalf("runCommand() error = %v", err)
+			}
+			if countedValidations != 1 {
+				t.Errorf("Validate() ran %d times, want 1", countedValidations)
+			}
+			if ran != tt.wantRun || (replied != nil) != tt.wantReply {
+				t.Errorf("ran = %v, param error = %v; want ran %v, reply %v", ran, replied, tt.wantRun, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestCommandTRejectsParams(t *testing.T) {
+	b := &Bot{}
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrInvalidParamSchema) {
+			t.Errorf("CommandT() panic = %v, want %v", err, ErrInvalidParamSchema)
+		}
+	}()
+	CommandT(b, CommandDef{Name: "ban", Params: Params{"user": {Type: TypeUser}}}, func(*Context, bindArgs) error { return nil })
+}
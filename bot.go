@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
@@ -23,26 +24,50 @@ type Bot struct {
 	gaps       *updates.Manager
 
 	// Handlers
-	mu               sync.RWMutex
-	messageHandlers  []handler
-	editHandlers     []handler
-	deleteHandlers   []deleteHandler
-	callbackHandlers []callbackHandler
-	commandHandlers  []commandHandler
-	albumHandlers    []handler
+	mu                 sync.RWMutex
+	messageHandlers    []handler
+	editHandlers       []handler
+	deleteHandlers     []deleteHandler
+	callbackHandlers   []callbackHandler
+	callbackRoutes     []callbackRoute
+	commandHandlers    []commandHandler
+	albumHandlers      []handler
+	startHandlers      []startHandler
+	stateHandlers      []stateHandler
+	sharedPeerHandlers []sharedPeerHandler
 
 	// Command locking
-	commandLock *CommandLock
+	locks *lockManager
 
 	// Album collector
 	albumCollector *albumCollector
 
+	// Guards the saved command scopes file
+	scopesMu sync.Mutex
+
+	// Cached admin status of users in chats
+	admins *adminCache
+
+	// Incoming message throttle per user (nil if disabled)
+	throttle *limiter
+
+	// Conversations waiting for a user's next message
+	conversations *conversations
+
+	// Command handlers running in their own goroutines
+	workers     sync.WaitGroup
+	commands    *commandRunner
+	invocations *invocationRegistry
+
 	// Lifecycle callbacks
-	onReady func(ctx context.Context)
+	onReady      func(ctx context.Context)
+	onParamError ParamErrorFunc
 
 	// State
-	running atomic.Bool
-	selfID  int64
+	builtinsOnce sync.Once
+	running      atomic.Bool
+	selfID       int64
+	selfUsername string
 }
 
 // New creates a new Bot with the given configuration.
@@ -84,11 +109,20 @@ func New(cfg Config) (*Bot, error) {
 	})
 
 	bot := &Bot{
-		config:      cfg,
-		client:      client,
-		dispatcher:  dispatcher,
-		gaps:        gaps,
-		commandLock: NewCommandLock(),
+		config:        cfg,
+		client:        client,
+		dispatcher:    dispatcher,
+		gaps:          gaps,
+		locks:         newLockManager(cfg.CommandLock, cfg.LockTTL, cfg.Logger),
+		conversations: newConversations(),
+		admins:        newAdminCache(),
+		invocations:   newInvocationRegistry(),
+	}
+	bot.commands = newCommandRunner(cfg.MaxConcurrentCommands, &bot.workers)
+	if cfg.UserThrottle != nil {
+		throttle := *cfg.UserThrottle
+		throttle.Scope = RateLimitPerUser
+		bot.throttle = newLimiter(throttle)
 	}
 
 	bot.albumCollector = newAlbumCollector(cfg.AlbumTimeout, bot.handleAlbum)
@@ -97,11 +131,54 @@ func New(cfg Config) (*Bot, error) {
 	return bot, nil
 }
 
+// registerBuiltins registers the built-in commands enabled in Config. It
+// runs when the bot starts, after the user's commands, so that a command
+// or alias registered with the same name replaces the built-in one.
+func (b *Bot) registerBuiltins() {
+	b.builtinsOnce.Do(func() {
+		b.registerBuiltin(CommandDef{
+			Name:        b.config.HelpCommand,
+			Description: "Show available commands",
+		}, b.handleHelp)
+
+		b.registerBuiltin(CommandDef{
+			Name:        b.config.CancelCommand,
+			Description: "Cancel the running command",
+		}, b.handleCancel)
+	})
+}
+
+func (b *Bot) registerBuiltin(def CommandDef, fn HandlerFunc) {
+	if def.Name == "" {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.commandHandlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if h.matchesName(def.Name, b.config.CaseInsensitiveCommands) {
+			b.config.Logger.Info("built-in command replaced by a registered command",
+				"command", def.Name,
+				"by", h.name)
+			return
+		}
+	}
+	b.CommandWithDesc(def, fn)
+}
+
 // OnReady sets a callback that's called when the bot is connected and ready.
 func (b *Bot) OnReady(fn func(ctx context.Context)) {
 	b.onReady = fn
 }
 
+// OnParamError sets a handler for commands rejected because of malformed
+// text or invalid parameters. It replaces the built-in error reply.
+func (b *Bot) OnParamError(fn ParamErrorFunc) {
+	b.onParamError = fn
+}
+
 // OnMessage registers a handler for new messages.
 func (b *Bot) OnMessage(filter Filter, fn HandlerFunc) {
 	b.mu.Lock()
@@ -151,21 +228,117 @@ type CommandDef struct {
 	// Name is the command name without the leading slash.
 	Name string
 
+	// Aliases are alternative names routed to the same handler, e.g. "s"
+	// for "start". Only Name is published to the command menu.
+	Aliases []string
+
 	// Description is shown in the bot's command menu.
 	Description string
 
 	// Params defines the parameter schema for validation.
 	Params Params
 
+	// Validators check relations between parameters after each parameter
+	// passed its own schema checks, e.g. ExactlyOneOf("id", "name").
+	Validators []CommandValidator
+
 	// Locked enables mutual exclusion for this command.
 	// When true, this command blocks other locked commands for the same user.
 	Locked bool
 
+	// Lock configures the lock mode, key, group and hold time. Setting it
+	// implies Locked.
+	Lock *LockOptions
+
+	// RequireAdmin restricts the command to administrators of the group or
+	// channel it is sent in. It never runs in private chats.
+	RequireAdmin bool
+
+	// RequireRights restricts the command to administrators with these
+	// rights. It implies RequireAdmin.
+	RequireRights *AdminRights
+
+	// Roles restricts the command to users holding at least one of these
+	// roles (see Bot.GrantRole). Such commands are published only in the
+	// private chat menus of those users.
+	Roles []string
+
+	// Timeout cancels the context of an invocation after this duration.
+	// Zero means no timeout.
+	Timeout time.Duration
+
+	// Cooldown is the minimum time between two invocations by the same user.
+	Cooldown time.Duration
+
+	// RateLimit limits invocations per user, chat or globally.
+	RateLimit *RateLimit
+
 	// Scope defines where the command is available (default: ScopeDefault).
 	Scope CommandScope
 
 	// LangCode is the language code for this command's description.
 	LangCode string
+
+	// Subcommands turns the command into a tree such as "/config get" and
+	// "/config set". The first word after the command selects the
+	// subcommand. The command's own handler (if not nil) runs when no
+	// subcommand is given; otherwise usage is shown.
+	Subcommands []SubcommandDef
+}
+
+// SubcommandDef defines a subcommand of a command or of another subcommand.
+type SubcommandDef struct {
+	// Name is the subcommand word, e.g. "set".
+	Name string
+
+	// Aliases are alternative words for the subcommand.
+	Aliases []string
+
+	// Description is shown in help and usage output.
+	Description string
+
+	// Params defines the parameter schema for validation.
+	Params Params
+
+	// Validators check relations between parameters.
+	Validators []CommandValidator
+
+	// Locked enables mutual exclusion for this subcommand.
+	Locked bool
+
+	// Lock configures the subcommand's lock. Setting it implies Locked.
+	// It replaces the parent's lock for this subcommand.
+	Lock *LockOptions
+
+	// Filter restricts who can use the subcommand, in addition to the
+	// parent's filter. The zero value matches everyone.
+	Filter Filter
+
+	// Handler runs the subcommand. It may be nil if Subcommands is set.
+	Handler HandlerFunc
+
+	// Subcommands nests further subcommands.
+	Subcommands []SubcommandDef
+}
+
+func newSubcommands(parent string, defs []SubcommandDef) []commandHandler {
+	var subs []commandHandler
+	for _, def := range defs {
+		path := parent + " " + def.Name
+		subs = append(subs, commandHandler{
+			name:        def.Name,
+			path:        path,
+			aliases:     def.Aliases,
+			description: def.Description,
+			params:      def.Params,
+			validators:  def.Validators,
+			fn:          def.Handler,
+			filter:      def.Filter,
+			lock:        lockOptions(def.Locked, def.Lock),
+			subcommands: newSubcommands(path, def.Subcommands),
+		})
+	}
+	return subs
 }
 
 // Command registers a command handler with optional parameter schema.
@@ -179,19 +352,36 @@ func (b *Bot) CommandWithDesc(def CommandDef, fn HandlerFunc) {
 }
 
 // CommandWithFilter registers a command handler with a custom filter.
+// It panics with ErrInvalidParamSchema if a parameter of the command or its
+// subcommands has an invalid Pattern or a Min or Max of the wrong type.
 func (b *Bot) CommandWithFilter(def CommandDef, filter Filter, fn HandlerFunc) {
+	h := commandHandler{
+		name:          def.Name,
+		path:          def.Name,
+		aliases:       def.Aliases,
+		description:   def.Description,
+		params:        def.Params,
+		validators:    def.Validators,
+		fn:            fn,
+		filter:        filter,
+		lock:          lockOptions(def.Locked, def.Lock),
+		scope:         def.Scope,
+		langCode:      def.LangCode,
+		subcommands:   newSubcommands(def.Name, def.Subcommands),
+		roles:         def.Roles,
+		requireAdmin:  def.RequireAdmin,
+		requireRights: def.RequireRights,
+		timeout:       def.Timeout,
+		cooldown:      newCooldown(def.Cooldown),
+		rateLimit:     rateLimiter(def.RateLimit),
+	}
+	if err := checkCommandSchema(h); err != nil {
+		panic(err)
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.commandHandlers = append(b.commandHandlers, commandHandler{
-		name:        def.Name,
-		description: def.Description,
-		params:      def.Params,
-		fn:          fn,
-		filter:      filter,
-		locked:      def.Locked,
-		scope:       def.Scope,
-		langCode:    def.LangCode,
-	})
+	b.commandHandlers = append(b.commandHandlers, h)
 }
 
 // CommandFrom registers a command handler that only responds to specific users.
@@ -252,6 +442,8 @@ func (b *Bot) Run(ctx context.Context) error {
 	}
 	defer b.running.Store(false)
 
+	b.registerBuiltins()
+
 	return b.client.Run(ctx, func(ctx context.Context) error {
 		defer b.albumCollector.stop()
 
@@ -270,6 +462,7 @@ func (b *Bot) Run(ctx context.Context) error {
 			return err
 		}
 		b.selfID = self.ID
+		b.selfUsername = self.Username
 		b.api = tg.NewClient(b.client)
 
 		if b.config.ProfilePhotoURL != "" {
@@ -296,11 +489,22 @@ func (b *Bot) Run(ctx context.Context) error {
 
 		b.config.Logger.Info("bot started", "id", self.ID, "username", self.Username)
 
-		return b.gaps.Run(ctx, b.api, self.ID, updates.AuthOptions{
+		sweepCtx, stopSweep := context.WithCancel(ctx)
+		sweepDone := make(chan struct{})
+		go func() {
+			defer close(sweepDone)
+			b.sweepStates(sweepCtx)
+		}()
+
+		err = b.gaps.Run(ctx, b.api, self.ID, updates.AuthOptions{
 			OnStart: func(ctx context.Context) {
 				b.config.Logger.Info("listening for updates")
 			},
 		})
+		stopSweep()
+		<-sweepDone
+		b.workers.Wait()
+		return err
 	})
 }
 
@@ -313,3 +517,8 @@ func (b *Bot) API() *tg.Client {
 func (b *Bot) SelfID() int64 {
 	return b.selfID
 }
+
+// SelfUsername returns the bot's username without "@".
+func (b *Bot) SelfUsername() string {
+	return b.selfUsername
+}
@@ -0,0 +1,456 @@
+package telekit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	callbackFlagSigned  = 1 << 0
+	callbackFlagExpiry  = 1 << 1
+	callbackFlagVersion = 1 << 2
+
+	callbackMACLen     = 8
+	callbackExpiryLen  = 4
+	callbackOverflowID = 9 // random bytes of an overflow ID
+
+	// callbackOverflowMark starts the encoded part of overflowed data.
+	// It is not in the base64url alphabet.
+	callbackOverflowMark = "~"
+
+	// defaultCallbackOverflowTTL is how long overflowed payloads are kept
+	// if Config.CallbackTTL is zero.
+	defaultCallbackOverflowTTL = 7 * 24 * time.Hour
+)
+
+// CallbackVersioner is implemented by callback data types that carry a
+// version. Data encoded with another version is rejected by
+// CallbackContext.Bind with ErrCallbackDataOutdated, so buttons sent before
+// a layout change cannot be misread.
+type CallbackVersioner interface {
+	CallbackVersion() uint8
+}
+
+// CallbackStore keeps callback payloads that do not fit into the 64 bytes
+// of callback data. Implementations must be safe for concurrent use.
+type CallbackStore interface {
+	// Put stores data under id until ttl has passed.
+	Put(ctx context.Context, id string, data []byte, ttl time.Duration) error
+
+	// Get returns the data stored under id, or false if it is missing or
+	// expired.
+	Get(ctx context.Context, id string) ([]byte, bool, error)
+}
+
+// MemoryCallbackStore keeps overflowed callback payloads in memory.
+// Payloads are lost on restart.
+type MemoryCallbackStore struct {
+	mu      sync.Mutex
+	entries map[string]callbackEntry
+	puts    int
+}
+
+type callbackEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryCallbackStore creates an empty in-memory callback store.
+func NewMemoryCallbackStore() *MemoryCallbackStore {
+	return &MemoryCallbackStore{
+		entries: make(map[string]callbackEntry),
+	}
+}
+
+func (s *MemoryCallbackStore) Put(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.puts++
+	if s.puts%pruneEvery == 0 {
+		s.prune(now)
+	}
+	s.entries[id] = callbackEntry{data: append([]byte(nil), data...), expires: now.Add(ttl)}
+	return nil
+}
+
+// prune removes expired entries. The caller must hold s.mu.
+func (s *MemoryCallbackStore) prune(now time.Time) {
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func (s *MemoryCallbackStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		delete(s.entries, id)
+		return nil, false, nil
+	}
+	return append([]byte(nil), e.data...), true, nil
+}
+
+// CallbackData encodes v as callback data "<prefix>:<payload>", so
+// handlers can be routed with OnCallbackPrefix(prefix + ":") and decode it
+// with CallbackContext.Bind.
+//
+// v must be a struct or a pointer to one. Its exported fields are encoded
+// in order without names; supported types are bool, string, []byte, all
+// integer and float kinds (including time.Duration), nested structs and
+// slices of these. Fields tagged `callback:"-"` are skipped.
+//
+// The payload is signed with Config.CallbackSecret and expires after
+// Config.CallbackTTL if they are set, and carries the version of types
+// implementing CallbackVersioner. Payloads over the 64-byte limit are kept
+// in Config.CallbackStore under a short ID; without a store
+// ErrCallbackDataTooLong is returned.
+func (b *Bot) CallbackData(ctx context.Context, prefix string, v any) (string, error) {
+	payload, err := encodeCallbackValue(v)
+	if err != nil {
+		return "", err
+	}
+
+	var version int
+	if vv, ok := v.(CallbackVersioner); ok {
+		version = int(vv.CallbackVersion())
+	} else {
+		version = -1
+	}
+	var expiry time.Time
+	if b.config.CallbackTTL > 0 {
+		expiry = time.Now().Add(b.config.CallbackTTL)
+	}
+	body := encodeCallbackBody(prefix, payload, version, expiry, b.config.CallbackSecret)
+
+	data := prefix + ":" + base64.RawURLEncoding.EncodeToString(body)
+	if len(data) <= maxCallbackDataLen {
+		return data, nil
+	}
+
+	if b.config.CallbackStore == nil {
+		return "", fmt.Errorf("%w: %d bytes encoded, maximum is %d",
+			ErrCallbackDataTooLong, len(data), maxCallbackDataLen)
+	}
+	var raw [callbackOverflowID]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw[:])
+	data = prefix + ":" + callbackOverflowMark + id
+	if len(data) > maxCallbackDataLen {
+		return "", fmt.Errorf("%w: prefix %q is too long", ErrCallbackDataTooLong, prefix)
+	}
+
+	ttl := b.config.CallbackTTL
+	if ttl <= 0 {
+		ttl = defaultCallbackOverflowTTL
+	}
+	if err := b.config.CallbackStore.Put(ctx, id, body, ttl); err != nil {
+		return "", fmt.Errorf("failed to store callback data: %w", err)
+	}
+	return data, nil
+}
+
+// Bind decodes callback data created by Bot.CallbackData into the struct
+// pointed to by dst. It returns ErrInvalidCallbackData for malformed,
+// forged or unknown data, ErrCallbackDataExpired after Config.CallbackTTL
+// and ErrCallbackDataOutdated if the version of dst's type differs.
+func (c *CallbackContext) Bind(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("telekit: Bind requires a non-nil pointer to a struct")
+	}
+
+	i := strings.LastIndexByte(c.data, ':')
+	if i < 0 {
+		return ErrInvalidCallbackData
+	}
+	prefix, encoded := c.data[:i], c.data[i+1:]
+
+	var body []byte
+	if id, ok := strings.CutPrefix(encoded, callbackOverflowMark); ok {
+		if c.bot.config.CallbackStore == nil {
+			return ErrInvalidCallbackData
+		}
+		data, found, err := c.bot.config.CallbackStore.Get(c, id)
+		if err != nil {
+			return fmt.Errorf("failed to load callback data: %w", err)
+		}
+		if !found {
+			return ErrCallbackDataExpired
+		}
+		body = data
+	} else {
+		data, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return ErrInvalidCallbackData
+		}
+		body = data
+	}
+
+	version := -1
+	if vv, ok := dst.(CallbackVersioner); ok {
+		version = int(vv.CallbackVersion())
+	}
+	payload, err := decodeCallbackBody(prefix, body, version, c.bot.config.CallbackSecret, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := decodeCallbackValue(payload, rv.Elem()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCallbackData, err)
+	}
+	return nil
+}
+
+// encodeCallbackBody wraps payload with its flags, the version if not
+// negative, the expiry if not zero and a MAC of prefix and body if secret
+// is set.
+func encodeCallbackBody(prefix string, payload []byte, version int, expiry time.Time, secret []byte) []byte {
+	body := []byte{0}
+	if version >= 0 {
+		body[0] |= callbackFlagVersion
+		body = append(body, byte(version))
+	}
+	if !expiry.IsZero() {
+		body[0] |= callbackFlagExpiry
+		body = binary.BigEndian.AppendUint32(body, uint32(expiry.Unix()))
+	}
+	if len(secret) > 0 {
+		body[0] |= callbackFlagSigned
+	}
+	body = append(body, payload...)
+	if len(secret) > 0 {
+		body = append(body, callbackMAC(secret, prefix, body)...)
+	}
+	return body
+}
+
+// decodeCallbackBody verifies body and returns its payload. When secret is
+// set, unsigned data is rejected.
+func decodeCallbackBody(prefix string, body []byte, version int, secret []byte, now time.Time) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, ErrInvalidCallbackData
+	}
+	flags := body[0]
+	if flags&^(callbackFlagSigned|callbackFlagExpiry|callbackFlagVersion) != 0 {
+		return nil, ErrInvalidCallbackData
+	}
+
+	if flags&callbackFlagSigned != 0 {
+		if len(secret) == 0 || len(body) < 1+callbackMACLen {
+			return nil, ErrInvalidCallbackData
+		}
+		signed, mac := body[:len(body)-callbackMACLen], body[len(body)-callbackMACLen:]
+		if !hmac.Equal(mac, callbackMAC(secret, prefix, signed)) {
+			return nil, ErrInvalidCallbackData
+		}
+		body = signed
+	} else if len(secret) > 0 {
+		return nil, ErrInvalidCallbackData
+	}
+
+	body = body[1:]
+	got := -1
+	if flags&callbackFlagVersion != 0 {
+		if len(body) < 1 {
+			return nil, ErrInvalidCallbackData
+		}
+		got, body = int(body[0]), body[1:]
+	}
+	if flags&callbackFlagExpiry != 0 {
+		if len(body) < callbackExpiryLen {
+			return nil, ErrInvalidCallbackData
+		}
+		if now.Unix() > int64(binary.BigEndian.Uint32(body)) {
+			return nil, ErrCallbackDataExpired
+		}
+		body = body[callbackExpiryLen:]
+	}
+	if got != version {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrCallbackDataOutdated, got, version)
+	}
+	return body, nil
+}
+
+func callbackMAC(secret []byte, prefix string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(prefix))
+	mac.Write([]byte{0})
+	mac.Write(body)
+	return mac.Sum(nil)[:callbackMACLen]
+}
+
+// encodeCallbackValue encodes the exported fields of a struct in order.
+func encodeCallbackValue(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("telekit: callback data must be a struct, got %T", v)
+	}
+	return appendCallbackValue(nil, rv)
+}
+
+func appendCallbackValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf = binary.AppendUvarint(buf, uint64(v.Len()))
+			return append(buf, v.Bytes()...), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		for i := range v.Len() {
+			var err error
+			if buf, err = appendCallbackValue(buf, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Struct:
+		for _, i := range callbackFields(v.Type()) {
+			var err error
+			if buf, err = appendCallbackValue(buf, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("telekit: unsupported callback data type %s", v.Type())
+}
+
+// decodeCallbackValue decodes data into the struct v. All data must be
+// consumed.
+func decodeCallbackValue(data []byte, v reflect.Value) error {
+	rest, err := readCallbackValue(data, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d trailing bytes", len(rest))
+	}
+	return nil
+}
+
+var errCallbackTruncated = errors.New("truncated payload")
+
+func readCallbackValue(data []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 || data[0] > 1 {
+			return nil, errCallbackTruncated
+		}
+		v.SetBool(data[0] == 1)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, size := binary.Varint(data)
+		if size <= 0 {
+			return nil, errCallbackTruncated
+		}
+		if v.OverflowInt(n) {
+			return nil, fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+		return data[size:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, errCallbackTruncated
+		}
+		if v.OverflowUint(n) {
+			return nil, fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+		return data[size:], nil
+	case reflect.Float32:
+		if len(data) < 4 {
+			return nil, errCallbackTruncated
+		}
+		v.SetFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(data))))
+		return data[4:], nil
+	case reflect.Float64:
+		if len(data) < 8 {
+			return nil, errCallbackTruncated
+		}
+		v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(data)))
+		return data[8:], nil
+	case reflect.String, reflect.Slice:
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) {
+			return nil, errCallbackTruncated
+		}
+		data = data[size:]
+		if v.Kind() == reflect.String {
+			v.SetString(string(data[:n]))
+			return data[n:], nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte(nil), data[:n]...))
+			return data[n:], nil
+		}
+		slice := reflect.MakeSlice(v.Type(), int(n), int(n))
+		for i := range int(n) {
+			var err error
+			if data, err = readCallbackValue(data, slice.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		v.Set(slice)
+		return data, nil
+	case reflect.Struct:
+		for _, i := range callbackFields(v.Type()) {
+			var err error
+			if data, err = readCallbackValue(data, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported callback data type %s", v.Type())
+}
+
+// callbackFields returns the indexes of the encoded fields of t.
+func callbackFields(t reflect.Type) []int {
+	var fields []int
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("callback") == "-" {
+			continue
+		}
+		fields = append(fields, i)
+	}
+	return fields
+}
@@ -0,0 +1,230 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type voteData struct {
+	PollID  int64
+	Option  uint8
+	Retract bool
+	Note    string
+	Tags    []string
+	Score   float64
+	Wait    time.Duration
+	Inner   struct{ A, B int }
+	skipped int
+	Ignored string `callback:"-"`
+}
+
+type versionedData struct {
+	ID int
+}
+
+func (versionedData) CallbackVersion() uint8 { return 2 }
+
+type versionedDataV3 struct {
+	ID int
+}
+
+func (versionedDataV3) CallbackVersion() uint8 { return 3 }
+
+func newCallbackTestBot(secret []byte, ttl time.Duration, store CallbackStore) *Bot {
+	return &Bot{config: Config{CallbackSecret: secret, CallbackTTL: ttl, CallbackStore: store}}
+}
+
+func bindCallback(b *Bot, data string, dst any) error {
+	c := &CallbackContext{Context: context.Background(), bot: b, data: data}
+	return c.Bind(dst)
+}
+
+func TestCallbackDataRoundTrip(t *testing.T) {
+	in := voteData{
+		PollID:  -123456789,
+		Option:  3,
+		Retract: true,
+		Note:    "hi",
+		Tags:    []string{"a", "bc"},
+		Score:   0.5,
+		Wait:    time.Minute,
+		Ignored: "not encoded",
+	}
+	in.Inner.A, in.Inner.B = 1, -2
+
+	tests := []struct {
+		name string
+		bot  *Bot
+	}{
+		{"plain", newCallbackTestBot(nil, 0, nil)},
+		{"signed", newCallbackTestBot([]byte("secret"), 0, nil)},
+		{"expiring", newCallbackTestBot(nil, time.Hour, nil)},
+		{"signed and expiring", newCallbackTestBot([]byte("secret"), time.Hour, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.bot.CallbackData(context.Background(), "vote", in)
+			if err != nil {
+				t.Fatalf("CallbackData() error = %v", err)
+			}
+			if len(data) > maxCallbackDataLen || !strings.HasPrefix(data, "vote:") {
+				t.Errorf("CallbackData() = %q (%d bytes)", data, len(data))
+			}
+
+			var out voteData
+			if err := bindCallback(tt.bot, data, &out); err != nil {
+				t.Fatalf("Bind() error = %v", err)
+			}
+			want := in
+			want.Ignored = ""
+			if !reflect.DeepEqual(out, want) {
+				t.Errorf("Bind() = %+v, want %+v", out, want)
+			}
+		})
+	}
+}
+
+func TestCallbackDataInvalid(t *testing.T) {
+	secret := []byte("secret")
+	signed := newCallbackTestBot(secret, 0, nil)
+	data, err := signed.CallbackData(context.Background(), "vote", versionedData{ID: 7})
+	if err != nil {
+		t.Fatalf("CallbackData() error = %v", err)
+	}
+	plain, err := newCallbackTestBot(nil, 0, nil).CallbackData(context.Background(), "vote", versionedData{ID: 7})
+	if err != nil {
+		t.Fatalf("CallbackData() error = %v", err)
+	}
+	moved := "poll" + strings.TrimPrefix(data, "vote")
+
+	tests := []struct {
+		name string
+		bot  *Bot
+		data string
+		dst  any
+		want error
+	}{
+		{"no separator", signed, "vote", &versionedData{}, ErrInvalidCallbackData},
+		{"bad base64", signed, "vote:!!", &versionedData{}, ErrInvalidCallbackData},
+		{"unsigned with secret", signed, plain, &versionedData{}, ErrInvalidCallbackData},
+		{"wrong secret", newCallbackTestBot([]byte("other"), 0, nil), data, &versionedData{}, ErrInvalidCallbackData},
+		{"moved prefix", signed, moved, &versionedData{}, ErrInvalidCallbackData},
+		{"other version", signed, data, &versionedDataV3{}, ErrCallbackDataOutdated},
+		{"unversioned type", signed, data, &voteData{}, ErrCallbackDataOutdated},
+		{"unknown overflow", newCallbackTestBot(nil, 0, NewMemoryCallbackStore()), "vote:~abc", &versionedData{}, ErrCallbackDataExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := bindCallback(tt.bot, tt.data, tt.dst); !errors.Is(err, tt.want) {
+				t.Errorf("Bind() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	var out versionedData
+	if err := bindCallback(signed, data, &out); err != nil || out.ID != 7 {
+		t.Errorf("Bind() = %+v, %v, want ID 7", out, err)
+	}
+	if err := bindCallback(signed, data, out); err == nil {
+		t.Error("Bind() with non-pointer succeeded")
+	}
+}
+
+func TestCallbackDataExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := encodeCallbackBody("x", []byte{1}, -1, now.Add(time.Minute), nil)
+
+	if _, err := decodeCallbackBody("x", body, -1, nil, now); err != nil {
+		t.Errorf("decodeCallbackBody() before expiry error = %v", err)
+	}
+	if _, err := decodeCallbackBody("x", body, -1, nil, now.Add(2*time.Minute)); !errors.Is(err, ErrCallbackDataExpired) {
+		t.Errorf("decodeCallbackBody() after expiry error = %v, want %v", err, ErrCallbackDataExpired)
+	}
+}
+
+func TestCallbackDataOverflow(t *testing.T) {
+	in := voteData{Note: strings.Repeat("x", 100)}
+
+	if _, err := newCallbackTestBot(nil, 0, nil).CallbackData(context.Background(), "vote", in); !errors.Is(err, ErrCallbackDataTooLong) {
+		t.Errorf("CallbackData() without store error = %v, want %v", err, ErrCallbackDataTooLong)
+	}
+
+	b := newCallbackTestBot([]byte("secret"), 0, NewMemoryCallbackStore())
+	data, err := b.CallbackData(context.Background(), "vote", in)
+	if err != nil {
+		t.Fatalf("CallbackData() error = %v", err)
+	}
+	if len(data) > maxCallbackDataLen || !strings.HasPrefix(data, "vote:~") {
+		t.Errorf("CallbackData() = %q, want overflow reference", data)
+	}
+
+	var out voteData
+	if err := bindCallback(b, data, &out); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	if out.Note != in.Note {
+		t.Errorf("Bind() Note = %q, want %q", out.Note, in.Note)
+	}
+}
+
+func TestCallbackDataDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", []byte{}},
+		{"trailing bytes", []byte{2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{"long string", []byte{2, 9}},
+		{"bad bool", []byte{0, 0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				N int8
+				S string
+				B bool
+			}
+			if err := decodeCallbackValue(tt.data, reflect.ValueOf(&v).Elem()); err == nil {
+				t.Errorf("decodeCallbackValue(%v) succeeded", tt.data)
+			}
+		})
+	}
+
+	var v struct{ N int8 }
+	if err := decodeCallbackValue([]byte{0x80, 0x04}, reflect.ValueOf(&v).Elem()); err == nil {
+		t.Error("decodeCallbackValue() with overflowing int8 succeeded")
+	}
+}
+
+func TestMemoryCallbackStore(t *testing.T) {
+	s := NewMemoryCallbackStore()
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "a", []byte("data"), time.Hour); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(ctx, "old", []byte("x"), -time.Second); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if data, ok, err := s.Get(ctx, "a"); err != nil || !ok || string(data) != "data" {
+		t.Errorf("Get(a) = %q, %v, %v", data, ok, err)
+	}
+	if _, ok, _ := s.Get(ctx, "old"); ok {
+		t.Error("Get(old) found an expired entry")
+	}
+	if _, ok, _ := s.Get(ctx, "missing"); ok {
+		t.Error("Get(missing) found an entry")
+	}
+
+	for i := range pruneEvery {
+		_ = s.Put(ctx, strconv.Itoa(i), nil, -time.Second)
+	}
+	if len(s.entries) > pruneEvery {
+		t.Errorf("store keeps %d entries, want expired entries pruned", len(s.entries))
+	}
+}
@@ -0,0 +1,187 @@
+package telekit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// routeParamName matches parameter names of callback routes.
+var routeParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// callbackRoute is a parsed callback route pattern such as
+// "item/{id:int}/delete".
+type callbackRoute struct {
+	pattern  string
+	segments []routeSegment
+	fn       CallbackFunc
+}
+
+// routeSegment is a literal path segment, or a parameter if name is set.
+type routeSegment struct {
+	literal string
+	name    string
+	typ     ParamType
+}
+
+// OnCallbackRoute registers a handler for callback data matching pattern.
+// Patterns are "/"-separated segments; a segment is either literal text or
+// a parameter "{name}" or "{name:type}" with type string (the default),
+// int, float or bool:
+//
+//	b.OnCallbackRoute("item/{id:int}/delete", func(ctx *telekit.CallbackContext) error {
+//		id, _ := ctx.ParamInt("id")
+//		...
+//	})
+//
+// Captured values are available through CallbackContext.Param. Routes may
+// not overlap: it panics if pattern is invalid or some callback data could
+// match both pattern and an already registered route.
+func (b *Bot) OnCallbackRoute(pattern string, fn CallbackFunc) {
+	route, err := parseCallbackRoute(pattern)
+	if err != nil {
+		panic(err)
+	}
+	route.fn = fn
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, other := range b.callbackRoutes {
+		if route.overlaps(other) {
+			panic(fmt.Errorf("%w: %q conflicts with %q", ErrAmbiguousRoute, pattern, other.pattern))
+		}
+	}
+	b.callbackRoutes = append(b.callbackRoutes, route)
+}
+
+func parseCallbackRoute(pattern string) (callbackRoute, error) {
+	if pattern == "" {
+		return callbackRoute{}, fmt.Errorf("%w: empty pattern", ErrInvalidRoute)
+	}
+
+	route := callbackRoute{pattern: pattern}
+	names := make(map[string]bool)
+	for part := range strings.SplitSeq(pattern, "/") {
+		if part == "" {
+			return callbackRoute{}, fmt.Errorf("%w: %q has an empty segment", ErrInvalidRoute, pattern)
+		}
+		if !strings.HasPrefix(part, "{") {
+			if strings.ContainsAny(part, "{}") {
+				return callbackRoute{}, fmt.Errorf("%w: %q: malformed segment %q", ErrInvalidRoute, pattern, part)
+			}
+			route.segments = append(route.segments, routeSegment{literal: part})
+			continue
+		}
+		if !strings.HasSuffix(part, "}") {
+			return callbackRoute{}, fmt.Errorf("%w: %q: malformed segment %q", ErrInvalidRoute, pattern, part)
+		}
+
+		name, typ, _ := strings.Cut(part[1:len(part)-1], ":")
+		if !routeParamName.MatchString(name) {
+			return callbackRoute{}, fmt.Errorf("%w: %q: invalid parameter name %q", ErrInvalidRoute, pattern, name)
+		}
+		if names[name] {
+			return callbackRoute{}, fmt.Errorf("%w: %q: duplicate parameter %q", ErrInvalidRoute, pattern, name)
+		}
+		names[name] = true
+
+		seg := routeSegment{name: name, typ: ParamType(typ)}
+		switch seg.typ {
+		case "":
+			seg.typ = TypeString
+		case TypeString, TypeInt, TypeFloat, TypeBool:
+		default:
+			return callbackRoute{}, fmt.Errorf("%w: %q: unsupported parameter type %q", ErrInvalidRoute, pattern, typ)
+		}
+		route.segments = append(route.segments, seg)
+	}
+	return route, nil
+}
+
+// match returns the parameters captured from data, or false if data does
+// not match the route.
+func (r callbackRoute) match(data string) (map[string]string, bool) {
+	parts := strings.Split(data, "/")
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, seg := range r.segments {
+		if !seg.accepts(parts[i]) {
+			return nil, false
+		}
+		if seg.name != "" {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.name] = parts[i]
+		}
+	}
+	return params, true
+}
+
+// overlaps reports whether some callback data matches both routes.
+func (r callbackRoute) overlaps(other callbackRoute) bool {
+	if len(r.segments) != len(other.segments) {
+		return false
+	}
+	for i, a := range r.segments {
+		if !a.overlaps(other.segments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s routeSegment) accepts(value string) bool {
+	if s.name == "" {
+		return value == s.literal
+	}
+	switch s.typ {
+	case TypeInt:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case TypeFloat:
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case TypeBool:
+		return value == "true" || value == "false"
+	}
+	return value != ""
+}
+
+func (s routeSegment) overlaps(other routeSegment) bool {
+	switch {
+	case s.name == "" && other.name == "":
+		return s.literal == other.literal
+	case s.name == "":
+		return other.accepts(s.literal)
+	case other.name == "":
+		return s.accepts(other.literal)
+	}
+
+	// every int is also a float; strings accept anything
+	if s.typ == other.typ || s.typ == TypeString || other.typ == TypeString {
+		return true
+	}
+	numeric := func(t ParamType) bool { return t == TypeInt || t == TypeFloat }
+	return numeric(s.typ) && numeric(other.typ)
+}
+
+// Param returns the value of a parameter captured by the callback route,
+// or "" if there is none with this name.
+func (c *CallbackContext) Param(name string) string {
+	return c.params[name]
+}
+
+// ParamInt returns the value of an int parameter captured by the callback
+// route.
+func (c *CallbackContext) ParamInt(name string) (int64, error) {
+	v, ok := c.params[name]
+	if !ok {
+		return 0, fmt.Errorf("no route parameter %q", name)
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -0,0 +1,169 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+// recordingInvoker records the requests sent through a tg.Client.
+type recordingInvoker struct {
+	mu       sync.Mutex
+	requests []bin.Encoder
+}
+
+func (r *recordingInvoker) Invoke(_ context.Context, input bin.Encoder, _ bin.Decoder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, input)
+	return nil
+}
+
+// sentTexts returns the texts of the messages sent so far.
+func (r *recordingInvoker) sentTexts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var texts []string
+	for _, req := range r.requests {
+		if m, ok := req.(*tg.MessagesSendMessageRequest); ok {
+			texts = append(texts, m.Message)
+		}
+	}
+	return texts
+}
+
+func TestCallbackRouteMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		data    string
+		want    map[string]string
+		ok      bool
+	}{
+		{"item/{id:int}/delete", "item/42/delete", map[string]string{"id": "42"}, true},
+		{"item/{id:int}/delete", "item/-1/delete", map[string]string{"id": "-1"}, true},
+		{"item/{id:int}/delete", "item/abc/delete", nil, false},
+		{"item/{id:int}/delete", "item/42", nil, false},
+		{"item/{id:int}/delete", "item/42/delete/x", nil, false},
+		{"page/{name}", "page/home", map[string]string{"name": "home"}, true},
+		{"page/{name}", "page/", nil, false},
+		{"set/{on:bool}", "set/true", map[string]string{"on": "true"}, true},
+		{"set/{on:bool}", "set/1", nil, false},
+		{"zoom/{f:float}", "zoom/1.5", map[string]string{"f": "1.5"}, true},
+		{"menu", "menu", nil, true},
+		{"menu", "menus", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.data, func(t *testing.T) {
+			route, err := parseCallbackRoute(tt.pattern)
+			if err != nil {
+				t.Fatalf("parseCallbackRoute() error = %v", err)
+			}
+			got, ok := route.match(tt.data)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match(%q) = %v, %v, want %v, %v", tt.data, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseCallbackRouteInvalid(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"a//b",
+		"a/{id",
+		"a/x{id}",
+		"a/{}",
+		"a/{1d}",
+		"a/{id}/{id}",
+		"a/{id:uuid}",
+	} {
+		if _, err := parseCallbackRoute(pattern); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("parseCallbackRoute(%q) error = %v, want %v", pattern, err, ErrInvalidRoute)
+		}
+	}
+}
+
+func TestCallbackRouteOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"item/{id:int}", "item/{n:int}", true},
+		{"item/{id:int}", "item/{name}", true},
+		{"item/{id:int}", "item/new", false},
+		{"item/{name}", "item/new", true},
+		{"item/{id:int}", "item/{f:float}", true},
+		{"item/{id:int}", "item/{on:bool}", false},
+		{"item/{on:bool}", "item/true", true},
+		{"item/{id:int}", "item/{id:int}/delete", false},
+		{"item/{id:int}/edit", "item/{id:int}/delete", false},
+		{"menu", "menu", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, _ := parseCallbackRoute(tt.a)
+			b, _ := parseCallbackRoute(tt.b)
+			if got := a.overlaps(b); got != tt.want {
+				t.Errorf("overlaps() = %v, want %v", got, tt.want)
+			}
+			if got := b.overlaps(a); got != tt.want {
+				t.Errorf("reverse overlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnCallbackRouteAmbiguous(t *testing.T) {
+	b := &Bot{}
+	noop := func(*CallbackContext) error { return nil }
+	b.OnCallbackRoute("item/{id:int}/delete", noop)
+	b.OnCallbackRoute("item/new/delete", noop)
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrAmbiguousRoute) {
+			t.Errorf("OnCallbackRoute() panic = %v, want %v", err, ErrAmbiguousRoute)
+		}
+	}()
+	b.OnCallbackRoute("item/{name}/delete", noop)
+}
+
+func TestHandleCallbackRoutes(t *testing.T) {
+	invoker := &recordingInvoker{}
+	b := &Bot{api: tg.NewClient(invoker), config: Config{Logger: slog.Default()}}
+
+	var gotID int64
+	b.OnCallbackRoute("item/{id:int}/delete", func(ctx *CallbackContext) error {
+		id, err := ctx.ParamInt("id")
+		gotID = id
+		return err
+	})
+
+	if err := b.handleCallback(context.Background(), &tg.UpdateBotCallbackQuery{QueryID: 1, Data: []byte("item/7/delete")}, tg.Entities{}); err != nil {
+		t.Fatalf("handleCallback() error = %v", err)
+	}
+	if gotID != 7 {
+		t.Errorf("route handler id = %d, want 7", gotID)
+	}
+	if len(invoker.requests) != 0 {
+		t.Errorf("matched callback sent %d requests, want 0", len(invoker.requests))
+	}
+
+	if err := b.handleCallback(context.Background(), &tg.UpdateBotCallbackQuery{QueryID: 2, Data: []byte("unknown")}, tg.Entities{}); err != nil {
+		t.Fatalf("handleCallback() error = %v", err)
+	}
+	if len(invoker.requests) != 1 {
+		t.Fatalf("unmatched callback sent %d requests, want 1", len(invoker.requests))
+	}
+	answer, ok := invoker.requests[0].(*tg.MessagesSetBotCallbackAnswerRequest)
+	if !ok || answer.QueryID != 2 {
+		t.Errorf("unmatched callback request = %#v, want callback answer for query 2", invoker.requests[0])
+	}
+}
@@ -1,44 +1,302 @@
 package telekit
 
-import "sync"
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
 
-// CommandLock provides mutual exclusion for locked commands per user.
-// Locked commands block other locked commands for the same user.
-// Non-locked commands always run without blocking.
-type CommandLock struct {
-	mu    sync.Mutex
-	locks map[int64]bool
+// LockMode selects what happens when a locked command finds its lock taken.
+type LockMode int
+
+const (
+	// LockReject rejects the new invocation and tells the user to wait.
+	LockReject LockMode = iota
+
+	// LockQueue waits until the running invocation releases the lock.
+	LockQueue
+
+	// LockCancelPrevious cancels the running invocation's context and
+	// waits for it to release the lock.
+	LockCancelPrevious
+)
+
+// LockKeyFunc returns the key of the lock an invocation takes, e.g. one
+// lock per user. An empty key runs the command without a lock.
+type LockKeyFunc func(ctx *Context) string
+
+// LockByUser gives each user one lock. It is the default lock key.
+func LockByUser(ctx *Context) string {
+	if userID := ctx.SenderID(); userID != 0 {
+		return userLockKey(userID)
+	}
+	return ""
+}
+
+// LockByChat gives each chat one lock shared by all its users.
+func LockByChat(ctx *Context) string {
+	if chatID := ctx.ChatID(); chatID != 0 {
+		return "chat:" + strconv.FormatInt(chatID, 10)
+	}
+	return ""
 }
 
-// NewCommandLock creates a new CommandLock.
-func NewCommandLock() *CommandLock {
-	return &CommandLock{
-		locks: make(map[int64]bool),
+func userLockKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// LockOptions configures the command lock. The zero value locks per user
+// in the default group and rejects overlapping invocations.
+type LockOptions struct {
+	// Mode selects what happens when the lock is taken.
+	Mode LockMode
+
+	// Key selects the lock. Defaults to LockByUser.
+	Key LockKeyFunc
+
+	// Group names a set of commands sharing locks. Locked commands in
+	// different groups do not block each other. "" is the default group.
+	Group string
+
+	// MaxHold releases the lock and cancels the invocation's context after
+	// this duration, so a hung handler cannot hold the lock forever.
+	// Zero means no limit.
+	MaxHold time.Duration
+
+	// QueueTimeout limits how long LockQueue and LockCancelPrevious wait
+	// for the lock before rejecting. Zero waits as long as the invocation's
+	// context allows.
+	QueueTimeout time.Duration
+}
+
+// lockOptions returns the lock options of a command, nil if it is not locked.
+func lockOptions(locked bool, opts *LockOptions) *LockOptions {
+	if opts != nil {
+		return opts
+	}
+	if locked {
+		return &LockOptions{}
 	}
+	return nil
 }
 
-// TryAcquire checks if command can proceed and optionally acquires lock.
-// If acquire is false, always returns true.
-// If acquire is true, returns true only if lock was acquired.
-func (l *CommandLock) TryAcquire(userID int64, acquire bool) bool {
+// CommandLock is the storage of command locks. Each lock is a lease: it
+// expires after its TTL unless refreshed, so locks of crashed processes
+// are freed automatically. Implementations shared between processes, such
+// as SQLLock and FileLock, let replicas of a bot share locks.
+type CommandLock interface {
+	// Acquire takes the named lock for ttl. It returns a token identifying
+	// the lease, or false if another unexpired lease holds the lock.
+	Acquire(ctx context.Context, name string, ttl time.Duration) (token string, ok bool, err error)
+
+	// Refresh extends the lease identified by token to ttl from now.
+	// It returns ErrLockLost if the lease expired or was taken over.
+	Refresh(ctx context.Context, name, token string, ttl time.Duration) error
+
+	// Release frees the lock if it is still held by the lease.
+	Release(ctx context.Context, name, token string) error
+}
+
+// newLockToken returns a random lease token.
+func newLockToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// MemoryLock keeps command locks in memory. It is the default CommandLock
+// and only works within one process.
+type MemoryLock struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryLock creates an empty in-memory lock store.
+func NewMemoryLock() *MemoryLock {
+	return &MemoryLock{
+		leases: make(map[string]memoryLease),
+	}
+}
+
+// NewCommandLock creates an in-memory lock store.
+//
+// Deprecated: Use NewMemoryLock.
+func NewCommandLock() *MemoryLock {
+	return NewMemoryLock()
+}
+
+// legacyLockTTL is the lease of locks taken with TryAcquire, which are
+// held until Unlock.
+const legacyLockTTL = 100 * 365 * 24 * time.Hour
+
+// TryAcquire checks if a command of userID can proceed and optionally
+// takes the user's lock. If acquire is false, it always returns true. The
+// lock is the one locked commands take by default, so TryAcquire and
+// commands using LockByUser in the default group exclude each other.
+//
+// Deprecated: Set LockOptions on the command instead.
+func (l *MemoryLock) TryAcquire(userID int64, acquire bool) bool {
 	if !acquire {
 		return true
 	}
+	_, ok, _ := l.Acquire(context.Background(), lockName("", userLockKey(userID)), legacyLockTTL)
+	return ok
+}
+
+// Unlock releases the lock of userID, whoever holds it.
+//
+// Deprecated: Set LockOptions on the command instead.
+func (l *MemoryLock) Unlock(userID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.leases, lockName("", userLockKey(userID)))
+}
 
+func (l *MemoryLock) Acquire(_ context.Context, name string, ttl time.Duration) (string, bool, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.locks[userID] {
-		return false
+	now := time.Now()
+	if lease, ok := l.leases[name]; ok && now.Before(lease.expires) {
+		return "", false, nil
 	}
+	token := newLockToken()
+	l.leases[name] = memoryLease{token: token, expires: now.Add(ttl)}
+	return token, true, nil
+}
+
+func (l *MemoryLock) Refresh(_ context.Context, name, token string, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	l.locks[userID] = true
-	return true
+	now := time.Now()
+	lease, ok := l.leases[name]
+	if !ok || lease.token != token || !now.Before(lease.expires) {
+		return ErrLockLost
+	}
+	lease.expires = now.Add(ttl)
+	l.leases[name] = lease
+	return nil
 }
 
-// Unlock releases the lock for user.
-func (l *CommandLock) Unlock(userID int64) {
+func (l *MemoryLock) Release(_ context.Context, name, token string) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	delete(l.locks, userID)
+
+	if lease, ok := l.leases[name]; ok && lease.token == token {
+		delete(l.leases, name)
+	}
+	return nil
+}
+
+// sqlLockAttempts limits how often SQLLock.Acquire retries a lock that was
+// released while it was taking it.
+const sqlLockAttempts = 3
+
+// SQLLock keeps command locks in a database table, so several bot
+// processes can share them. Expiry uses the local clock of each process,
+// so replicas need synchronized clocks.
+type SQLLock struct {
+	db      *sql.DB
+	table   string
+	dialect SQLDialect
+}
+
+// NewSQLLock creates the table if needed and returns a lock store using it.
+func NewSQLLock(ctx context.Context, db *sql.DB, table string, dialect SQLDialect) (*SQLLock, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	l := &SQLLock{db: db, table: table, dialect: dialect}
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		name VARCHAR(255) NOT NULL PRIMARY KEY,
+		token VARCHAR(64) NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock table: %w", err)
+	}
+	return l, nil
+}
+
+func (l *SQLLock) query(q string) string {
+	return rebindQuery(q, l.dialect)
+}
+
+func (l *SQLLock) Acquire(ctx context.Context, name string, ttl time.Duration) (string, bool, error) {
+	// The insert fails on the primary key while the lock is held. If the
+	// holder releases it before the lookup, the lock is free and the
+	// insert is tried again. An insert failing for another reason finds
+	// no row either, so the attempts are limited.
+	var err error
+	for range sqlLockAttempts {
+		now := time.Now()
+		if _, err := l.db.ExecContext(ctx,
+			l.query(`DELETE FROM `+l.table+` WHERE name = ? AND expires_at <= ?`),
+			name, now.UnixNano(),
+		); err != nil {
+			return "", false, fmt.Errorf("failed to clear expired lock: %w", err)
+		}
+
+		token := newLockToken()
+		_, err = l.db.ExecContext(ctx,
+			l.query(`INSERT INTO `+l.table+` (name, token, expires_at) VALUES (?, ?, ?)`),
+			name, token, now.Add(ttl).UnixNano(),
+		)
+		if err == nil {
+			return token, true, nil
+		}
+
+		var held string
+		qerr := l.db.QueryRowContext(ctx,
+			l.query(`SELECT token FROM `+l.table+` WHERE name = ?`), name,
+		).Scan(&held)
+		if qerr == nil {
+			return "", false, nil
+		}
+		if !errors.Is(qerr, sql.ErrNoRows) {
+			break
+		}
+	}
+	return "", false, fmt.Errorf("failed to acquire lock: %w", err)
+}
+
+func (l *SQLLock) Refresh(ctx context.Context, name, token string, ttl time.Duration) error {
+	now := time.Now()
+	res, err := l.db.ExecContext(ctx,
+		l.query(`UPDATE `+l.table+` SET expires_at = ? WHERE name = ? AND token = ? AND expires_at > ?`),
+		now.Add(ttl).UnixNano(), name, token, now.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to refresh lock: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *SQLLock) Release(ctx context.Context, name, token string) error {
+	_, err := l.db.ExecContext(ctx,
+		l.query(`DELETE FROM `+l.table+` WHERE name = ? AND token = ?`),
+		name, token,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
 }
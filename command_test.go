@@ -0,0 +1,55 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryLock(t *testing.T) {
+	l := NewMemoryLock()
+	ctx := context.Background()
+
+	token, ok, err := l.Acquire(ctx, "/user:1", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("Acquire() = %v, %v", ok, err)
+	}
+	if _, ok, _ := l.Acquire(ctx, "/user:1", time.Minute); ok {
+		t.Error("Acquire() succeeded while the lease is held")
+	}
+	if _, ok, _ := l.Acquire(ctx, "reports/user:1", time.Minute); !ok {
+		t.Error("Acquire() of another lock failed")
+	}
+
+	if err := l.Refresh(ctx, "/user:1", "other", time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Refresh() with a foreign token error = %v, want %v", err, ErrLockLost)
+	}
+	if err := l.Refresh(ctx, "/user:1", token, time.Minute); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+
+	l.Release(ctx, "/user:1", "other")
+	if _, ok, _ := l.Acquire(ctx, "/user:1", time.Minute); ok {
+		t.Error("Release() with a foreign token freed the lock")
+	}
+	l.Release(ctx, "/user:1", token)
+	if _, ok, _ := l.Acquire(ctx, "/user:1", time.Minute); !ok {
+		t.Error("Acquire() failed after Release()")
+	}
+}
+
+func TestMemoryLockExpiry(t *testing.T) {
+	l := NewMemoryLock()
+	ctx := context.Background()
+
+	token, _, _ := l.Acquire(ctx, "/chat:5", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	if err := l.Refresh(ctx, "/chat:5", token, time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Refresh() of an expired lease error = %v, want %v", err, ErrLockLost)
+	}
+	if _, ok, _ := l.Acquire(ctx, "/chat:5", time.Minute); !ok {
+		t.Error("Acquire() of an expired lock failed")
+	}
+}
@@ -54,6 +54,108 @@ type Config struct {
 	// Commands registered in OnReady will be included.
 	SyncCommands bool
 
+	// CaseInsensitiveCommands matches command names, aliases and
+	// subcommands regardless of case, so "/Start" runs "start".
+	CaseInsensitiveCommands bool
+
+	// RequireBotMention ignores commands in groups and channels unless they
+	// are addressed to this bot with a suffix, as in "/start@mybot".
+	// Commands addressed to other bots are always ignored.
+	RequireBotMention bool
+
+	// StartLinkSecret signs payloads of links created by Bot.StartLink so
+	// users cannot forge them. When set, unsigned payloads are rejected.
+	StartLinkSecret []byte
+
+	// StartLinkTTL makes payloads of new start links expire after this
+	// duration. Zero means links never expire.
+	StartLinkTTL time.Duration
+
+	// CallbackSecret signs callback data created by Bot.CallbackData so
+	// users cannot forge it. When set, unsigned data is rejected.
+	CallbackSecret []byte
+
+	// CallbackTTL makes new callback data expire after this duration.
+	// Zero means it never expires.
+	CallbackTTL time.Duration
+
+	// CallbackStore keeps callback data that does not fit into 64 bytes,
+	// e.g. a MemoryCallbackStore. If nil, Bot.CallbackData fails with
+	// ErrCallbackDataTooLong for such data.
+	CallbackStore CallbackStore
+
+	// ConversationTimeout is the default time Context.WaitMessage and
+	// Context.Ask wait for an answer. Defaults to 5 minutes if zero.
+	ConversationTimeout time.Duration
+
+	// MaxConcurrentCommands limits the command handlers queued or running
+	// at once. Commands of the same user in the same chat run one at a
+	// time in arrival order; a handler waiting in Context.WaitMessage or
+	// Context.Ask no longer counts and lets the user's next command run.
+	// When the limit is reached, updates are not processed until a command
+	// finishes. Defaults to 64 if zero.
+	MaxConcurrentCommands int
+
+	// ProgressInterval is the minimum time between edits of a status
+	// message created by Context.Progress, to stay within Telegram's edit
+	// limits. Defaults to 3 seconds if zero.
+	ProgressInterval time.Duration
+
+	// StateStore persists dialog states used by Bot.OnState and
+	// Context.SetState. Defaults to a MemoryStateStore if nil.
+	StateStore StateStore
+
+	// StateSweepInterval is how often expired dialog states are cleared
+	// from a StateStore implementing StateSweeper. Defaults to 1 minute if
+	// zero; negative disables the sweep.
+	StateSweepInterval time.Duration
+
+	// RoleStore persists user roles used by CommandDef.Roles.
+	// Defaults to a MemoryRoleStore if nil.
+	RoleStore RoleStore
+
+	// AdminCacheTTL is how long admin rights checked for
+	// CommandDef.RequireAdmin are cached. Defaults to 1 minute if zero.
+	AdminCacheTTL time.Duration
+
+	// CommandLock stores command locks. Use a shared implementation such
+	// as SQLLock, or FileLock for processes on one host, when several bot
+	// processes serve the same users.
+	// Defaults to a MemoryLock if nil.
+	CommandLock CommandLock
+
+	// LockTTL is the lease time of command locks without LockOptions.MaxHold.
+	// Running invocations renew their leases; locks of crashed processes
+	// expire after this time. Defaults to 30 seconds if zero.
+	LockTTL time.Duration
+
+	// HelpCommand enables a built-in help command with this name (e.g. "help").
+	// It lists the commands visible to the requesting user and shows the
+	// usage of a single command when called as "/help <command>".
+	// A command or alias registered with this name replaces it.
+	// Empty disables the built-in command.
+	HelpCommand string
+
+	// UserThrottle drops incoming messages from users who exceed this rate
+	// before any handler runs. Nil disables the throttle.
+	UserThrottle *RateLimit
+
+	// CancelCommand enables a built-in command with this name (e.g. "cancel")
+	// that cancels the sender's running commands and releases their locks.
+	// A command or alias registered with this name replaces it.
+	// Empty disables the built-in command.
+	CancelCommand string
+
+	// ErrorReply controls where command errors, such as invalid parameters
+	// or rate limits, are reported.
+	// Defaults to ErrorReplyInChat. Ignored when Bot.OnParamError is set.
+	ErrorReply ErrorReplyMode
+
+	// Messages overrides built-in reply texts per language code, chosen by
+	// the sender's Telegram language. The "" entry applies to all languages.
+	// Missing texts fall back to DefaultMessages.
+	Messages map[string]Messages
+
 	// BotInfo is the bot profile information to set on startup.
 	// If set, the bot info will be updated when the bot starts.
 	BotInfo *BotInfo
@@ -91,6 +193,33 @@ func (c *Config) setDefaults() {
 	if c.AlbumTimeout == 0 {
 		c.AlbumTimeout = 500 * time.Millisecond
 	}
+	if c.StateStore == nil {
+		c.StateStore = NewMemoryStateStore()
+	}
+	if c.RoleStore == nil {
+		c.RoleStore = NewMemoryRoleStore()
+	}
+	if c.CommandLock == nil {
+		c.CommandLock = NewMemoryLock()
+	}
+	if c.LockTTL == 0 {
+		c.LockTTL = 30 * time.Second
+	}
+	if c.AdminCacheTTL == 0 {
+		c.AdminCacheTTL = time.Minute
+	}
+	if c.StateSweepInterval == 0 {
+		c.StateSweepInterval = time.Minute
+	}
+	if c.MaxConcurrentCommands == 0 {
+		c.MaxConcurrentCommands = 64
+	}
+	if c.ConversationTimeout == 0 {
+		c.ConversationTimeout = 5 * time.Minute
+	}
+	if c.ProgressInterval == 0 {
+		c.ProgressInterval = 3 * time.Second
+	}
 }
 
 func (c *Config) validate() error {
@@ -2,8 +2,9 @@ package telekit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
 
-	"github.com/gotd/td/telegram/message"
 	"github.com/gotd/td/tg"
 )
 
@@ -17,7 +18,27 @@ type Context struct {
 	entities tg.Entities
 
 	// Parsed command parameters (nil if not a command)
-	params ParsedParams
+	command    string
+	subcommand string
+	params     ParsedParams
+	args       []string
+	body       string
+
+	// async is set for command handlers, which run in their own goroutine
+	// and may wait for further messages; locked reports whether the
+	// invocation holds the sender's command lock.
+	async  bool
+	locked bool
+
+	// turn is the command's place in the sender's command queue.
+	turn *commandTurn
+
+	// invocationID identifies a running command in Bot.Invocations.
+	invocationID uint64
+
+	// Dialog state and sender roles, loaded on first use
+	state *State
+	roles []string
 
 	// For album handling
 	messages []*tg.Message
@@ -94,6 +115,14 @@ func (c *Context) SenderID() int64 {
 	return 0
 }
 
+// LangCode returns the sender's Telegram client language code, if known.
+func (c *Context) LangCode() string {
+	if u, ok := c.entities.Users[c.SenderID()]; ok {
+		return u.LangCode
+	}
+	return ""
+}
+
 // IsOutgoing returns true if this is an outgoing message.
 func (c *Context) IsOutgoing() bool {
 	if c.message != nil {
@@ -145,6 +174,17 @@ func (c *Context) Media() tg.MessageMediaClass {
 	return nil
 }
 
+// Command returns the name of the command being handled, without the slash.
+func (c *Context) Command() string {
+	return c.command
+}
+
+// Subcommand returns the subcommand path being handled, e.g. "set" for
+// "/config set", or "" if the command has no subcommands.
+func (c *Context) Subcommand() string {
+	return c.subcommand
+}
+
 // Params returns the parsed command parameters.
 func (c *Context) Params() ParsedParams {
 	return c.params
@@ -158,58 +198,189 @@ func (c *Context) Param(key string) any {
 	return nil
 }
 
+// Args returns the positional command arguments (words that are not
+// key=value or --flag parameters).
+func (c *Context) Args() []string {
+	return c.args
+}
+
+// Body returns the command text after the first line that was not consumed
+// by a heredoc parameter.
+func (c *Context) Body() string {
+	return c.body
+}
+
 // API returns the raw tg.Client for advanced operations.
 func (c *Context) API() *tg.Client {
 	return c.bot.api
 }
 
-// Reply sends a reply to the current message.
-func (c *Context) Reply(text string) error {
+// Reply sends a reply to the current message. opts can attach a keyboard.
+func (c *Context) Reply(text string, opts ...SendOption) error {
+	return c.ReplyStyled(text, nil, opts...)
+}
+
+// Send sends a message to the current chat.
+func (c *Context) Send(text string, opts ...SendOption) error {
+	return c.SendStyled(text, nil, opts...)
+}
+
+// SendTo sends a message to a specific user ID.
+func (c *Context) SendTo(userID int64, text string, opts ...SendOption) error {
+	return c.SendToStyled(userID, text, nil, opts...)
+}
+
+// ReplyHTML replies to the current message with Telegram-style HTML.
+// See ParseHTML for the supported tags.
+func (c *Context) ReplyHTML(text string, opts ...SendOption) error {
+	msg, entities, err := ParseHTML(text)
+	if err != nil {
+		return err
+	}
+	return c.ReplyStyled(msg, entities, opts...)
+}
+
+// ReplyMarkdown replies to the current message with MarkdownV2.
+// See ParseMarkdown for the supported syntax.
+func (c *Context) ReplyMarkdown(text string, opts ...SendOption) error {
+	msg, entities, err := ParseMarkdown(text)
+	if err != nil {
+		return err
+	}
+	return c.ReplyStyled(msg, entities, opts...)
+}
+
+// ReplyStyled replies to the current message with text formatted by
+// entities. Entity offsets and lengths are in UTF-16 code units.
+func (c *Context) ReplyStyled(text string, entities []tg.MessageEntityClass, opts ...SendOption) error {
 	if c.message == nil {
 		return nil
 	}
-	sender := message.NewSender(c.bot.api)
-	_, err := sender.Reply(c.entities, c.updateToMessage()).Text(c, text)
+	_, err := c.bot.sendMessage(c, c.inputPeer(), c.message.ID, text, entities, opts)
 	return err
 }
 
-// Send sends a message to the current chat.
-func (c *Context) Send(text string) error {
+// SendHTML sends Telegram-style HTML to the current chat.
+func (c *Context) SendHTML(text string, opts ...SendOption) error {
+	msg, entities, err := ParseHTML(text)
+	if err != nil {
+		return err
+	}
+	return c.SendStyled(msg, entities, opts...)
+}
+
+// SendMarkdown sends MarkdownV2 to the current chat.
+func (c *Context) SendMarkdown(text string, opts ...SendOption) error {
+	msg, entities, err := ParseMarkdown(text)
+	if err != nil {
+		return err
+	}
+	return c.SendStyled(msg, entities, opts...)
+}
+
+// SendStyled sends text formatted by entities to the current chat.
+func (c *Context) SendStyled(text string, entities []tg.MessageEntityClass, opts ...SendOption) error {
 	if c.message == nil {
 		return nil
 	}
-	sender := message.NewSender(c.bot.api)
-	_, err := sender.To(c.inputPeer()).Text(c, text)
+	_, err := c.bot.sendMessage(c, c.inputPeer(), 0, text, entities, opts)
 	return err
 }
 
-// SendTo sends a message to a specific user ID.
-func (c *Context) SendTo(userID int64, text string) error {
-	sender := message.NewSender(c.bot.api)
-	_, err := sender.To(&tg.InputPeerUser{UserID: userID}).Text(c, text)
+// SendToHTML sends Telegram-style HTML to a specific user ID.
+func (c *Context) SendToHTML(userID int64, text string, opts ...SendOption) error {
+	msg, entities, err := ParseHTML(text)
+	if err != nil {
+		return err
+	}
+	return c.SendToStyled(userID, msg, entities, opts...)
+}
+
+// SendToMarkdown sends MarkdownV2 to a specific user ID.
+func (c *Context) SendToMarkdown(userID int64, text string, opts ...SendOption) error {
+	msg, entities, err := ParseMarkdown(text)
+	if err != nil {
+		return err
+	}
+	return c.SendToStyled(userID, msg, entities, opts...)
+}
+
+// SendToStyled sends text formatted by entities to a specific user ID.
+func (c *Context) SendToStyled(userID int64, text string, entities []tg.MessageEntityClass, opts ...SendOption) error {
+	peer := &tg.InputPeerUser{UserID: userID}
+	if u, ok := c.entities.Users[userID]; ok {
+		peer.AccessHash = u.AccessHash
+	}
+	_, err := c.bot.sendMessage(c, peer, 0, text, entities, opts)
 	return err
 }
 
+// sendMessage sends text with entities to peer, as a reply to the message
+// replyTo if it is not zero, and returns the ID of the sent message.
+func (b *Bot) sendMessage(ctx context.Context, peer tg.InputPeerClass, replyTo int, text string, entities []tg.MessageEntityClass, opts []SendOption) (int, error) {
+	o, err := applySendOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	req := &tg.MessagesSendMessageRequest{
+		Peer:     peer,
+		Message:  text,
+		Entities: entities,
+		RandomID: randomID(),
+	}
+	if replyTo != 0 {
+		req.ReplyTo = &tg.InputReplyToMessage{ReplyToMsgID: replyTo}
+	}
+	if o.markup != nil {
+		req.ReplyMarkup = o.markup
+	}
+	upd, err := b.api.MessagesSendMessage(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return sentMessageID(upd), nil
+}
+
+// randomID returns a random ID for deduplicating send requests.
+func randomID() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// inputPeer returns the chat of the current message as an input peer,
+// using the access hash from the update's entities.
 func (c *Context) inputPeer() tg.InputPeerClass {
 	if c.message == nil {
 		return nil
 	}
-	switch peer := c.message.PeerID.(type) {
+	return inputPeerOf(c.message.PeerID, c.entities)
+}
+
+// inputPeerOf converts peer to an input peer with the access hash from
+// entities.
+func inputPeerOf(peer tg.PeerClass, entities tg.Entities) tg.InputPeerClass {
+	switch peer := peer.(type) {
 	case *tg.PeerChannel:
-		return &tg.InputPeerChannel{ChannelID: peer.ChannelID}
+		p := &tg.InputPeerChannel{ChannelID: peer.ChannelID}
+		if ch, ok := entities.Channels[peer.ChannelID]; ok {
+			p.AccessHash = ch.AccessHash
+		}
+		return p
 	case *tg.PeerChat:
 		return &tg.InputPeerChat{ChatID: peer.ChatID}
 	case *tg.PeerUser:
-		return &tg.InputPeerUser{UserID: peer.UserID}
+		p := &tg.InputPeerUser{UserID: peer.UserID}
+		if u, ok := entities.Users[peer.UserID]; ok {
+			p.AccessHash = u.AccessHash
+		}
+		return p
 	}
 	return nil
 }
 
-// updateToMessage converts the update to a message interface for reply.
-func (c *Context) updateToMessage() *tg.UpdateNewMessage {
-	return &tg.UpdateNewMessage{Message: c.message}
-}
-
 // CallbackContext provides access to callback query data.
 type CallbackContext struct {
 	context.Context
@@ -220,6 +391,9 @@ type CallbackContext struct {
 	userID int64
 	msgID  int
 	chatID int64
+	params map[string]string // captured by a callback route
+
+	entities tg.Entities
 }
 
 // Query returns the raw callback query.
@@ -0,0 +1,154 @@
+package telekit
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AskOptions configures Context.Ask.
+type AskOptions struct {
+	// Timeout is how long to wait for the answer.
+	// Defaults to Config.ConversationTimeout if zero.
+	Timeout time.Duration
+
+	// Filter accepts or skips candidate answers, e.g. to wait for a document.
+	// Skipped messages are handled as usual. Nil accepts any message.
+	Filter func(ctx *Context) bool
+
+	// SendOptions apply to the prompt, e.g. a keyboard with suggested answers.
+	SendOptions []SendOption
+}
+
+// Ask sends prompt to the current chat and waits for the sender's answer.
+// See WaitMessage.
+func (c *Context) Ask(prompt string, opts AskOptions) (*Context, error) {
+	if err := c.Send(prompt, opts.SendOptions...); err != nil {
+		return nil, err
+	}
+	return c.WaitMessage(opts.Filter, opts.Timeout)
+}
+
+// WaitMessage suspends the command handler until the same user sends a
+// message in the same chat that passes filter, and returns a Context for
+// that message. The message is not passed to other handlers. Commands are
+// never consumed, so they keep working during a conversation.
+//
+// While waiting, the user holds the command lock: other locked commands of
+// the user are rejected, and unlocked ones are handled meanwhile.
+// WaitMessage returns ErrConversationTimeout after timeout
+// (Config.ConversationTimeout if zero) and the context error when the
+// invocation is cancelled. It is only available in command handlers.
+func (c *Context) WaitMessage(filter func(ctx *Context) bool, timeout time.Duration) (*Context, error) {
+	userID := c.SenderID()
+	if !c.async || userID == 0 {
+		return nil, ErrWaitNotSupported
+	}
+	if timeout <= 0 {
+		timeout = c.bot.config.ConversationTimeout
+	}
+
+	if !c.locked {
+		release, err := c.bot.locks.acquire(c, lockName("", userLockKey(userID)), LockOptions{}, nil)
+		if errors.Is(err, ErrCommandLocked) {
+			return nil, ErrConversationBusy
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	key := conversationKey{chatID: c.ChatID(), userID: userID}
+	w, ok := c.bot.conversations.add(key, filter)
+	if !ok {
+		return nil, ErrConversationBusy
+	}
+	defer c.bot.conversations.remove(key, w)
+
+	// a waiting handler does not take up a command slot
+	c.turn.release()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-w.replies:
+		reply.async = true
+		return reply, nil
+	case <-timer.C:
+		return nil, ErrConversationTimeout
+	case <-c.Done():
+		return nil, c.Err()
+	}
+}
+
+type conversationKey struct {
+	chatID int64
+	userID int64
+}
+
+type waiter struct {
+	filter  func(ctx *Context) bool
+	replies chan *Context
+}
+
+// conversations tracks handlers waiting for a user's next message.
+type conversations struct {
+	mu      sync.Mutex
+	waiters map[conversationKey]*waiter
+}
+
+func newConversations() *conversations {
+	return &conversations{
+		waiters: make(map[conversationKey]*waiter),
+	}
+}
+
+// add registers a waiter. It fails if one already waits for key.
+func (cs *conversations) add(key conversationKey, filter func(ctx *Context) bool) (*waiter, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.waiters[key]; ok {
+		return nil, false
+	}
+	w := &waiter{filter: filter, replies: make(chan *Context, 1)}
+	cs.waiters[key] = w
+	return w, true
+}
+
+func (cs *conversations) remove(key conversationKey, w *waiter) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.waiters[key] == w {
+		delete(cs.waiters, key)
+	}
+}
+
+// deliver hands the message of ctx to a matching waiter and reports
+// whether it was consumed.
+func (cs *conversations) deliver(ctx *Context) bool {
+	if ctx.SenderID() == 0 || strings.HasPrefix(ctx.Text(), "/") {
+		return false
+	}
+	key := conversationKey{chatID: ctx.ChatID(), userID: ctx.SenderID()}
+
+	cs.mu.Lock()
+	w, ok := cs.waiters[key]
+	cs.mu.Unlock()
+	if !ok || (w.filter != nil && !w.filter(ctx)) {
+		return false
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.waiters[key] != w {
+		return false
+	}
+	delete(cs.waiters, key)
+	w.replies <- ctx
+	return true
+}
@@ -0,0 +1,95 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func groupMessage(chatID, userID int64, text string) *Context {
+	return &Context{
+		Context: context.Background(),
+		message: &tg.Message{
+			PeerID:  &tg.PeerChat{ChatID: chatID},
+			FromID:  &tg.PeerUser{UserID: userID},
+			Message: text,
+		},
+	}
+}
+
+func TestConversationsDeliver(t *testing.T) {
+	cs := newConversations()
+	key := conversationKey{chatID: 1, userID: 10}
+	w, ok := cs.add(key, func(ctx *Context) bool { return ctx.Text() != "skip" })
+	if !ok {
+		t.Fatal("add() failed")
+	}
+	if _, ok := cs.add(key, nil); ok {
+		t.Error("add() succeeded for a key that is already waiting")
+	}
+
+	tests := []struct {
+		name string
+		ctx  *Context
+		want bool
+	}{
+		{"other user", groupMessage(1, 11, "hi"), false},
+		{"other chat", groupMessage(2, 10, "hi"), false},
+		{"command", groupMessage(1, 10, "/start"), false},
+		{"filtered", groupMessage(1, 10, "skip"), false},
+		{"answer", groupMessage(1, 10, "42"), true},
+		{"after answer", groupMessage(1, 10, "43"), false},
+	}
+	for _, tt := range tests {
+		if got := cs.deliver(tt.ctx); got != tt.want {
+			t.Errorf("%s: deliver() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if reply := <-w.replies; reply.Text() != "42" {
+		t.Errorf("reply = %q, want %q", reply.Text(), "42")
+	}
+}
+
+func TestWaitMessage(t *testing.T) {
+	b := &Bot{
+		config:        Config{ConversationTimeout: time.Second},
+		locks:         newTestLockManager(),
+		conversations: newConversations(),
+	}
+
+	ctx := groupMessage(1, 10, "/ask")
+	ctx.bot = b
+	if _, err := ctx.WaitMessage(nil, 0); !errors.Is(err, ErrWaitNotSupported) {
+		t.Errorf("WaitMessage() outside a command error = %v, want %v", err, ErrWaitNotSupported)
+	}
+
+	ctx.async = true
+	go func() {
+		for !b.conversations.deliver(groupMessage(1, 10, "answer")) {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	reply, err := ctx.WaitMessage(nil, 0)
+	if err != nil || reply.Text() != "answer" {
+		t.Fatalf("WaitMessage() = %v, %v", reply, err)
+	}
+	release, err := b.locks.acquire(ctx, "/user:10", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("command lock still held after WaitMessage returned: %v", err)
+	}
+
+	release()
+	if _, err := ctx.WaitMessage(nil, 10*time.Millisecond); !errors.Is(err, ErrConversationTimeout) {
+		t.Errorf("WaitMessage() error = %v, want %v", err, ErrConversationTimeout)
+	}
+
+	release, _ = b.locks.acquire(ctx, "/user:10", LockOptions{}, nil)
+	defer release()
+	if _, err := ctx.WaitMessage(nil, 0); !errors.Is(err, ErrConversationBusy) {
+		t.Errorf("WaitMessage() with busy lock error = %v, want %v", err, ErrConversationBusy)
+	}
+}
@@ -2,7 +2,10 @@ package telekit
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gotd/td/tg"
 )
@@ -17,11 +20,13 @@ func (b *Bot) registerDispatcherHandlers() {
 	})
 
 	b.dispatcher.OnNewMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewMessage) error {
-		msg, ok := u.Message.(*tg.Message)
-		if !ok {
-			return nil
+		switch msg := u.Message.(type) {
+		case *tg.Message:
+			return b.handleMessage(ctx, msg, u, e)
+		case *tg.MessageService:
+			return b.handleServiceMessage(ctx, msg, u, e)
 		}
-		return b.handleMessage(ctx, msg, u, e)
+		return nil
 	})
 
 	b.dispatcher.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditChannelMessage) error {
@@ -40,8 +45,8 @@ func (b *Bot) registerDispatcherHandlers() {
 		return b.handleEdit(ctx, msg, u, e)
 	})
 
-	b.dispatcher.OnBotCallbackQuery(func(ctx context.Context, _ tg.Entities, u *tg.UpdateBotCallbackQuery) error {
-		return b.handleCallback(ctx, u)
+	b.dispatcher.OnBotCallbackQuery(func(ctx context.Context, e tg.Entities, u *tg.UpdateBotCallbackQuery) error {
+		return b.handleCallback(ctx, u, e)
 	})
 
 	b.dispatcher.OnDeleteChannelMessages(func(ctx context.Context, _ tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
@@ -58,10 +63,6 @@ func (b *Bot) handleMessage(ctx context.Context, msg *tg.Message, update tg.Upda
 		return nil
 	}
 
-	if b.albumCollector.add(ctx, msg, entities) {
-		return nil
-	}
-
 	botCtx := &Context{
 		Context:  ctx,
 		bot:      b,
@@ -70,10 +71,38 @@ func (b *Bot) handleMessage(ctx context.Context, msg *tg.Message, update tg.Upda
 		entities: entities,
 	}
 
-	if strings.HasPrefix(msg.Message, "/") {
-		if err := b.handleCommand(botCtx); err != nil {
-			b.config.Logger.Error("command handler error", "error", err)
+	if b.throttle != nil && botCtx.SenderID() != 0 {
+		if ok, _ := b.throttle.allow(botCtx.SenderID(), time.Now()); !ok {
+			b.config.Logger.Debug("message dropped by throttle", "sender_id", botCtx.SenderID())
+			return nil
 		}
+	}
+
+	if !strings.HasPrefix(msg.Message, "/") {
+		// an answer goes to the command the sender sent before it
+		b.commands.wait(botCtx)
+	}
+	if b.conversations.deliver(botCtx) {
+		return nil
+	}
+
+	if b.albumCollector.add(ctx, msg, entities) {
+		return nil
+	}
+
+	if strings.HasPrefix(msg.Message, "/") {
+		// Commands run off the update loop so that handlers waiting for
+		// the user's next message do not block it; see commandRunner.
+		botCtx.async = true
+		b.commands.run(botCtx, func() {
+			if err := b.handleCommand(botCtx); err != nil {
+				b.config.Logger.Error("command handler error", "error", err)
+			}
+		})
+		return nil
+	}
+
+	if b.handleState(botCtx) {
 		return nil
 	}
 
@@ -118,14 +147,15 @@ func (b *Bot) handleEdit(ctx context.Context, msg *tg.Message, update tg.UpdateC
 
 func (b *Bot) handleCommand(ctx *Context) error {
 	text := ctx.Text()
-	parts := strings.Fields(text)
-	if len(parts) == 0 {
+	cmdName, mention := splitCommandName(text)
+	if cmdName == "" {
 		return nil
 	}
-
-	cmdName := strings.TrimPrefix(parts[0], "/")
-	if idx := strings.Index(cmdName, "@"); idx > 0 {
-		cmdName = cmdName[:idx]
+	if !commandAddressed(mention, b.selfUsername, ctx.IsPrivate(), b.config.RequireBotMention) {
+		b.config.Logger.Debug("command addressed to another bot",
+			"command", cmdName,
+			"mention", mention)
+		return nil
 	}
 
 	b.config.Logger.Debug("received command",
@@ -134,14 +164,14 @@ func (b *Bot) handleCommand(ctx *Context) error {
 		"chat_id", ctx.ChatID(),
 		"text", text)
 
-	userID := ctx.SenderID()
-
 	b.mu.RLock()
 	handlers := b.commandHandlers
 	b.mu.RUnlock()
 
+	found := false
 	for _, h := range handlers {
-		if h.name == cmdName {
+		if h.matchesName(cmdName, b.config.CaseInsensitiveCommands) {
+			found = true
 			if !h.filter.matches(ctx) {
 				b.config.Logger.Debug("command filter not matched",
 					"command", cmdName,
@@ -149,33 +179,281 @@ func (b *Bot) handleCommand(ctx *Context) error {
 					"filter_users", h.filter.Users)
 				continue
 			}
-
-			if userID != 0 {
-				if !b.commandLock.TryAcquire(userID, h.locked) {
-					b.config.Logger.Debug("command blocked by lock",
-						"command", cmdName,
-						"sender_id", userID)
-					return nil
-				}
-				if h.locked {
-					defer b.commandLock.Unlock(userID)
-				}
+			if !rolesAllow(h, ctx.Roles()) {
+				b.config.Logger.Debug("command requires role",
+					"command", cmdName,
+					"sender_id", ctx.SenderID(),
+					"roles", h.roles)
+				continue
 			}
 
-			params, err := parseParams(text, h.params)
-			if err != nil {
-				if ctx.SenderID() != 0 {
-					_ = ctx.SendTo(ctx.SenderID(), "Error: "+err.Error())
-				}
+			ctx.command = h.name
+			return b.runCommand(ctx, h)
+		}
+	}
+
+	// deep links work without a registered start command
+	start := commandHandler{name: "start"}
+	if !found && start.matchesName(cmdName, b.config.CaseInsensitiveCommands) {
+		if run := b.startPayloadHandler(ctx); run != nil {
+			ctx.command = "start"
+			return run()
+		}
+	}
+
+	return nil
+}
+
+// commandAddressed reports whether a command with the given @suffix is meant
+// for the bot with username self. In groups the suffix may be required.
+func commandAddressed(mention, self string, private, require bool) bool {
+	if mention == "" {
+		return private || !require
+	}
+	return self == "" || strings.EqualFold(mention, self)
+}
+
+// runCommand resolves subcommands of h, acquires the command lock, parses
+// and validates parameters and calls the handler.
+func (b *Bot) runCommand(ctx *Context, h commandHandler) error {
+	if !adminAllowed(ctx, h) {
+		b.replyAdminRequired(ctx, h)
+		return nil
+	}
+	// limits are only charged when the handler runs, see chargeCommandLimits
+	if ok, wait, notify := peekCommandLimits(ctx, h, time.Now()); !ok {
+		b.replyRateLimited(ctx, h, wait, notify)
+		return nil
+	}
+
+	// a /start deep link payload replaces the start command's handler
+	var startPayload func() error
+	if h.matchesName("start", b.config.CaseInsensitiveCommands) {
+		startPayload = b.startPayloadHandler(ctx)
+	}
+
+	// Subcommand words are plain, so a lenient split finds the node. Only
+	// commands that declare parameters are tokenized shell-style; others
+	// keep their words as typed, like "/say don't".
+	tokens, body := splitFields(ctx.Text())
+	node, depth := h, 0
+	if startPayload == nil {
+		var err error
+		node, depth, err = resolveSubcommand(ctx, h, tokens[1:], b.config.CaseInsensitiveCommands)
+		if err != nil {
+			b.replyParamError(ctx, node, err)
+			return nil
+		}
+		if len(node.params) > 0 {
+			if tokens, body, err = tokenize(ctx.Text()); err != nil {
+				b.replyParamError(ctx, node, err)
 				return nil
 			}
-			ctx.params = params
+		}
+	}
+	ctx.subcommand = strings.TrimPrefix(strings.TrimPrefix(node.path, h.path), " ")
+
+	var (
+		invocation context.Context
+		cancel     context.CancelFunc
+	)
+	if h.timeout > 0 {
+		invocation, cancel = context.WithTimeout(ctx.Context, h.timeout)
+	} else {
+		invocation, cancel = context.WithCancel(ctx.Context)
+	}
+	defer cancel()
+	ctx.Context = invocation
+
+	ctx.invocationID = b.invocations.add(Invocation{
+		Command: node.path,
+		UserID:  ctx.SenderID(),
+		ChatID:  ctx.ChatID(),
+		Started: time.Now(),
+	}, cancel)
+	defer b.invocations.remove(ctx.invocationID)
+
+	opts := node.lock
+	if opts == nil {
+		opts = h.lock
+	}
+	if opts != nil {
+		release, err := b.acquireLock(ctx, node, *opts, cancel)
+		if err != nil {
+			return nil
+		}
+		if release != nil {
+			b.invocations.setRelease(ctx.invocationID, release)
+			defer release()
+		}
+	}
+
+	// the sender's next command may be dispatched while this one runs
+	ctx.turn.dispatch()
 
-			return h.fn(ctx)
+	if startPayload != nil {
+		if !b.chargeCommandLimits(ctx, h) {
+			return nil
 		}
+		return startPayload()
 	}
 
-	return nil
+	cmd, err := parseTokens(tokens, body, depth, node.params)
+	if err != nil {
+		b.replyParamError(ctx, node, err)
+		return nil
+	}
+	if err := b.resolvePeerParams(ctx, cmd.params); err != nil {
+		b.replyParamError(ctx, node, err)
+		return nil
+	}
+	if err := validateParams(cmd.params, cmd.raw, node.params, node.validators); err != nil {
+		b.replyParamError(ctx, node, err)
+		return nil
+	}
+	if !b.chargeCommandLimits(ctx, h) {
+		return nil
+	}
+	ctx.params = cmd.params
+	ctx.args = cmd.args
+	ctx.body = cmd.body
+
+	err = node.fn(ctx)
+	var berr *bindError
+	if errors.As(err, &berr) {
+		b.replyParamError(ctx, node, berr.err)
+		return nil
+	}
+	return err
+}
+
+// acquireLock takes the command lock selected by opts. It tells the sender
+// if the lock is taken; the returned release func is nil if the
+// invocation needs no lock.
+func (b *Bot) acquireLock(ctx *Context, h commandHandler, opts LockOptions, cancel context.CancelFunc) (func(), error) {
+	keyFunc := opts.Key
+	if keyFunc == nil {
+		keyFunc = LockByUser
+	}
+	key := keyFunc(ctx)
+	if key == "" {
+		return nil, nil
+	}
+
+	// a queued invocation must not hold up the sender's next commands
+	release, err := b.locks.acquireWaiting(ctx, lockName(opts.Group, key), opts, cancel, ctx.turn.dispatch)
+	if errors.Is(err, ErrCommandLocked) {
+		b.config.Logger.Debug("command blocked by lock",
+			"command", h.path,
+			"sender_id", ctx.SenderID(),
+			"lock", key)
+		m := b.messagesFor(ctx.LangCode())
+		b.sendErrorReply(ctx, renderMessage(m.CommandLocked, map[string]string{"Command": h.path}))
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the conversation lock is the user's lock in the default group
+	ctx.locked = opts.Group == "" && key == userLockKey(ctx.SenderID())
+	return release, nil
+}
+
+// resolveSubcommand walks the subcommand tree of h using the leading plain
+// words of tokens. It returns the selected node and the number of words used.
+// A node with subcommands but no handler requires a subcommand.
+func resolveSubcommand(ctx *Context, h commandHandler, tokens []token, foldCase bool) (commandHandler, int, error) {
+	node := h
+	depth := 0
+	for len(node.subcommands) > 0 {
+		var word string
+		if depth < len(tokens) {
+			tok := tokens[depth]
+			if !tok.isFlag() && !tok.isTerminator() && (tok.eq < 0 || tok.startQuoted) {
+				word = tok.value
+			}
+		}
+
+		if word == "" {
+			if node.fn != nil {
+				return node, depth, nil
+			}
+			return node, depth, ValidationErrors{{
+				Kind: ValidationSubcommand,
+				Msg:  fmt.Sprintf("command /%s requires a subcommand", node.path),
+			}}
+		}
+
+		next, ok := findSubcommand(ctx, node, word, foldCase)
+		if !ok {
+			return node, depth, ValidationErrors{{
+				Kind:  ValidationSubcommand,
+				Value: word,
+				Msg:   fmt.Sprintf("unknown subcommand %q of /%s", word, node.path),
+			}}
+		}
+		node = next
+		depth++
+	}
+
+	if node.fn == nil {
+		return node, depth, ValidationErrors{{
+			Kind: ValidationSubcommand,
+			Msg:  fmt.Sprintf("command /%s has no handler", node.path),
+		}}
+	}
+	return node, depth, nil
+}
+
+// findSubcommand returns the subcommand of h with the given name or alias
+// whose filter matches ctx.
+func findSubcommand(ctx *Context, h commandHandler, name string, foldCase bool) (commandHandler, bool) {
+	for _, sub := range h.subcommands {
+		if sub.matchesName(name, foldCase) && sub.filter.matches(ctx) {
+			return sub, true
+		}
+	}
+	return commandHandler{}, false
+}
+
+// replyParamError tells the sender why the command was rejected, followed
+// by the command's usage text, according to Config.ErrorReply.
+func (b *Bot) replyParamError(ctx *Context, h commandHandler, err error) {
+	b.config.Logger.Debug("command rejected",
+		"command", h.name,
+		"sender_id", ctx.SenderID(),
+		"error", err)
+
+	if b.onParamError != nil {
+		if err := b.onParamError(ctx, err); err != nil {
+			b.config.Logger.Error("param error handler error", "error", err)
+		}
+		return
+	}
+
+	m := b.messagesFor(ctx.LangCode())
+	b.sendErrorReply(ctx, ctx.ErrorText(err)+"\n\n"+commandUsage(ctx, m, h))
+}
+
+// sendErrorReply sends a command error to the sender according to
+// Config.ErrorReply.
+func (b *Bot) sendErrorReply(ctx *Context, text string) {
+	var sendErr error
+	switch b.config.ErrorReply {
+	case ErrorReplySilent:
+		return
+	case ErrorReplyPrivate:
+		if ctx.SenderID() == 0 {
+			return
+		}
+		sendErr = ctx.SendTo(ctx.SenderID(), text)
+	default:
+		sendErr = ctx.Reply(text)
+	}
+	if sendErr != nil {
+		b.config.Logger.Warn("failed to send command error", "error", sendErr)
+	}
 }
 
 func (b *Bot) handleAlbum(ctx context.Context, messages []*tg.Message, entities tg.Entities) {
@@ -204,7 +482,7 @@ func (b *Bot) handleAlbum(ctx context.Context, messages []*tg.Message, entities
 	}
 }
 
-func (b *Bot) handleCallback(ctx context.Context, query *tg.UpdateBotCallbackQuery) error {
+func (b *Bot) handleCallback(ctx context.Context, query *tg.UpdateBotCallbackQuery, entities tg.Entities) error {
 	data := string(query.Data)
 
 	var chatID int64
@@ -222,27 +500,52 @@ func (b *Bot) handleCallback(ctx context.Context, query *tg.UpdateBotCallbackQue
 	msgID = query.MsgID
 
 	cbCtx := &CallbackContext{
-		Context: ctx,
-		bot:     b,
-		query:   query,
-		data:    data,
-		userID:  query.UserID,
-		msgID:   msgID,
-		chatID:  chatID,
+		Context:  ctx,
+		bot:      b,
+		query:    query,
+		data:     data,
+		userID:   query.UserID,
+		msgID:    msgID,
+		chatID:   chatID,
+		entities: entities,
 	}
 
 	b.mu.RLock()
 	handlers := b.callbackHandlers
+	routes := b.callbackRoutes
 	b.mu.RUnlock()
 
+	matched := false
 	for _, h := range handlers {
 		if h.filter.matches(cbCtx) {
+			matched = true
 			if err := h.fn(cbCtx); err != nil {
 				b.config.Logger.Error("callback handler error", "error", err)
 			}
 		}
 	}
 
+	// routes never overlap, so at most one matches
+	for _, r := range routes {
+		params, ok := r.match(data)
+		if !ok {
+			continue
+		}
+		matched = true
+		cbCtx.params = params
+		if err := r.fn(cbCtx); err != nil {
+			b.config.Logger.Error("callback handler error", "route", r.pattern, "error", err)
+		}
+		break
+	}
+
+	// answer unhandled queries so the client stops showing a spinner
+	if !matched {
+		if err := cbCtx.AnswerEmpty(); err != nil {
+			b.config.Logger.Debug("failed to answer unhandled callback", "data", data, "error", err)
+		}
+	}
+
 	return nil
 }
 
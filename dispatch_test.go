@@ -0,0 +1,244 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestResolveSubcommand(t *testing.T) {
+	noop := func(*Context) error { return nil }
+	root := commandHandler{
+		name: "config",
+		path: "config",
+		subcommands: newSubcommands("config", []SubcommandDef{
+			{Name: "get", Handler: noop},
+			{Name: "set", Handler: noop, Params: Params{"key": {Type: TypeString}}},
+			{Name: "admin", Handler: noop, Filter: Filter{Users: []int64{1}}},
+			{Name: "user", Subcommands: []SubcommandDef{
+				{Name: "add", Handler: noop},
+			}},
+		}),
+	}
+	ctx := &Context{message: &tg.Message{PeerID: &tg.PeerUser{UserID: 2}}}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantPath  string
+		wantDepth int
+		wantValue string // subcommand error value, "-" for no error
+	}{
+		{"leaf", "/config set key=x", "config set", 1, "-"},
+		{"nested", "/config user add", "config user add", 2, "-"},
+		{"missing", "/config", "config", 0, ""},
+		{"named arg is not a subcommand", "/config key=x", "config", 0, ""},
+		{"unknown", "/config drop", "config", 0, "drop"},
+		{"filtered out", "/config admin", "config", 0, "admin"},
+		{"missing nested", "/config user", "config user", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, _, err := tokenize(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, depth, err := resolveSubcommand(ctx, root, tokens[1:], false)
+			if node.path != tt.wantPath || depth != tt.wantDepth {
+				t.Errorf("resolveSubcommand() = %q, %d; want %q, %d", node.path, depth, tt.wantPath, tt.wantDepth)
+			}
+
+			if tt.wantValue == "-" {
+				if err != nil {
+					t.Errorf("resolveSubcommand() error = %v", err)
+				}
+				return
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || errs[0].Kind != ValidationSubcommand || errs[0].Value != tt.wantValue {
+				t.Errorf("resolveSubcommand() error = %#v, want subcommand error %q", err, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestResolveSubcommandParentHandler(t *testing.T) {
+	root := commandHandler{
+		name: "list",
+		path: "list",
+		fn:   func(*Context) error { return nil },
+		subcommands: newSubcommands("list", []SubcommandDef{
+			{Name: "all", Handler: func(*Context) error { return nil }},
+		}),
+	}
+	tokens, _, _ := tokenize("/list --limit 5")
+	node, depth, err := resolveSubcommand(&Context{}, root, tokens[1:], false)
+	if err != nil || node.path != "list" || depth != 0 {
+		t.Errorf("resolveSubcommand() = %q, %d, %v", node.path, depth, err)
+	}
+}
+
+func TestMenuDescription(t *testing.T) {
+	h := commandHandler{
+		description: "Manage settings",
+		subcommands: newSubcommands("config", []SubcommandDef{{Name: "get"}, {Name: "set"}}),
+	}
+	if got, want := menuDescription(h), "Manage settings (get, set)"; got != want {
+		t.Errorf("menuDescription() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchesName(t *testing.T) {
+	h := commandHandler{name: "start", aliases: []string{"s", "begin"}}
+	tests := []struct {
+		name     string
+		foldCase bool
+		want     bool
+	}{
+		{"start", false, true},
+		{"s", false, true},
+		{"begin", false, true},
+		{"Start", false, false},
+		{"Start", true, true},
+		{"BEGIN", true, true},
+		{"stop", true, false},
+	}
+	for _, tt := range tests {
+		if got := h.matchesName(tt.name, tt.foldCase); got != tt.want {
+			t.Errorf("matchesName(%q, %v) = %v, want %v", tt.name, tt.foldCase, got, tt.want)
+		}
+	}
+}
+
+func TestCommandAddressed(t *testing.T) {
+	tests := []struct {
+		name    string
+		mention string
+		private bool
+		require bool
+		want    bool
+	}{
+		{"no suffix", "", false, false, true},
+		{"own suffix", "MyBot", false, false, true},
+		{"other bot", "otherbot", false, false, false},
+		{"required in group", "", false, true, false},
+		{"required with suffix", "mybot", false, true, true},
+		{"not required in private", "", true, true, true},
+		{"other bot in private", "otherbot", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandAddressed(tt.mention, "mybot", tt.private, tt.require); got != tt.want {
+				t.Errorf("commandAddressed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommandTokenizing(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		params   Params
+		wantArgs []string
+		wantRun  bool
+	}{
+		{"unbalanced quote without schema", "/say don't", nil, []string{"don't"}, true},
+		{"quotes kept without schema", `/say "a b"`, nil, []string{`"a`, `b"`}, true},
+		{"quotes parsed with schema", `/say "a b" n=1`, Params{"n": {Type: TypeInt}}, []string{"a b"}, true},
+		{"unbalanced quote with schema", "/say don't", Params{"n": {Type: TypeInt}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{invocations: newInvocationRegistry(), config: Config{Logger: slog.Default(), ErrorReply: ErrorReplySilent}}
+			ran := false
+			var args []string
+			h := commandHandler{name: "say", path: "say", params: tt.params, fn: func(ctx *Context) error {
+				ran = true
+				args = ctx.Args()
+				return nil
+			}}
+			ctx := &Context{
+				Context: context.Background(),
+				bot:     b,
+				message: &tg.Message{Message: tt.text, PeerID: &tg.PeerUser{UserID: 1}},
+			}
+			if err := b.runCommand(ctx, h); err != nil {
+				t.Fatalf("runCommand() error = %v", err)
+			}
+			if ran != tt.wantRun || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("runCommand() ran = %v, args = %q; want %v, %q", ran, args, tt.wantRun, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func newDispatchTestBot(invoker *recordingInvoker) *Bot {
+	b := &Bot{
+		api:           tg.NewClient(invoker),
+		config:        Config{Logger: slog.Default(), RoleStore: NewMemoryRoleStore(), ConversationTimeout: time.Second},
+		locks:         newTestLockManager(),
+		conversations: newConversations(),
+		invocations:   newInvocationRegistry(),
+	}
+	b.commands = newCommandRunner(8, &b.workers)
+	return b
+}
+
+// sendTestMessage passes a message of userID in a group chat to the bot.
+func sendTestMessage(t *testing.T, b *Bot, userID int64, text string) {
+	t.Helper()
+	msg := &tg.Message{
+		PeerID:  &tg.PeerChat{ChatID: 1},
+		FromID:  &tg.PeerUser{UserID: userID},
+		Message: text,
+	}
+	if err := b.handleMessage(context.Background(), msg, nil, tg.Entities{}); err != nil {
+		t.Fatalf("handleMessage(%q) error = %v", text, err)
+	}
+}
+
+// waitSent waits until the bot has sent text.
+func waitSent(t *testing.T, invoker *recordingInvoker, text string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !slices.Contains(invoker.sentTexts(), text) {
+		if time.Now().After(deadline) {
+			t.Fatalf("bot sent %q, want %q", invoker.sentTexts(), text)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandleMessageCancelWhileRunning(t *testing.T) {
+	invoker := &recordingInvoker{}
+	b := newDispatchTestBot(invoker)
+
+	started := make(chan *Context, 1)
+	finish := make(chan struct{})
+	b.CommandWithDesc(CommandDef{Name: "export", Locked: true}, func(ctx *Context) error {
+		started <- ctx
+		<-ctx.Done()
+		<-finish
+		return nil
+	})
+	b.CommandWithDesc(CommandDef{Name: "cancel"}, b.handleCancel)
+
+	sendTestMessage(t, b, 10, "/export")
+	export := <-started
+	sendTestMessage(t, b, 10, "/cancel")
+
+	waitSent(t, invoker, DefaultMessages().Cancelled)
+	if export.Err() == nil {
+		t.Error("running command was not cancelled")
+	}
+	close(finish)
+	b.workers.Wait()
+}
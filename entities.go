@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/gotd/td/tg"
 )
@@ -28,13 +29,15 @@ type tagEvent struct {
 }
 
 // EntitiesToHTML converts Telegram message entities to HTML.
-// Properly handles overlapping/nested entities.
+// Properly handles overlapping/nested entities. Entity offsets are in
+// UTF-16 code units, as sent by Telegram and returned by ParseHTML.
 func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 	if len(entities) == 0 {
 		return html.EscapeString(text)
 	}
 
 	runes := []rune(text)
+	span := utf16Span(runes)
 
 	var infos []*entityInfo
 	for i, entity := range entities {
@@ -43,16 +46,16 @@ func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 
 		switch e := entity.(type) {
 		case *tg.MessageEntityBold:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<strong>", "</strong>"
 		case *tg.MessageEntityItalic:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<em>", "</em>"
 		case *tg.MessageEntityCode:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<code>", "</code>"
 		case *tg.MessageEntityPre:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			if offset < 0 || offset+length > len(runes) {
 				continue
 			}
@@ -73,13 +76,13 @@ func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 			})
 			continue
 		case *tg.MessageEntityStrike:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<s>", "</s>"
 		case *tg.MessageEntityUnderline:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<u>", "</u>"
 		case *tg.MessageEntityBlockquote:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			if offset < 0 || offset+length > len(runes) {
 				continue
 			}
@@ -98,18 +101,26 @@ func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 			})
 			continue
 		case *tg.MessageEntitySpoiler:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag, endTag = "<span class=\"spoiler\">", "</span>"
 		case *tg.MessageEntityTextURL:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag = "<a href=\"" + html.EscapeString(e.URL) + "\">"
 			endTag = "</a>"
 		case *tg.MessageEntityMentionName:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			startTag = "<a href=\"tg://user?id=" + strconv.FormatInt(e.UserID, 10) + "\">"
 			endTag = "</a>"
+		case *tg.InputMessageEntityMentionName:
+			offset, length = span(e.Offset, e.Length)
+			user, ok := e.UserID.(*tg.InputUser)
+			if !ok {
+				continue
+			}
+			startTag = "<a href=\"tg://user?id=" + strconv.FormatInt(user.UserID, 10) + "\">"
+			endTag = "</a>"
 		case *tg.MessageEntityMention:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			if offset < 0 || offset+length > len(runes) {
 				continue
 			}
@@ -117,7 +128,7 @@ func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 			startTag = "<a href=\"https://t.me/" + username + "\">"
 			endTag = "</a>"
 		case *tg.MessageEntityURL:
-			offset, length = e.Offset, e.Length
+			offset, length = span(e.Offset, e.Length)
 			if offset < 0 || offset+length > len(runes) {
 				continue
 			}
@@ -302,3 +313,26 @@ func EntitiesToHTML(text string, entities []tg.MessageEntityClass) string {
 
 	return result.String()
 }
+
+// utf16Span returns a func converting UTF-16 based entity offsets and
+// lengths to rune offsets and lengths in runes. Spans outside the text or
+// splitting a surrogate pair yield a negative offset.
+func utf16Span(runes []rune) func(offset, length int) (int, int) {
+	// runeAt[i] is the rune index at UTF-16 offset i, -1 inside a pair
+	runeAt := make([]int, 0, len(runes)+1)
+	for i, r := range runes {
+		runeAt = append(runeAt, i)
+		if utf16.RuneLen(r) == 2 {
+			runeAt = append(runeAt, -1)
+		}
+	}
+	runeAt = append(runeAt, len(runes))
+
+	return func(offset, length int) (int, int) {
+		end := offset + length
+		if offset < 0 || length < 0 || end >= len(runeAt) || runeAt[offset] < 0 || runeAt[end] < 0 {
+			return -1, 0
+		}
+		return runeAt[offset], runeAt[end] - runeAt[offset]
+	}
+}
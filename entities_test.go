@@ -628,6 +628,39 @@ func TestEntitiesToHTML_EmojiOffsets(t *testing.T) {
 	}
 }
 
+func TestEntitiesToHTML_AstralPlaneOffsets(t *testing.T) {
+	// emoji outside the BMP take two UTF-16 code units each
+	text := "👋 hi 🌍 there"
+	tests := []struct {
+		name     string
+		entities []tg.MessageEntityClass
+		expected string
+	}{
+		{
+			"after and on emoji",
+			[]tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 3, Length: 2},   // "hi"
+				&tg.MessageEntityItalic{Offset: 6, Length: 2}, // "🌍"
+			},
+			"👋 <strong>hi</strong> <em>🌍</em> there",
+		},
+		{
+			"inside a surrogate pair",
+			[]tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 1, Length: 3},
+			},
+			text,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EntitiesToHTML(text, tt.entities); result != tt.expected {
+				t.Errorf("Astral plane offsets failed\nExpected: %s\nGot:      %s", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestEntitiesToHTML_TrailingWhitespaceTrimming(t *testing.T) {
 	// Telegram's markdown parser often includes trailing spaces in entity boundaries
 	text := "Hello bold text and more"
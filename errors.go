@@ -14,3 +14,46 @@ var (
 	ErrBotNotRunning  = errors.New("telekit: bot is not running")
 	ErrAlreadyRunning = errors.New("telekit: bot is already running")
 )
+
+// Command errors
+var (
+	ErrCommandLocked = errors.New("telekit: command is locked by a running invocation")
+	ErrLockLost      = errors.New("telekit: command lock lease was lost")
+
+	ErrInvalidParamSchema = errors.New("telekit: invalid parameter schema")
+)
+
+// Message errors
+var (
+	ErrNoMessage       = errors.New("telekit: context has no message")
+	ErrInvalidMarkup   = errors.New("telekit: invalid markup")
+	ErrInvalidKeyboard = errors.New("telekit: invalid keyboard")
+)
+
+// Callback route errors
+var (
+	ErrInvalidRoute   = errors.New("telekit: invalid callback route")
+	ErrAmbiguousRoute = errors.New("telekit: ambiguous callback route")
+)
+
+// Conversation errors
+var (
+	ErrConversationTimeout = errors.New("telekit: timed out waiting for a message")
+	ErrConversationBusy    = errors.New("telekit: user is busy with another command")
+	ErrWaitNotSupported    = errors.New("telekit: waiting for messages is only supported in command handlers")
+)
+
+// Start link errors
+var (
+	ErrStartPayloadTooLong = errors.New("telekit: start payload is too long")
+	ErrInvalidStartPayload = errors.New("telekit: invalid start payload")
+	ErrStartPayloadExpired = errors.New("telekit: start payload has expired")
+)
+
+// Callback data errors
+var (
+	ErrCallbackDataTooLong  = errors.New("telekit: callback data is too long")
+	ErrInvalidCallbackData  = errors.New("telekit: invalid callback data")
+	ErrCallbackDataExpired  = errors.New("telekit: callback data has expired")
+	ErrCallbackDataOutdated = errors.New("telekit: callback data has an outdated version")
+)
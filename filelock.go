@@ -0,0 +1,137 @@
+package telekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLock keeps command locks in a directory, so bot processes on one
+// host can share them without a database. Every operation takes an
+// exclusive lock on a file in the directory and keeps the leases in a JSON
+// file next to it. Locks of crashed processes expire like other leases.
+type FileLock struct {
+	lockPath   string
+	leasesPath string
+}
+
+type fileLease struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"` // UnixNano
+}
+
+// NewFileLock creates dir if needed and returns a lock store using it.
+// It fails with errors.ErrUnsupported on platforms without file locks.
+func NewFileLock(dir string) (*FileLock, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	l := &FileLock{
+		lockPath:   filepath.Join(dir, "locks.lock"),
+		leasesPath: filepath.Join(dir, "locks.json"),
+	}
+	if err := l.update(func(map[string]fileLease, int64) bool { return false }); err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	return l, nil
+}
+
+func (l *FileLock) Acquire(_ context.Context, name string, ttl time.Duration) (string, bool, error) {
+	var token string
+	err := l.update(func(leases map[string]fileLease, now int64) bool {
+		if lease, ok := leases[name]; ok && now < lease.ExpiresAt {
+			return false
+		}
+		token = newLockToken()
+		leases[name] = fileLease{Token: token, ExpiresAt: now + int64(ttl)}
+		return true
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	return token, token != "", nil
+}
+
+func (l *FileLock) Refresh(_ context.Context, name, token string, ttl time.Duration) error {
+	lost := false
+	err := l.update(func(leases map[string]fileLease, now int64) bool {
+		lease, ok := leases[name]
+		if !ok || lease.Token != token || now >= lease.ExpiresAt {
+			lost = true
+			return false
+		}
+		lease.ExpiresAt = now + int64(ttl)
+		leases[name] = lease
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refresh lock: %w", err)
+	}
+	if lost {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *FileLock) Release(_ context.Context, name, token string) error {
+	err := l.update(func(leases map[string]fileLease, _ int64) bool {
+		if lease, ok := leases[name]; ok && lease.Token == token {
+			delete(leases, name)
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// update runs fn on the leases while holding the file lock and writes them
+// back if fn reports a change. Expired leases are dropped on write.
+func (l *FileLock) update(fn func(leases map[string]fileLease, now int64) bool) error {
+	f, err := os.OpenFile(l.lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	leases := make(map[string]fileLease)
+	data, err := os.ReadFile(l.leasesPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &leases); err != nil {
+			return fmt.Errorf("failed to parse lock file: %w", err)
+		}
+	}
+
+	now := time.Now().UnixNano()
+	if !fn(leases, now) {
+		return nil
+	}
+	for name, lease := range leases {
+		if now >= lease.ExpiresAt {
+			delete(leases, name)
+		}
+	}
+
+	data, err = json.Marshal(leases)
+	if err != nil {
+		return err
+	}
+	tmp := l.leasesPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.leasesPath)
+}
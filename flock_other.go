@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package telekit
+
+import (
+	"errors"
+	"os"
+)
+
+func lockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
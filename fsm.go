@@ -0,0 +1,248 @@
+package telekit
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+)
+
+// StateDef defines a dialog state and its handler.
+type StateDef struct {
+	// Name is the state name used with Context.SetState.
+	Name string
+
+	// Timeout clears the state if the user does not move on within this
+	// duration. Expired states are cleared when the user's next message
+	// arrives, and by a periodic sweep (see Config.StateSweepInterval) if
+	// the state store implements StateSweeper, as all built-in stores do.
+	// Zero means no timeout.
+	Timeout time.Duration
+
+	// OnTimeout is called after the state timed out and was cleared,
+	// e.g. to tell the user the dialog was abandoned. It runs with the
+	// bot's context from the sweep, or with the context of the user's next
+	// message. With a StateSweeper store it runs once per timed-out state.
+	OnTimeout StateTimeoutFunc
+
+	// Filter restricts which messages the handler receives in this state.
+	Filter Filter
+}
+
+// StateTimeoutFunc handles a dialog state that timed out.
+type StateTimeoutFunc func(ctx context.Context, key StateKey, state State) error
+
+type stateHandler struct {
+	def StateDef
+	fn  HandlerFunc
+}
+
+// OnState registers a handler for non-command messages from users in the
+// given dialog state. A message handled by a state handler is not passed to
+// OnMessage handlers. Commands keep working in every state.
+func (b *Bot) OnState(def StateDef, fn HandlerFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stateHandlers = append(b.stateHandlers, stateHandler{def: def, fn: fn})
+}
+
+// handleState runs the first state handler matching the sender's state.
+// It reports whether a handler ran.
+func (b *Bot) handleState(ctx *Context) bool {
+	b.mu.RLock()
+	handlers := b.stateHandlers
+	b.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return false
+	}
+	name := ctx.State()
+	if name == "" {
+		return false
+	}
+
+	for _, h := range handlers {
+		if h.def.Name == name && h.def.Filter.matches(ctx) {
+			if err := h.fn(ctx); err != nil {
+				b.config.Logger.Error("state handler error", "state", name, "error", err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// stateTimeout returns the timeout registered for a state.
+func (b *Bot) stateTimeout(name string) time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, h := range b.stateHandlers {
+		if h.def.Name == name && h.def.Timeout > 0 {
+			return h.def.Timeout
+		}
+	}
+	return 0
+}
+
+// stateTimedOut runs the OnTimeout hook of an expired state.
+func (b *Bot) stateTimedOut(ctx context.Context, key StateKey, state State) {
+	b.mu.RLock()
+	var fn StateTimeoutFunc
+	for _, h := range b.stateHandlers {
+		if h.def.Name == state.Name && h.def.OnTimeout != nil {
+			fn = h.def.OnTimeout
+			break
+		}
+	}
+	b.mu.RUnlock()
+
+	if fn == nil {
+		return
+	}
+	if err := fn(ctx, key, state); err != nil {
+		b.config.Logger.Error("state timeout handler error", "state", state.Name, "error", err)
+	}
+}
+
+// sweepStates clears expired states every Config.StateSweepInterval until
+// ctx is done, if the state store supports it.
+func (b *Bot) sweepStates(ctx context.Context) {
+	sweeper, ok := b.config.StateStore.(StateSweeper)
+	if !ok || b.config.StateSweepInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.config.StateSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		expired, err := sweeper.TakeExpired(ctx, time.Now())
+		if err != nil {
+			b.config.Logger.Warn("failed to sweep dialog states", "error", err)
+			continue
+		}
+		for key, state := range expired {
+			b.stateTimedOut(ctx, key, state)
+		}
+	}
+}
+
+// clearExpiredState removes an expired state and reports whether this call
+// removed it. Stores that are not a StateSweeper cannot tell, so the state
+// counts as removed.
+func clearExpiredState(ctx context.Context, store StateStore, key StateKey, state State) (bool, error) {
+	if sweeper, ok := store.(StateSweeper); ok {
+		return sweeper.DeleteExpired(ctx, key, state.ExpiresAt)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Context) stateKey() StateKey {
+	return StateKey{ChatID: c.ChatID(), UserID: c.SenderID()}
+}
+
+// loadState returns the sender's dialog state, clearing it if expired.
+func (c *Context) loadState() State {
+	if c.state != nil {
+		return *c.state
+	}
+
+	var state State
+	if c.SenderID() != 0 {
+		store := c.bot.config.StateStore
+		stored, ok, err := store.Get(c, c.stateKey())
+		switch {
+		case err != nil:
+			c.bot.config.Logger.Warn("failed to load dialog state", "error", err)
+		case ok && stored.expired(time.Now()):
+			cleared, err := clearExpiredState(c, store, c.stateKey(), stored)
+			if err != nil {
+				c.bot.config.Logger.Warn("failed to clear expired dialog state", "error", err)
+				break
+			}
+			// the sweep or another process may have cleared it first
+			if cleared {
+				c.bot.stateTimedOut(c, c.stateKey(), stored)
+			}
+		case ok:
+			state = stored
+		}
+	}
+	c.state = &state
+	return state
+}
+
+// State returns the name of the sender's dialog state in the current chat,
+// or "" if there is none.
+func (c *Context) State() string {
+	return c.loadState().Name
+}
+
+// StateData returns a copy of the data stored with the sender's dialog state.
+func (c *Context) StateData() map[string]string {
+	data := maps.Clone(c.loadState().Data)
+	if data == nil {
+		data = make(map[string]string)
+	}
+	return data
+}
+
+// SetState moves the sender to the named state, keeping the state data.
+// The timeout of the state's StateDef starts anew.
+func (c *Context) SetState(name string) error {
+	state := c.loadState()
+	state.Name = name
+	state.ExpiresAt = time.Time{}
+	if timeout := c.bot.stateTimeout(name); timeout > 0 {
+		state.ExpiresAt = time.Now().Add(timeout)
+	}
+	return c.saveState(state)
+}
+
+// SetStateData stores a value with the sender's dialog state.
+func (c *Context) SetStateData(key, value string) error {
+	state := c.loadState()
+	state.Data = maps.Clone(state.Data)
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+	state.Data[key] = value
+	return c.saveState(state)
+}
+
+// ClearState ends the sender's dialog and removes its data.
+func (c *Context) ClearState() error {
+	if c.SenderID() == 0 {
+		return nil
+	}
+	if err := c.bot.config.StateStore.Delete(c, c.stateKey()); err != nil {
+		return err
+	}
+	c.state = &State{}
+	return nil
+}
+
+func (c *Context) saveState(state State) error {
+	if c.SenderID() == 0 {
+		return nil
+	}
+	if err := c.bot.config.StateStore.Set(c, c.stateKey(), state); err != nil {
+		return err
+	}
+	c.state = &state
+	return nil
+}
+
+// inState reports whether the sender's dialog state is one of states.
+func (c *Context) inState(states []string) bool {
+	return slices.Contains(states, c.State())
+}
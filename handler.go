@@ -3,6 +3,7 @@ package telekit
 import (
 	"slices"
 	"strings"
+	"time"
 )
 
 // HandlerFunc is the function signature for event handlers.
@@ -24,6 +25,10 @@ type Filter struct {
 	// Outgoing filters for outgoing messages only.
 	Outgoing bool
 
+	// States filters by the sender's dialog state (see Context.SetState).
+	// Empty means any state.
+	States []string
+
 	// Custom is a custom filter function.
 	// Return true to process the message, false to skip.
 	Custom func(ctx *Context) bool
@@ -35,14 +40,34 @@ type handler struct {
 }
 
 type commandHandler struct {
-	name        string
-	description string
-	params      Params
-	fn          HandlerFunc
-	filter      Filter
-	locked      bool
-	scope       CommandScope
-	langCode    string
+	name          string
+	path          string // full name including parent commands, e.g. "config set"
+	description   string
+	params        Params
+	validators    []CommandValidator
+	fn            HandlerFunc
+	filter        Filter
+	lock          *LockOptions
+	scope         CommandScope
+	langCode      string
+	aliases       []string
+	subcommands   []commandHandler
+	roles         []string
+	requireAdmin  bool
+	requireRights *AdminRights
+	timeout       time.Duration
+	cooldown      *limiter
+	rateLimit     *limiter
+}
+
+// matchesName reports whether name is the command's name or one of its aliases.
+func (h *commandHandler) matchesName(name string, foldCase bool) bool {
+	if foldCase {
+		return strings.EqualFold(h.name, name) || slices.ContainsFunc(h.aliases, func(a string) bool {
+			return strings.EqualFold(a, name)
+		})
+	}
+	return h.name == name || slices.Contains(h.aliases, name)
 }
 
 type callbackHandler struct {
@@ -98,6 +123,10 @@ func (f *Filter) matches(ctx *Context) bool {
 		return false
 	}
 
+	if len(f.States) > 0 && !ctx.inState(f.States) {
+		return false
+	}
+
 	if f.Custom != nil && !f.Custom(ctx) {
 		return false
 	}
@@ -0,0 +1,290 @@
+package telekit
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// handleHelp implements the built-in help command enabled by Config.HelpCommand.
+// Without arguments it lists the commands visible to the sender;
+// with a command name it shows that command's usage.
+func (b *Bot) handleHelp(ctx *Context) error {
+	m := b.messagesFor(ctx.LangCode())
+	visible := b.visibleCommands(ctx)
+
+	if args := ctx.Args(); len(args) > 0 {
+		name := strings.TrimPrefix(args[0], "/")
+		for _, h := range visible {
+			if !h.matchesName(name, b.config.CaseInsensitiveCommands) {
+				continue
+			}
+			node := h
+			for _, word := range args[1:] {
+				sub, ok := findSubcommand(ctx, node, word, b.config.CaseInsensitiveCommands)
+				if !ok {
+					break
+				}
+				node = sub
+			}
+			return ctx.Reply(commandUsage(ctx, m, node))
+		}
+		return ctx.Reply(renderMessage(m.UnknownCommand, map[string]string{"Command": name}))
+	}
+
+	if len(visible) == 0 {
+		return ctx.Reply(m.HelpEmpty)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(m.HelpHeader + "\n")
+	for _, h := range visible {
+		sb.WriteString("/" + h.name)
+		if h.description != "" {
+			sb.WriteString(" - " + h.description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n" + renderMessage(m.HelpFooter, map[string]string{"Command": b.config.HelpCommand}))
+	return ctx.Reply(sb.String())
+}
+
+// visibleCommands returns the commands the sender of ctx can use in the
+// current chat, one entry per name, sorted by name.
+func (b *Bot) visibleCommands(ctx *Context) []commandHandler {
+	b.mu.RLock()
+	handlers := b.commandHandlers
+	b.mu.RUnlock()
+
+	var visible []commandHandler
+	for _, h := range handlers {
+		if slices.ContainsFunc(visible, func(v commandHandler) bool { return v.name == h.name }) {
+			continue
+		}
+		if !h.filter.matches(ctx) || !scopeMatches(h.scope, ctx) || !rolesAllow(h, ctx.Roles()) {
+			continue
+		}
+		visible = append(visible, h)
+	}
+
+	slices.SortFunc(visible, func(a, b commandHandler) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	return visible
+}
+
+// scopeMatches reports whether a command scope covers the chat and sender of ctx.
+func scopeMatches(scope CommandScope, ctx *Context) bool {
+	chatID := ctx.ChatID()
+	senderID := ctx.SenderID()
+
+	switch s := scope.(type) {
+	case nil, ScopeDefault:
+		return true
+	case ScopeAllPrivate:
+		return ctx.IsPrivate()
+	case ScopeAllGroups:
+		return !ctx.IsPrivate()
+	case ScopeAllGroupAdmins:
+		return !ctx.IsPrivate() && ctx.IsAdmin()
+	case ScopeChat:
+		return chatID == s.ChatID
+	case ScopeChatAdmins:
+		return chatID == s.ChatID && ctx.IsAdmin()
+	case ScopeChannel:
+		return chatID == s.ChannelID
+	case ScopeChannelAdmins:
+		return chatID == s.ChannelID && ctx.IsAdmin()
+	case ScopeChatMember:
+		return chatID == s.ChatID && senderID == s.UserID
+	case ScopeChatMemberChannel:
+		return chatID == s.ChannelID && senderID == s.UserID
+	case ScopeUser:
+		return ctx.IsPrivate() && senderID == s.UserID
+	case ScopeUsername:
+		u, ok := ctx.entities.Users[senderID]
+		return ok && ctx.IsPrivate() && strings.EqualFold(u.Username, s.Username)
+	case ScopeChannelUsername:
+		c, ok := ctx.entities.Channels[chatID]
+		return ok && strings.EqualFold(c.Username, s.Username)
+	}
+	return false
+}
+
+// commandUsage renders the usage text of a command from its parameter schema.
+// Commands with subcommands list the subcommands visible to the sender instead.
+func commandUsage(ctx *Context, m Messages, h commandHandler) string {
+	if len(h.subcommands) == 0 {
+		return formatUsage(m.Usage, h.path, h.description, h.params)
+	}
+
+	var subs []commandHandler
+	for _, sub := range h.subcommands {
+		if sub.filter.matches(ctx) {
+			subs = append(subs, sub)
+		}
+	}
+	return formatSubcommandUsage(m.Usage, h.path, h.description, subs)
+}
+
+func formatSubcommandUsage(label, path, description string, subs []commandHandler) string {
+	var sb strings.Builder
+	sb.WriteString(label + " /" + path + " <subcommand>")
+	if description != "" {
+		sb.WriteString("\n" + description)
+	}
+	if len(subs) > 0 {
+		sb.WriteString("\n")
+		width := 0
+		for _, sub := range subs {
+			width = max(width, len(sub.name))
+		}
+		for _, sub := range subs {
+			if sub.description == "" {
+				sb.WriteString("\n  " + sub.name)
+				continue
+			}
+			fmt.Fprintf(&sb, "\n  %-*s  %s", width, sub.name, sub.description)
+		}
+	}
+	return sb.String()
+}
+
+func formatUsage(label, name, description string, params Params) string {
+	names := sortedKeys(params)
+	slices.SortStableFunc(names, func(a, b string) int {
+		// required parameters first
+		ra, rb := params[a].Required, params[b].Required
+		switch {
+		case ra && !rb:
+			return -1
+		case !ra && rb:
+			return 1
+		}
+		return 0
+	})
+
+	var sb strings.Builder
+	sb.WriteString(label + " /" + name)
+	for _, n := range names {
+		s := params[n]
+		var arg string
+		if s.Type == TypeBool {
+			arg = "--" + n
+		} else {
+			arg = n + "=" + paramPlaceholder(s)
+		}
+		if !s.Required {
+			arg = "[" + arg + "]"
+		}
+		sb.WriteString(" " + arg)
+	}
+	if description != "" {
+		sb.WriteString("\n" + description)
+	}
+
+	if len(names) > 0 {
+		sb.WriteString("\n")
+		width := 0
+		for _, n := range names {
+			width = max(width, len(n))
+		}
+		for _, n := range names {
+			fmt.Fprintf(&sb, "\n  %-*s  %s", width, n, paramDetails(params[n]))
+		}
+	}
+
+	return sb.String()
+}
+
+// paramPlaceholder returns the value placeholder shown in the usage line.
+func paramPlaceholder(s ParamSchema) string {
+	if s.Type == TypeEnum {
+		return strings.Join(s.Enum, "|")
+	}
+	if s.Type == TypeList {
+		elem := s.Elem
+		if elem == "" {
+			elem = TypeString
+		}
+		return "<" + typeLabel(elem, s.Enum) + ",...>"
+	}
+	return "<" + typeLabel(s.Type, s.Enum) + ">"
+}
+
+func typeLabel(t ParamType, enum []string) string {
+	switch t {
+	case TypeString:
+		return "text"
+	case TypeInt:
+		return "number"
+	case TypeFloat:
+		return "decimal"
+	case TypeBool:
+		return "yes|no"
+	case TypeEnum:
+		return strings.Join(enum, "|")
+	case TypeDuration:
+		return "duration"
+	case TypeTime:
+		return "date"
+	case TypeURL:
+		return "url"
+	case TypeUser:
+		return "@user"
+	case TypeChat:
+		return "@chat"
+	}
+	return string(t)
+}
+
+// paramDetails describes the type, constraints and description of a parameter.
+func paramDetails(s ParamSchema) string {
+	var parts []string
+	if s.Type == TypeList {
+		elem := s.Elem
+		if elem == "" {
+			elem = TypeString
+		}
+		parts = append(parts, "list of "+typeLabel(elem, s.Enum))
+	} else {
+		parts = append(parts, typeLabel(s.Type, s.Enum))
+	}
+	if s.Required {
+		parts = append(parts, "required")
+	}
+	switch {
+	case s.Min != nil && s.Max != nil:
+		parts = append(parts, fmt.Sprintf("%v..%v", s.Min, s.Max))
+	case s.Min != nil:
+		parts = append(parts, fmt.Sprintf(">= %v", s.Min))
+	case s.Max != nil:
+		parts = append(parts, fmt.Sprintf("<= %v", s.Max))
+	}
+	if s.MinLen > 0 {
+		parts = append(parts, fmt.Sprintf("min length %d", s.MinLen))
+	}
+	if s.MaxLen > 0 {
+		parts = append(parts, fmt.Sprintf("max length %d", s.MaxLen))
+	}
+	if s.Default != nil {
+		parts = append(parts, fmt.Sprintf("default %v", formatDefault(s.Default)))
+	}
+
+	details := strings.Join(parts, ", ")
+	if s.Description != "" {
+		details += ". " + s.Description
+	}
+	return details
+}
+
+func formatDefault(v any) string {
+	switch x := v.(type) {
+	case []string:
+		return strings.Join(x, ",")
+	case fmt.Stringer:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,101 @@
+package telekit
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestFormatUsage(t *testing.T) {
+	params := Params{
+		"title":   {Type: TypeString, Required: true, Description: "Note title"},
+		"limit":   {Type: TypeInt, Min: int64(1), Max: int64(100), Default: int64(10)},
+		"mode":    {Type: TypeEnum, Enum: []string{"fast", "slow"}},
+		"verbose": {Type: TypeBool},
+	}
+
+	got := formatUsage("Usage:", "note", "Create a note", params)
+
+	wantLines := []string{
+		"Usage: /note title=<text> [limit=<number>] [mode=fast|slow] [--verbose]",
+		"Create a note",
+		"  title    text, required. Note title",
+		"  limit    number, 1..100, default 10",
+		"  mode     fast|slow",
+	}
+	for _, line := range wantLines {
+		if !strings.Contains(got, line) {
+			t.Errorf("usage missing line %q\nGot:\n%s", line, got)
+		}
+	}
+}
+
+func TestScopeMatches(t *testing.T) {
+	private := &Context{message: &tg.Message{PeerID: &tg.PeerUser{UserID: 10}}}
+	group := &Context{message: &tg.Message{
+		PeerID: &tg.PeerChannel{ChannelID: 500},
+		FromID: &tg.PeerUser{UserID: 10},
+	}}
+
+	tests := []struct {
+		name  string
+		scope CommandScope
+		ctx   *Context
+		want  bool
+	}{
+		{"default private", nil, private, true},
+		{"default group", ScopeDefault{}, group, true},
+		{"all private in private", ScopeAllPrivate{}, private, true},
+		{"all private in group", ScopeAllPrivate{}, group, false},
+		{"all groups in group", ScopeAllGroups{}, group, true},
+		{"all groups in private", ScopeAllGroups{}, private, false},
+		{"user match", ScopeUser{UserID: 10}, private, true},
+		{"user mismatch", ScopeUser{UserID: 11}, private, false},
+		{"channel match", ScopeChannel{ChannelID: 500}, group, true},
+		{"channel member mismatch", ScopeChatMemberChannel{ChannelID: 500, UserID: 11}, group, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopeMatches(tt.scope, tt.ctx); got != tt.want {
+				t.Errorf("scopeMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterBuiltins(t *testing.T) {
+	b := &Bot{config: Config{HelpCommand: "help", CancelCommand: "cancel", Logger: slog.Default()}}
+	noop := func(*Context) error { return nil }
+	b.CommandWithDesc(CommandDef{Name: "help", Description: "Custom help"}, noop)
+	b.CommandWithDesc(CommandDef{Name: "abort", Aliases: []string{"cancel"}}, noop)
+	b.CommandWithDesc(CommandDef{Name: "report"}, noop)
+
+	b.registerBuiltins()
+	b.registerBuiltins()
+
+	var names []string
+	for _, h := range b.commandHandlers {
+		names = append(names, h.name)
+	}
+	if want := []string{"help", "abort", "report"}; !slices.Equal(names, want) {
+		t.Errorf("commands = %q, want %q", names, want)
+	}
+	if b.commandHandlers[0].description != "Custom help" {
+		t.Error("built-in help replaced the registered help command")
+	}
+
+	b = &Bot{config: Config{HelpCommand: "help", CancelCommand: "cancel", Logger: slog.Default()}}
+	b.CommandWithDesc(CommandDef{Name: "report"}, noop)
+	b.registerBuiltins()
+	names = names[:0]
+	for _, h := range b.commandHandlers {
+		names = append(names, h.name)
+	}
+	if want := []string{"report", "help", "cancel"}; !slices.Equal(names, want) {
+		t.Errorf("commands = %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,130 @@
+package telekit
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Invocation describes a running command handler.
+type Invocation struct {
+	ID      uint64
+	Command string // full command path, e.g. "config set"
+	UserID  int64
+	ChatID  int64
+	Started time.Time
+}
+
+type runningInvocation struct {
+	info    Invocation
+	cancel  context.CancelFunc
+	release func() // releases the command lock, nil if none is held
+}
+
+// invocationRegistry tracks running command handlers.
+type invocationRegistry struct {
+	mu      sync.Mutex
+	nextID  uint64
+	running map[uint64]*runningInvocation
+}
+
+func newInvocationRegistry() *invocationRegistry {
+	return &invocationRegistry{
+		running: make(map[uint64]*runningInvocation),
+	}
+}
+
+func (r *invocationRegistry) add(info Invocation, cancel context.CancelFunc) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	info.ID = r.nextID
+	r.running[info.ID] = &runningInvocation{info: info, cancel: cancel}
+	return info.ID
+}
+
+func (r *invocationRegistry) remove(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, id)
+}
+
+func (r *invocationRegistry) setRelease(id uint64, release func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if inv, ok := r.running[id]; ok {
+		inv.release = release
+	}
+}
+
+func (r *invocationRegistry) list() []Invocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]Invocation, 0, len(r.running))
+	for _, inv := range r.running {
+		list = append(list, inv.info)
+	}
+	slices.SortFunc(list, func(a, b Invocation) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return list
+}
+
+// cancel cancels the invocations matching fn and releases their locks.
+// It returns how many were cancelled.
+func (r *invocationRegistry) cancel(fn func(Invocation) bool) int {
+	r.mu.Lock()
+	var matched []*runningInvocation
+	for id, inv := range r.running {
+		if fn(inv.info) {
+			matched = append(matched, inv)
+			delete(r.running, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, inv := range matched {
+		inv.cancel()
+		if inv.release != nil {
+			inv.release()
+		}
+	}
+	return len(matched)
+}
+
+// Invocations returns the running command handlers, oldest first.
+func (b *Bot) Invocations() []Invocation {
+	return b.invocations.list()
+}
+
+// CancelInvocation cancels the context of a running command handler and
+// releases its command lock. It returns false if the invocation is not
+// running.
+func (b *Bot) CancelInvocation(id uint64) bool {
+	return b.invocations.cancel(func(inv Invocation) bool { return inv.ID == id }) > 0
+}
+
+// CancelUserInvocations cancels all running command handlers of a user
+// and returns how many were cancelled.
+func (b *Bot) CancelUserInvocations(userID int64) int {
+	return b.invocations.cancel(func(inv Invocation) bool { return inv.UserID == userID })
+}
+
+// handleCancel implements the built-in cancel command enabled by
+// Config.CancelCommand.
+func (b *Bot) handleCancel(ctx *Context) error {
+	userID := ctx.SenderID()
+	n := b.invocations.cancel(func(inv Invocation) bool {
+		return inv.UserID == userID && inv.ID != ctx.invocationID
+	})
+
+	m := b.messagesFor(ctx.LangCode())
+	if n == 0 {
+		return ctx.Reply(m.NothingToCancel)
+	}
+	return ctx.Reply(m.Cancelled)
+}
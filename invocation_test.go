@@ -0,0 +1,101 @@
+package telekit
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInvocationRegistry(t *testing.T) {
+	r := newInvocationRegistry()
+	m := newTestLockManager()
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	id1 := r.add(Invocation{Command: "export", UserID: 1}, cancel1)
+	id2 := r.add(Invocation{Command: "report", UserID: 2}, cancel2)
+
+	release, err := m.acquire(ctx1, "/user:1", LockOptions{}, cancel1)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	r.setRelease(id1, release)
+
+	list := r.list()
+	if len(list) != 2 || list[0].ID != id1 || list[1].ID != id2 || list[0].Command != "export" {
+		t.Fatalf("list() = %+v", list)
+	}
+
+	if n := r.cancel(func(inv Invocation) bool { return inv.UserID == 1 }); n != 1 {
+		t.Errorf("cancel() = %d, want 1", n)
+	}
+	if ctx1.Err() == nil {
+		t.Error("cancelled invocation context is not done")
+	}
+	if ctx2.Err() != nil {
+		t.Error("other invocation was cancelled")
+	}
+	next, err := m.acquire(ctx2, "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Error("lock of the cancelled invocation was not released")
+	} else {
+		next()
+	}
+
+	r.remove(id2)
+	if list := r.list(); len(list) != 0 {
+		t.Errorf("list() after remove = %+v", list)
+	}
+}
+
+func TestHandleCancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(ctx *Context, finish <-chan struct{}) error
+	}{
+		{"running", func(ctx *Context, finish <-chan struct{}) error {
+			<-ctx.Done()
+			<-finish
+			return nil
+		}},
+		{"waiting for an answer", func(ctx *Context, finish <-chan struct{}) error {
+			_, err := ctx.WaitMessage(nil, 0)
+			<-finish
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &recordingInvoker{}
+			b := newDispatchTestBot(invoker)
+
+			started := make(chan *Context, 1)
+			finish := make(chan struct{})
+			b.CommandWithDesc(CommandDef{Name: "export", Locked: true}, func(ctx *Context) error {
+				started <- ctx
+				return tt.handler(ctx, finish)
+			})
+			b.CommandWithDesc(CommandDef{Name: "cancel"}, b.handleCancel)
+
+			sendTestMessage(t, b, 10, "/export")
+			export := <-started
+			sendTestMessage(t, b, 10, "/cancel")
+
+			// the command is still in flight until finish is closed
+			waitSent(t, invoker, DefaultMessages().Cancelled)
+			if export.Err() == nil {
+				t.Error("running command was not cancelled")
+			}
+			release, err := b.locks.acquire(context.Background(), "/user:10", LockOptions{}, nil)
+			if err != nil {
+				t.Errorf("lock of the cancelled command was not released: %v", err)
+			} else {
+				release()
+			}
+
+			close(finish)
+			b.workers.Wait()
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package telekit
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gotd/td/tg"
+)
+
+// Telegram limits of inline keyboards.
+const (
+	maxCallbackDataLen   = 64
+	maxCopyTextLen       = 256
+	maxButtonsPerRow     = 8
+	maxKeyboardButtons   = 100
+	maxSwitchInlineQuery = 256
+)
+
+// SendOption configures a message sent by the send, reply and edit methods
+// of Context, e.g. an InlineKeyboard.
+type SendOption interface {
+	applySend(opts *sendOptions) error
+}
+
+// sendOptions is the result of applying SendOptions.
+type sendOptions struct {
+	markup tg.ReplyMarkupClass
+}
+
+func applySendOptions(opts []SendOption) (sendOptions, error) {
+	var o sendOptions
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt.applySend(&o); err != nil {
+			return sendOptions{}, err
+		}
+	}
+	return o, nil
+}
+
+// InlineButton is a button of an InlineKeyboard. Create it with one of the
+// button constructors, such as CallbackButton.
+type InlineButton struct {
+	button tg.KeyboardButtonClass
+	err    error
+}
+
+// CallbackButton sends data to the bot's callback handlers when pressed.
+// data must be 1-64 bytes.
+func CallbackButton(text, data string) InlineButton {
+	if data == "" || len(data) > maxCallbackDataLen {
+		return invalidButton(text, "callback data must be 1-%d bytes, got %d", maxCallbackDataLen, len(data))
+	}
+	return newButton(text, &tg.KeyboardButtonCallback{Text: text, Data: []byte(data)})
+}
+
+// URLButton opens url when pressed.
+func URLButton(text, url string) InlineButton {
+	if url == "" {
+		return invalidButton(text, "URL is required")
+	}
+	return newButton(text, &tg.KeyboardButtonURL{Text: text, URL: url})
+}
+
+// SwitchInlineButton lets the user pick a chat and starts an inline query
+// to the bot there, prefilled with query.
+func SwitchInlineButton(text, query string) InlineButton {
+	return switchInlineButton(text, query, false)
+}
+
+// SwitchInlineCurrentChatButton starts an inline query to the bot in the
+// current chat, prefilled with query.
+func SwitchInlineCurrentChatButton(text, query string) InlineButton {
+	return switchInlineButton(text, query, true)
+}
+
+func switchInlineButton(text, query string, sameChat bool) InlineButton {
+	if utf8.RuneCountInString(query) > maxSwitchInlineQuery {
+		return invalidButton(text, "inline query must be at most %d characters", maxSwitchInlineQuery)
+	}
+	return newButton(text, &tg.KeyboardButtonSwitchInline{Text: text, Query: query, SamePeer: sameChat})
+}
+
+// LoginButton authorizes the user on the website at url with their
+// Telegram account (Telegram Login).
+func LoginButton(text, url string) InlineButton {
+	if url == "" {
+		return invalidButton(text, "login URL is required")
+	}
+	return newButton(text, &tg.InputKeyboardButtonURLAuth{
+		Text: text,
+		URL:  url,
+		Bot:  &tg.InputUserSelf{},
+	})
+}
+
+// WebAppButton opens the Web App at url when pressed.
+func WebAppButton(text, url string) InlineButton {
+	if url == "" {
+		return invalidButton(text, "web app URL is required")
+	}
+	return newButton(text, &tg.KeyboardButtonWebView{Text: text, URL: url})
+}
+
+// CopyTextButton copies copyText (1-256 characters) to the clipboard.
+func CopyTextButton(text, copyText string) InlineButton {
+	if n := utf8.RuneCountInString(copyText); n == 0 || n > maxCopyTextLen {
+		return invalidButton(text, "copied text must be 1-%d characters, got %d", maxCopyTextLen, n)
+	}
+	return newButton(text, &tg.KeyboardButtonCopy{Text: text, CopyText: copyText})
+}
+
+// PayButton pays an invoice. It must be the first button of the first row
+// and is only allowed on invoice messages.
+func PayButton(text string) InlineButton {
+	return newButton(text, &tg.KeyboardButtonBuy{Text: text})
+}
+
+func newButton(text string, button tg.KeyboardButtonClass) InlineButton {
+	if text == "" {
+		return InlineButton{err: fmt.Errorf("%w: button text is required", ErrInvalidKeyboard)}
+	}
+	return InlineButton{button: button}
+}
+
+func invalidButton(text, format string, args ...any) InlineButton {
+	return InlineButton{err: fmt.Errorf("%w: button %q: %s", ErrInvalidKeyboard, text, fmt.Sprintf(format, args...))}
+}
+
+// InlineKeyboard builds the inline keyboard of a message. It is a
+// SendOption, so it can be passed to Context.Reply and friends:
+//
+//	kb := telekit.NewInlineKeyboard().
+//		Row(telekit.CallbackButton("Yes", "vote:yes"), telekit.CallbackButton("No", "vote:no")).
+//		Row(telekit.URLButton("Docs", "https://example.com"))
+//	ctx.Reply("Continue?", kb)
+//
+// Invalid buttons and exceeded Telegram limits are reported by Build and
+// by the methods the keyboard is passed to.
+type InlineKeyboard struct {
+	rows [][]InlineButton
+}
+
+// NewInlineKeyboard creates an empty inline keyboard.
+func NewInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{}
+}
+
+// Row appends a row of buttons.
+func (k *InlineKeyboard) Row(buttons ...InlineButton) *InlineKeyboard {
+	if len(buttons) > 0 {
+		k.rows = append(k.rows, buttons)
+	}
+	return k
+}
+
+// Grid appends buttons in rows of the given number of columns; the last
+// row may be shorter.
+func (k *InlineKeyboard) Grid(columns int, buttons ...InlineButton) *InlineKeyboard {
+	if columns <= 0 {
+		columns = 1
+	}
+	for len(buttons) > 0 {
+		n := min(columns, len(buttons))
+		k.Row(buttons[:n]...)
+		buttons = buttons[n:]
+	}
+	return k
+}
+
+// Build validates the keyboard and returns its markup.
+func (k *InlineKeyboard) Build() (*tg.ReplyInlineMarkup, error) {
+	markup := &tg.ReplyInlineMarkup{Rows: make([]tg.KeyboardButtonRow, 0, len(k.rows))}
+	total := 0
+	for i, row := range k.rows {
+		if len(row) > maxButtonsPerRow {
+			return nil, fmt.Errorf("%w: row %d has %d buttons, at most %d are allowed",
+				ErrInvalidKeyboard, i+1, len(row), maxButtonsPerRow)
+		}
+		buttons := make([]tg.KeyboardButtonClass, 0, len(row))
+		for j, b := range row {
+			if b.err != nil {
+				return nil, b.err
+			}
+			if b.button == nil {
+				return nil, fmt.Errorf("%w: button %d of row %d is empty", ErrInvalidKeyboard, j+1, i+1)
+			}
+			if _, ok := b.button.(*tg.KeyboardButtonBuy); ok && (i != 0 || j != 0) {
+				return nil, fmt.Errorf("%w: pay button must be the first button", ErrInvalidKeyboard)
+			}
+			buttons = append(buttons, b.button)
+		}
+		total += len(buttons)
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: buttons})
+	}
+	if total > maxKeyboardButtons {
+		return nil, fmt.Errorf("%w: keyboard has %d buttons, at most %d are allowed",
+			ErrInvalidKeyboard, total, maxKeyboardButtons)
+	}
+	return markup, nil
+}
+
+func (k *InlineKeyboard) applySend(opts *sendOptions) error {
+	markup, err := k.Build()
+	if err != nil {
+		return err
+	}
+	opts.markup = markup
+	return nil
+}
@@ -0,0 +1,103 @@
+package telekit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestInlineKeyboardBuild(t *testing.T) {
+	kb := NewInlineKeyboard().
+		Row(CallbackButton("Yes", "vote:yes"), URLButton("Docs", "https://example.com")).
+		Grid(2,
+			SwitchInlineButton("Share", "q"),
+			SwitchInlineCurrentChatButton("Here", ""),
+			WebAppButton("App", "https://app.example.com"),
+		).
+		Row(CopyTextButton("Copy", "code-123"), LoginButton("Login", "https://example.com/login"))
+
+	markup, err := kb.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := [][]tg.KeyboardButtonClass{
+		{
+			&tg.KeyboardButtonCallback{Text: "Yes", Data: []byte("vote:yes")},
+			&tg.KeyboardButtonURL{Text: "Docs", URL: "https://example.com"},
+		},
+		{
+			&tg.KeyboardButtonSwitchInline{Text: "Share", Query: "q"},
+			&tg.KeyboardButtonSwitchInline{Text: "Here", SamePeer: true},
+		},
+		{
+			&tg.KeyboardButtonWebView{Text: "App", URL: "https://app.example.com"},
+		},
+		{
+			&tg.KeyboardButtonCopy{Text: "Copy", CopyText: "code-123"},
+			&tg.InputKeyboardButtonURLAuth{Text: "Login", URL: "https://example.com/login", Bot: &tg.InputUserSelf{}},
+		},
+	}
+	if len(markup.Rows) != len(want) {
+		t.Fatalf("Build() rows = %d, want %d", len(markup.Rows), len(want))
+	}
+	for i, row := range markup.Rows {
+		if !reflect.DeepEqual(row.Buttons, want[i]) {
+			t.Errorf("row %d = %+v, want %+v", i, row.Buttons, want[i])
+		}
+	}
+}
+
+func TestInlineKeyboardValidation(t *testing.T) {
+	manyButtons := make([]InlineButton, 101)
+	for i := range manyButtons {
+		manyButtons[i] = CallbackButton("b", "x")
+	}
+
+	tests := []struct {
+		name string
+		kb   *InlineKeyboard
+	}{
+		{"long callback data", NewInlineKeyboard().Row(CallbackButton("A", strings.Repeat("x", 65)))},
+		{"empty callback data", NewInlineKeyboard().Row(CallbackButton("A", ""))},
+		{"empty text", NewInlineKeyboard().Row(URLButton("", "https://example.com"))},
+		{"empty URL", NewInlineKeyboard().Row(URLButton("A", ""))},
+		{"long copy text", NewInlineKeyboard().Row(CopyTextButton("A", strings.Repeat("x", 257)))},
+		{"zero button", NewInlineKeyboard().Row(InlineButton{})},
+		{"wide row", NewInlineKeyboard().Row(manyButtons[:9]...)},
+		{"too many buttons", NewInlineKeyboard().Grid(5, manyButtons...)},
+		{"pay button not first", NewInlineKeyboard().Row(CallbackButton("A", "a"), PayButton("Pay"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.kb.Build(); !errors.Is(err, ErrInvalidKeyboard) {
+				t.Errorf("Build() error = %v, want %v", err, ErrInvalidKeyboard)
+			}
+		})
+	}
+
+	if _, err := NewInlineKeyboard().Row(PayButton("Pay")).Build(); err != nil {
+		t.Errorf("Build() with leading pay button error = %v", err)
+	}
+	if _, err := NewInlineKeyboard().Row(CallbackButton("A", strings.Repeat("x", 64))).Build(); err != nil {
+		t.Errorf("Build() with 64-byte callback data error = %v", err)
+	}
+}
+
+func TestApplySendOptions(t *testing.T) {
+	o, err := applySendOptions([]SendOption{nil, NewInlineKeyboard().Row(CallbackButton("A", "a"))})
+	if err != nil {
+		t.Fatalf("applySendOptions() error = %v", err)
+	}
+	if _, ok := o.markup.(*tg.ReplyInlineMarkup); !ok {
+		t.Errorf("applySendOptions() markup = %T, want *tg.ReplyInlineMarkup", o.markup)
+	}
+
+	bad := NewInlineKeyboard().Row(CallbackButton("A", ""))
+	if _, err := applySendOptions([]SendOption{bad}); !errors.Is(err, ErrInvalidKeyboard) {
+		t.Errorf("applySendOptions() error = %v, want %v", err, ErrInvalidKeyboard)
+	}
+}
@@ -0,0 +1,173 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// lockPollInterval is how often waiting invocations retry a lock held by
+// another process. Locks released in this process wake waiters at once.
+const lockPollInterval = 100 * time.Millisecond
+
+// lockName combines a lock group and key into the backend lock name.
+func lockName(group, key string) string {
+	return group + "/" + key
+}
+
+// lockManager implements lock modes and lease renewal on top of a
+// CommandLock backend.
+type lockManager struct {
+	backend CommandLock
+	ttl     time.Duration
+	logger  *slog.Logger
+
+	mu    sync.Mutex
+	local map[string]*localLock // locks held by this process
+}
+
+type localLock struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newLockManager(backend CommandLock, ttl time.Duration, logger *slog.Logger) *lockManager {
+	return &lockManager{
+		backend: backend,
+		ttl:     ttl,
+		logger:  logger,
+		local:   make(map[string]*localLock),
+	}
+}
+
+// acquire takes the named lock according to opts. It returns
+// ErrCommandLocked if the lock could not be taken in time. cancel is
+// called when the hold time runs out, the lease is lost, or another
+// invocation cancels this one.
+func (m *lockManager) acquire(ctx context.Context, name string, opts LockOptions, cancel context.CancelFunc) (func(), error) {
+	return m.acquireWaiting(ctx, name, opts, cancel, nil)
+}
+
+// acquireWaiting is acquire calling waiting, if not nil, once the first
+// attempt failed and before it starts waiting for the lock.
+func (m *lockManager) acquireWaiting(ctx context.Context, name string, opts LockOptions, cancel context.CancelFunc, waiting func()) (func(), error) {
+	if opts.QueueTimeout > 0 && opts.Mode != LockReject {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, opts.QueueTimeout)
+		defer stop()
+	}
+
+	ttl := m.ttl
+	if opts.MaxHold > 0 {
+		ttl = opts.MaxHold
+	}
+
+	for {
+		token, ok, err := m.backend.Acquire(ctx, name, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire command lock: %w", err)
+		}
+		if ok {
+			return m.hold(name, token, ttl, opts.MaxHold > 0, cancel), nil
+		}
+
+		if opts.Mode == LockReject {
+			return nil, ErrCommandLocked
+		}
+		if waiting != nil {
+			waiting()
+			waiting = nil
+		}
+
+		m.mu.Lock()
+		held := m.local[name]
+		m.mu.Unlock()
+
+		var released <-chan struct{}
+		if held != nil {
+			if opts.Mode == LockCancelPrevious && held.cancel != nil {
+				held.cancel()
+			}
+			released = held.done
+		}
+
+		timer := time.NewTimer(lockPollInterval)
+		select {
+		case <-released:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrCommandLocked
+			}
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// hold records a lease taken by this process and keeps it alive until the
+// returned release func is called. With a fixed hold time the lease is not
+// renewed and the invocation is cancelled when it runs out.
+func (m *lockManager) hold(name, token string, ttl time.Duration, fixed bool, cancel context.CancelFunc) func() {
+	held := &localLock{cancel: cancel, done: make(chan struct{})}
+	m.mu.Lock()
+	m.local[name] = held
+	m.mu.Unlock()
+
+	stop := make(chan struct{})
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			if err := m.backend.Release(context.Background(), name, token); err != nil {
+				m.logger.Warn("failed to release command lock", "lock", name, "error", err)
+			}
+			m.mu.Lock()
+			if m.local[name] == held {
+				delete(m.local, name)
+			}
+			m.mu.Unlock()
+			close(held.done)
+		})
+	}
+
+	go func() {
+		if fixed {
+			timer := time.NewTimer(ttl)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				release()
+				if cancel != nil {
+					cancel()
+				}
+			case <-stop:
+			}
+			return
+		}
+
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.backend.Refresh(context.Background(), name, token, ttl); err != nil {
+					m.logger.Warn("command lock lost", "lock", name, "error", err)
+					release()
+					if cancel != nil {
+						cancel()
+					}
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return release
+}
@@ -0,0 +1,278 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestLockManager() *lockManager {
+	return newLockManager(NewMemoryLock(), time.Minute, slog.Default())
+}
+
+func TestLockManagerReject(t *testing.T) {
+	m := newTestLockManager()
+	ctx := context.Background()
+
+	release, err := m.acquire(ctx, "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if _, err := m.acquire(ctx, "/user:1", LockOptions{}, nil); !errors.Is(err, ErrCommandLocked) {
+		t.Errorf("second acquire() error = %v, want %v", err, ErrCommandLocked)
+	}
+	if _, err := m.acquire(ctx, "reports/user:1", LockOptions{}, nil); err != nil {
+		t.Errorf("acquire() in another group error = %v", err)
+	}
+
+	release()
+	release()
+	next, err := m.acquire(ctx, "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() after release error = %v", err)
+	}
+	next()
+}
+
+func TestLockManagerQueue(t *testing.T) {
+	m := newTestLockManager()
+	ctx := context.Background()
+
+	release, _ := m.acquire(ctx, "/chat:5", LockOptions{}, nil)
+	acquired := make(chan struct{})
+	go func() {
+		next, err := m.acquire(ctx, "/chat:5", LockOptions{Mode: LockQueue}, nil)
+		if err == nil {
+			next()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("queued acquire() returned while the lock was held")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	<-acquired
+
+	release, _ = m.acquire(ctx, "/chat:5", LockOptions{}, nil)
+	defer release()
+	_, err := m.acquire(ctx, "/chat:5", LockOptions{Mode: LockQueue, QueueTimeout: 10 * time.Millisecond}, nil)
+	if !errors.Is(err, ErrCommandLocked) {
+		t.Errorf("acquire() after queue timeout error = %v, want %v", err, ErrCommandLocked)
+	}
+}
+
+func TestLockManagerCancelPrevious(t *testing.T) {
+	m := newTestLockManager()
+	first, cancel := context.WithCancel(context.Background())
+
+	release, _ := m.acquire(first, "/user:1", LockOptions{}, cancel)
+	go func() {
+		<-first.Done()
+		release()
+	}()
+
+	next, err := m.acquire(context.Background(), "/user:1", LockOptions{Mode: LockCancelPrevious}, nil)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	next()
+	if first.Err() == nil {
+		t.Error("previous invocation was not cancelled")
+	}
+}
+
+func TestLockManagerMaxHold(t *testing.T) {
+	m := newTestLockManager()
+	held, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := m.acquire(held, "/user:1", LockOptions{MaxHold: 5 * time.Millisecond}, cancel); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	select {
+	case <-held.Done():
+	case <-time.After(time.Second):
+		t.Fatal("invocation was not cancelled after the maximum hold time")
+	}
+	next, err := m.acquire(context.Background(), "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() after the maximum hold time error = %v", err)
+	}
+	next()
+}
+
+func TestLockManagerRenewsLease(t *testing.T) {
+	m := newLockManager(NewMemoryLock(), 15*time.Millisecond, slog.Default())
+	ctx := context.Background()
+
+	release, err := m.acquire(ctx, "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer release()
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := m.acquire(ctx, "/user:1", LockOptions{}, nil); !errors.Is(err, ErrCommandLocked) {
+		t.Errorf("acquire() after the lease TTL error = %v, want %v", err, ErrCommandLocked)
+	}
+}
+
+func TestLockManagersShareFileLock(t *testing.T) {
+	dir := t.TempDir()
+	newManager := func() *lockManager {
+		backend, err := NewFileLock(dir)
+		if err != nil {
+			t.Fatalf("NewFileLock() error = %v", err)
+		}
+		return newLockManager(backend, time.Minute, slog.Default())
+	}
+	// two bot processes using the same directory
+	first, second := newManager(), newManager()
+	ctx := context.Background()
+
+	release, err := first.acquire(ctx, "/user:1", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if _, err := second.acquire(ctx, "/user:1", LockOptions{}, nil); !errors.Is(err, ErrCommandLocked) {
+		t.Errorf("acquire() in another process error = %v, want %v", err, ErrCommandLocked)
+	}
+	other, err := second.acquire(ctx, "/user:2", LockOptions{}, nil)
+	if err != nil {
+		t.Fatalf("acquire() of another lock error = %v", err)
+	}
+	other()
+
+	acquired := make(chan error, 1)
+	go func() {
+		next, err := second.acquire(ctx, "/user:1", LockOptions{Mode: LockQueue, QueueTimeout: time.Second}, nil)
+		if err == nil {
+			next()
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued acquire() in another process error = %v", err)
+	}
+}
+
+func TestFileLockLease(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := NewFileLock(dir)
+	second, _ := NewFileLock(dir)
+	ctx := context.Background()
+
+	token, ok, err := first.Acquire(ctx, "a", 10*time.Millisecond)
+	if !ok || err != nil {
+		t.Fatalf("Acquire() = %v, %v", ok, err)
+	}
+	if err := first.Refresh(ctx, "a", token, 10*time.Millisecond); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	taken, ok, err := second.Acquire(ctx, "a", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("Acquire() after expiry = %v, %v", ok, err)
+	}
+	if err := first.Refresh(ctx, "a", token, time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Refresh() of a lost lease error = %v, want %v", err, ErrLockLost)
+	}
+	if err := first.Release(ctx, "a", token); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, ok, _ := first.Acquire(ctx, "a", time.Minute); ok {
+		t.Error("Release() of a lost lease freed the new holder's lock")
+	}
+	_ = second.Release(ctx, "a", taken)
+	if _, ok, _ := first.Acquire(ctx, "a", time.Minute); !ok {
+		t.Error("Acquire() after Release() failed")
+	}
+}
+
+func TestMemoryLockTryAcquire(t *testing.T) {
+	l := NewMemoryLock()
+	m := newLockManager(l, time.Minute, slog.Default())
+
+	if !l.TryAcquire(1, false) {
+		t.Error("TryAcquire() without acquiring returned false")
+	}
+	if !l.TryAcquire(1, true) || l.TryAcquire(1, true) {
+		t.Error("TryAcquire() did not take the user's lock exactly once")
+	}
+	if _, err := m.acquire(context.Background(), lockName("", userLockKey(1)), LockOptions{}, nil); !errors.Is(err, ErrCommandLocked) {
+		t.Errorf("locked command ran while TryAcquire() held the lock, error = %v", err)
+	}
+	l.Unlock(1)
+	if !l.TryAcquire(1, true) {
+		t.Error("TryAcquire() after Unlock() failed")
+	}
+}
+
+func TestHandleMessageLockModes(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           LockMode
+		wantConcurrent bool // the second invocation starts while the first runs
+		wantCancelled  bool // the first invocation is cancelled
+		wantRuns       int
+	}{
+		{"reject", LockReject, false, false, 1},
+		{"queue", LockQueue, false, false, 2},
+		{"cancel previous", LockCancelPrevious, true, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &recordingInvoker{}
+			b := newDispatchTestBot(invoker)
+
+			var runs atomic.Int32
+			started := make(chan *Context, 2)
+			finish := make(chan struct{})
+			b.CommandWithDesc(CommandDef{Name: "export", Lock: &LockOptions{Mode: tt.mode}}, func(ctx *Context) error {
+				runs.Add(1)
+				started <- ctx
+				select {
+				case <-ctx.Done():
+				case <-finish:
+				}
+				return nil
+			})
+
+			sendTestMessage(t, b, 10, "/export")
+			first := <-started
+			sendTestMessage(t, b, 10, "/export")
+
+			if tt.mode == LockReject {
+				waitSent(t, invoker, DefaultMessages().CommandLocked)
+			}
+			select {
+			case <-started:
+				if !tt.wantConcurrent {
+					t.Error("second invocation ran while the first held the lock")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.wantConcurrent {
+					t.Error("second invocation did not start")
+				}
+			}
+			if cancelled := first.Err() != nil; cancelled != tt.wantCancelled {
+				t.Errorf("first invocation cancelled = %v, want %v", cancelled, tt.wantCancelled)
+			}
+
+			close(finish)
+			b.workers.Wait()
+			if got := int(runs.Load()); got != tt.wantRuns {
+				t.Errorf("export ran %d times, want %d", got, tt.wantRuns)
+			}
+		})
+	}
+}
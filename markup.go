@@ -0,0 +1,515 @@
+package telekit
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+)
+
+// markupBuilder collects plain text and entities with UTF-16 offsets.
+type markupBuilder struct {
+	text     strings.Builder
+	pos      int // UTF-16 length of text
+	entities []tg.MessageEntityClass
+}
+
+func (b *markupBuilder) write(s string) {
+	b.text.WriteString(s)
+	b.pos += entity.ComputeLength(s)
+}
+
+// add appends an entity for the text written since start. Empty entities
+// are dropped, as Telegram rejects them.
+func (b *markupBuilder) add(start int, fn func(offset, length int) tg.MessageEntityClass) {
+	if b.pos > start {
+		b.entities = append(b.entities, fn(start, b.pos-start))
+	}
+}
+
+// result returns the text and its entities ordered by offset, outer
+// entities first.
+func (b *markupBuilder) result() (string, []tg.MessageEntityClass) {
+	slices.SortStableFunc(b.entities, func(x, y tg.MessageEntityClass) int {
+		if c := cmp.Compare(x.GetOffset(), y.GetOffset()); c != 0 {
+			return c
+		}
+		return cmp.Compare(y.GetLength(), x.GetLength())
+	})
+	return b.text.String(), b.entities
+}
+
+// markupError reports invalid markup at a byte position of the input.
+func markupError(pos int, format string, args ...any) error {
+	return fmt.Errorf("%w at position %d: %s", ErrInvalidMarkup, pos, fmt.Sprintf(format, args...))
+}
+
+// htmlTag is an open HTML element.
+type htmlTag struct {
+	name      string // canonical name, e.g. "b" for <strong>
+	start     int    // UTF-16 offset of the content
+	textStart int    // byte offset of the content in the output
+	attrs     map[string]string
+}
+
+// htmlTagNames maps accepted tags to their canonical names.
+var htmlTagNames = map[string]string{
+	"b": "b", "strong": "b",
+	"i": "i", "em": "i",
+	"u": "u", "ins": "u",
+	"s": "s", "strike": "s", "del": "s",
+	"code":       "code",
+	"pre":        "pre",
+	"blockquote": "blockquote",
+	"span":       "span",
+	"tg-spoiler": "tg-spoiler",
+	"a":          "a",
+}
+
+// ParseHTML converts Telegram-style HTML to plain text and message entities
+// with UTF-16 offsets, ready to be sent. It accepts every tag EntitiesToHTML
+// emits, so its output round-trips, and the aliases of Telegram's HTML
+// parse mode: b/strong, i/em, u/ins, s/strike/del, code, pre (with an inner
+// <code class="language-...">), blockquote (class="expandable" or the
+// expandable attribute), span class="spoiler" or "tg-spoiler", tg-spoiler,
+// a href and br.
+//
+// Whitespace is kept as-is. The content of pre is taken literally up to its
+// closing tag, so unescaped "<" in code survives; character references are
+// still decoded. Unknown or misnested tags return ErrInvalidMarkup.
+func ParseHTML(s string) (string, []tg.MessageEntityClass, error) {
+	var (
+		b     markupBuilder
+		stack []htmlTag
+	)
+
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			end := strings.IndexByte(s[i:], '<')
+			if end < 0 {
+				end = len(s) - i
+			}
+			b.write(html.UnescapeString(s[i : i+end]))
+			i += end
+			continue
+		}
+
+		end := htmlTagEnd(s, i)
+		if end < 0 {
+			return "", nil, markupError(i, "unclosed tag")
+		}
+		body := strings.TrimSpace(s[i+1 : end])
+		tagPos := i
+		i = end + 1
+
+		if closing, ok := strings.CutPrefix(body, "/"); ok {
+			name := htmlTagNames[strings.ToLower(strings.TrimSpace(closing))]
+			if name == "" || len(stack) == 0 || stack[len(stack)-1].name != name {
+				return "", nil, markupError(tagPos, "unexpected closing tag <%s>", body)
+			}
+			tag := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err := closeHTMLTag(&b, tag); err != nil {
+				return "", nil, markupError(tagPos, "%v", err)
+			}
+			continue
+		}
+
+		rawName, attrs, err := parseHTMLTag(strings.TrimSuffix(body, "/"))
+		if err != nil {
+			return "", nil, markupError(tagPos, "%v", err)
+		}
+		if rawName == "br" {
+			b.write("\n")
+			continue
+		}
+		name := htmlTagNames[rawName]
+		if name == "" {
+			return "", nil, markupError(tagPos, "unsupported tag <%s>", rawName)
+		}
+		tag := htmlTag{name: name, start: b.pos, textStart: b.text.Len(), attrs: attrs}
+
+		if name != "pre" {
+			stack = append(stack, tag)
+			continue
+		}
+
+		// pre content is literal up to the closing tag
+		closeTag := "</pre>"
+		if rest := strings.TrimLeft(s[i:], " "); strings.HasPrefix(strings.ToLower(rest), "<code") {
+			codeEnd := htmlTagEnd(rest, 0)
+			if codeEnd < 0 {
+				return "", nil, markupError(i, "unclosed tag")
+			}
+			_, codeAttrs, err := parseHTMLTag(rest[1:codeEnd])
+			if err != nil {
+				return "", nil, markupError(i, "%v", err)
+			}
+			if lang, ok := strings.CutPrefix(codeAttrs["class"], "language-"); ok {
+				tag.attrs["language"] = lang
+			}
+			i += len(s[i:]) - len(rest) + codeEnd + 1
+			closeTag = "</code></pre>"
+		}
+		contentEnd := strings.Index(strings.ToLower(s[i:]), closeTag)
+		if contentEnd < 0 {
+			return "", nil, markupError(tagPos, "unclosed tag <pre>")
+		}
+		b.write(html.UnescapeString(s[i : i+contentEnd]))
+		i += contentEnd + len(closeTag)
+		if err := closeHTMLTag(&b, tag); err != nil {
+			return "", nil, markupError(tagPos, "%v", err)
+		}
+	}
+
+	if len(stack) > 0 {
+		return "", nil, markupError(len(s), "unclosed tag <%s>", stack[len(stack)-1].name)
+	}
+	text, entities := b.result()
+	return text, entities, nil
+}
+
+// htmlTagEnd returns the index of the ">" closing the tag that starts at
+// start, skipping quoted attribute values, or -1.
+func htmlTagEnd(s string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLTag splits the inside of an opening tag into its lowercase name
+// and attributes. Attributes without a value map to "".
+func parseHTMLTag(body string) (string, map[string]string, error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(body), " ")
+	attrs := make(map[string]string)
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		end := strings.IndexAny(rest, "= ")
+		if end < 0 {
+			attrs[strings.ToLower(rest)] = ""
+			break
+		}
+		key := strings.ToLower(rest[:end])
+		if rest[end] == ' ' {
+			attrs[key] = ""
+			rest = rest[end:]
+			continue
+		}
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest == "" {
+			return "", nil, fmt.Errorf("missing value of attribute %q", key)
+		}
+		var value string
+		if q := rest[0]; q == '"' || q == '\'' {
+			closing := strings.IndexByte(rest[1:], q)
+			if closing < 0 {
+				return "", nil, fmt.Errorf("unterminated value of attribute %q", key)
+			}
+			value, rest = rest[1:closing+1], rest[closing+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		attrs[key] = html.UnescapeString(value)
+	}
+	return strings.ToLower(name), attrs, nil
+}
+
+// closeHTMLTag adds the entity of a closed tag.
+func closeHTMLTag(b *markupBuilder, tag htmlTag) error {
+	switch tag.name {
+	case "b":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityBold{Offset: o, Length: l} })
+	case "i":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityItalic{Offset: o, Length: l} })
+	case "u":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityUnderline{Offset: o, Length: l} })
+	case "s":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityStrike{Offset: o, Length: l} })
+	case "code":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityCode{Offset: o, Length: l} })
+	case "tg-spoiler":
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntitySpoiler{Offset: o, Length: l} })
+	case "span":
+		if class := tag.attrs["class"]; class != "spoiler" && class != "tg-spoiler" {
+			return fmt.Errorf("unsupported span class %q", class)
+		}
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntitySpoiler{Offset: o, Length: l} })
+	case "pre":
+		lang := tag.attrs["language"]
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass {
+			return &tg.MessageEntityPre{Offset: o, Length: l, Language: lang}
+		})
+	case "blockquote":
+		_, expandable := tag.attrs["expandable"]
+		collapsed := expandable || tag.attrs["class"] == "expandable"
+		b.add(tag.start, func(o, l int) tg.MessageEntityClass {
+			return &tg.MessageEntityBlockquote{Offset: o, Length: l, Collapsed: collapsed}
+		})
+	case "a":
+		href, ok := tag.attrs["href"]
+		if !ok {
+			return fmt.Errorf("missing href of link")
+		}
+		text := b.text.String()[tag.textStart:]
+		link, err := linkEntity(href, text)
+		if err != nil {
+			return err
+		}
+		b.add(tag.start, link)
+	}
+	return nil
+}
+
+// linkEntity returns the entity of a link to href with the given text.
+// Links written by EntitiesToHTML for mentions and bare URLs become those
+// entities again; tg://user?id= links become user mentions.
+func linkEntity(href, text string) (func(offset, length int) tg.MessageEntityClass, error) {
+	if id, ok := strings.CutPrefix(href, "tg://user?id="); ok {
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID in link %q", href)
+		}
+		return func(o, l int) tg.MessageEntityClass {
+			return &tg.InputMessageEntityMentionName{Offset: o, Length: l, UserID: &tg.InputUser{UserID: userID}}
+		}, nil
+	}
+	if username, ok := strings.CutPrefix(text, "@"); ok && href == "https://t.me/"+username {
+		return func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityMention{Offset: o, Length: l} }, nil
+	}
+	if href == text {
+		return func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityURL{Offset: o, Length: l} }, nil
+	}
+	return func(o, l int) tg.MessageEntityClass {
+		return &tg.MessageEntityTextURL{Offset: o, Length: l, URL: href}
+	}, nil
+}
+
+// markdownMark is an open Markdown marker.
+type markdownMark struct {
+	marker    string
+	start     int // UTF-16 offset of the content
+	textStart int // byte offset of the content in the output
+	pos       int // byte position in the input
+}
+
+// markdownEntities maps inline markers to their entities.
+var markdownEntities = map[string]func(offset, length int) tg.MessageEntityClass{
+	"*":  func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityBold{Offset: o, Length: l} },
+	"_":  func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityItalic{Offset: o, Length: l} },
+	"__": func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityUnderline{Offset: o, Length: l} },
+	"~":  func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityStrike{Offset: o, Length: l} },
+	"||": func(o, l int) tg.MessageEntityClass { return &tg.MessageEntitySpoiler{Offset: o, Length: l} },
+}
+
+// ParseMarkdown converts Telegram MarkdownV2 to plain text and message
+// entities with UTF-16 offsets. It supports *bold*, _italic_, __underline__,
+// ~strike~, ||spoiler||, `code`, ```lang pre```, [text](url), ">" quotes
+// and "**>" expandable quotes. Markers may nest and overlap.
+//
+// Unlike Telegram, reserved characters such as "." or "-" only need a
+// backslash where they would otherwise start formatting. Unclosed markers
+// return ErrInvalidMarkup.
+func ParseMarkdown(s string) (string, []tg.MessageEntityClass, error) {
+	var (
+		b     markupBuilder
+		stack []markdownMark
+		quote *markdownMark // open blockquote, marker "**>" if expandable
+	)
+
+	closeMark := func(marker string) bool {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].marker == marker {
+				b.add(stack[i].start, markdownEntities[marker])
+				stack = slices.Delete(stack, i, i+1)
+				return true
+			}
+		}
+		return false
+	}
+	endQuote := func() {
+		collapsed := quote.marker == "**>"
+		b.add(quote.start, func(o, l int) tg.MessageEntityClass {
+			return &tg.MessageEntityBlockquote{Offset: o, Length: l, Collapsed: collapsed}
+		})
+		quote = nil
+	}
+
+	for i := 0; i < len(s); {
+		lineStart := i == 0 || s[i-1] == '\n'
+		rest := s[i:]
+
+		switch {
+		case lineStart && strings.HasPrefix(rest, "**>"):
+			if quote == nil {
+				quote = &markdownMark{marker: "**>", start: b.pos, pos: i}
+			}
+			i += 3
+		case lineStart && rest[0] == '>':
+			if quote == nil {
+				quote = &markdownMark{marker: ">", start: b.pos, pos: i}
+			}
+			i++
+		case rest[0] == '\n':
+			if quote != nil && (i+1 == len(s) || (s[i+1] != '>' && !strings.HasPrefix(s[i+1:], "**>"))) {
+				endQuote()
+			}
+			b.write("\n")
+			i++
+		case rest[0] == '\\' && len(rest) > 1 && rest[1] > 0 && rest[1] < 127:
+			b.write(rest[1:2])
+			i += 2
+		case strings.HasPrefix(rest, "```"):
+			end := markdownCodeEnd(s, i+3, "```")
+			if end < 0 {
+				return "", nil, markupError(i, "unclosed code block")
+			}
+			content := unescapeMarkdownCode(s[i+3 : end])
+			var lang string
+			if first, body, ok := strings.Cut(content, "\n"); ok && !strings.ContainsAny(first, " \t") {
+				lang, content = first, body
+			}
+			start := b.pos
+			b.write(content)
+			b.add(start, func(o, l int) tg.MessageEntityClass {
+				return &tg.MessageEntityPre{Offset: o, Length: l, Language: lang}
+			})
+			i = end + 3
+		case rest[0] == '`':
+			end := markdownCodeEnd(s, i+1, "`")
+			if end < 0 {
+				return "", nil, markupError(i, "unclosed inline code")
+			}
+			start := b.pos
+			b.write(unescapeMarkdownCode(s[i+1 : end]))
+			b.add(start, func(o, l int) tg.MessageEntityClass { return &tg.MessageEntityCode{Offset: o, Length: l} })
+			i = end + 1
+		case rest[0] == '[':
+			stack = append(stack, markdownMark{marker: "[", start: b.pos, textStart: b.text.Len(), pos: i})
+			i++
+		case rest[0] == ']':
+			idx := -1
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].marker == "[" {
+					idx = j
+					break
+				}
+			}
+			if idx < 0 {
+				return "", nil, markupError(i, "unexpected \"]\"")
+			}
+			if !strings.HasPrefix(rest, "](") {
+				return "", nil, markupError(i, "missing link URL")
+			}
+			url, n, ok := markdownLinkURL(rest[2:])
+			if !ok {
+				return "", nil, markupError(i, "unclosed link URL")
+			}
+			link, err := linkEntity(url, b.text.String()[stack[idx].textStart:])
+			if err != nil {
+				return "", nil, markupError(i, "%v", err)
+			}
+			b.add(stack[idx].start, link)
+			stack = slices.Delete(stack, idx, idx+1)
+			i += 2 + n
+		case quote != nil && quote.marker == "**>" && strings.HasPrefix(rest, "||") &&
+			(i+2 == len(s) || s[i+2] == '\n') && !slices.ContainsFunc(stack, func(m markdownMark) bool { return m.marker == "||" }):
+			// "||" ends an expandable quote
+			i += 2
+		default:
+			marker := ""
+			for _, m := range []string{"||", "__", "*", "_", "~"} {
+				if strings.HasPrefix(rest, m) {
+					marker = m
+					break
+				}
+			}
+			if marker == "" {
+				end := strings.IndexAny(rest[1:], "\n\\`[]*_~|>") + 1
+				if end <= 0 {
+					end = len(rest)
+				}
+				b.write(rest[:end])
+				i += end
+				continue
+			}
+			if !closeMark(marker) {
+				stack = append(stack, markdownMark{marker: marker, start: b.pos, pos: i})
+			}
+			i += len(marker)
+		}
+	}
+
+	if quote != nil {
+		endQuote()
+	}
+	if len(stack) > 0 {
+		m := stack[len(stack)-1]
+		return "", nil, markupError(m.pos, "unclosed %q", m.marker)
+	}
+	text, entities := b.result()
+	return text, entities, nil
+}
+
+// markdownCodeEnd returns the index of the unescaped closing marker of a
+// code span or block whose content starts at start, or -1.
+func markdownCodeEnd(s string, start int, marker string) int {
+	for i := start; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], marker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeMarkdownCode removes the backslashes escaping "`" and "\" in code.
+func unescapeMarkdownCode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '`' || s[i+1] == '\\') {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// markdownLinkURL reads a link URL up to the unescaped ")" and returns it
+// with the number of bytes consumed, including the ")".
+func markdownLinkURL(s string) (string, int, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case ')':
+			return sb.String(), i + 1, true
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", 0, false
+}
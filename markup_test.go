@@ -0,0 +1,251 @@
+package telekit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		text     string
+		entities []tg.MessageEntityClass
+	}{
+		{
+			name:  "plain",
+			input: "a &lt;b&gt; &amp; c",
+			text:  "a <b> & c",
+		},
+		{
+			name:  "aliases",
+			input: "<b>1</b><strong>2</strong><i>3</i><em>4</em><u>5</u><ins>6</ins><s>7</s><del>8</del>",
+			text:  "12345678",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 1},
+				&tg.MessageEntityBold{Offset: 1, Length: 1},
+				&tg.MessageEntityItalic{Offset: 2, Length: 1},
+				&tg.MessageEntityItalic{Offset: 3, Length: 1},
+				&tg.MessageEntityUnderline{Offset: 4, Length: 1},
+				&tg.MessageEntityUnderline{Offset: 5, Length: 1},
+				&tg.MessageEntityStrike{Offset: 6, Length: 1},
+				&tg.MessageEntityStrike{Offset: 7, Length: 1},
+			},
+		},
+		{
+			name:  "utf16 offsets",
+			input: "Hi 👋 <b>there</b>",
+			text:  "Hi 👋 there",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 6, Length: 5},
+			},
+		},
+		{
+			name:  "nested",
+			input: "<b>bold <i>both</i></b>",
+			text:  "bold both",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 9},
+				&tg.MessageEntityItalic{Offset: 5, Length: 4},
+			},
+		},
+		{
+			name:  "pre with language",
+			input: "<pre><code class=\"language-go\">if a < b && c {}</code></pre>",
+			text:  "if a < b && c {}",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityPre{Offset: 0, Length: 16, Language: "go"},
+			},
+		},
+		{
+			name:  "expandable blockquote",
+			input: "<blockquote expandable>one<br>two</blockquote>",
+			text:  "one\ntwo",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 0, Length: 7, Collapsed: true},
+			},
+		},
+		{
+			name:  "spoilers",
+			input: "<span class=\"spoiler\">a</span><tg-spoiler>b</tg-spoiler>",
+			text:  "ab",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntitySpoiler{Offset: 0, Length: 1},
+				&tg.MessageEntitySpoiler{Offset: 1, Length: 1},
+			},
+		},
+		{
+			name: "links",
+			input: "<a href=\"https://example.com?a=1&amp;b=2\">site</a> " +
+				"<a href=\"https://go.dev\">https://go.dev</a> " +
+				"<a href=\"https://t.me/gopher\">@gopher</a> " +
+				"<a href='tg://user?id=42'>Bob</a>",
+			text: "site https://go.dev @gopher Bob",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityTextURL{Offset: 0, Length: 4, URL: "https://example.com?a=1&b=2"},
+				&tg.MessageEntityURL{Offset: 5, Length: 14},
+				&tg.MessageEntityMention{Offset: 20, Length: 7},
+				&tg.InputMessageEntityMentionName{Offset: 28, Length: 3, UserID: &tg.InputUser{UserID: 42}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, entities, err := ParseHTML(tt.input)
+			if err != nil {
+				t.Fatalf("ParseHTML() error = %v", err)
+			}
+			if text != tt.text {
+				t.Errorf("ParseHTML() text = %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(entities, tt.entities) {
+				t.Errorf("ParseHTML() entities = %+v, want %+v", entities, tt.entities)
+			}
+		})
+	}
+}
+
+func TestParseHTMLErrors(t *testing.T) {
+	inputs := []string{
+		"<b>unclosed",
+		"<b><i>misnested</b></i>",
+		"</b>",
+		"<marquee>old</marquee>",
+		"<span>plain</span>",
+		"<a>no href</a>",
+		"<a href=\"tg://user?id=bob\">x</a>",
+		"<b",
+		"<pre>no end",
+	}
+	for _, input := range inputs {
+		if _, _, err := ParseHTML(input); !errors.Is(err, ErrInvalidMarkup) {
+			t.Errorf("ParseHTML(%q) error = %v, want %v", input, err, ErrInvalidMarkup)
+		}
+	}
+}
+
+func TestParseHTMLRoundTrip(t *testing.T) {
+	text := "Hi 👋 bold italic code\nquote line\nmore @gopher https://go.dev link spoiler\nfunc main() {}"
+	entities := []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 6, Length: 11},
+		&tg.MessageEntityItalic{Offset: 11, Length: 6},
+		&tg.MessageEntityCode{Offset: 18, Length: 4},
+		&tg.MessageEntityBlockquote{Offset: 23, Length: 15, Collapsed: true},
+		&tg.MessageEntityMention{Offset: 39, Length: 7},
+		&tg.MessageEntityURL{Offset: 47, Length: 14},
+		&tg.MessageEntityTextURL{Offset: 62, Length: 4, URL: "https://example.com/?q=<1>"},
+		&tg.MessageEntitySpoiler{Offset: 67, Length: 7},
+		&tg.MessageEntityPre{Offset: 75, Length: 14, Language: "go"},
+	}
+
+	html := EntitiesToHTML(text, entities)
+	gotText, gotEntities, err := ParseHTML(html)
+	if err != nil {
+		t.Fatalf("ParseHTML(%q) error = %v", html, err)
+	}
+	if gotText != text {
+		t.Errorf("round-trip text = %q, want %q", gotText, text)
+	}
+	if !reflect.DeepEqual(gotEntities, entities) {
+		t.Errorf("round-trip entities of %q =\n%+v\nwant\n%+v", html, gotEntities, entities)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		text     string
+		entities []tg.MessageEntityClass
+	}{
+		{
+			name:  "inline",
+			input: "*b* _i_ __u__ ~s~ ||sp|| `c`",
+			text:  "b i u s sp c",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 1},
+				&tg.MessageEntityItalic{Offset: 2, Length: 1},
+				&tg.MessageEntityUnderline{Offset: 4, Length: 1},
+				&tg.MessageEntityStrike{Offset: 6, Length: 1},
+				&tg.MessageEntitySpoiler{Offset: 8, Length: 2},
+				&tg.MessageEntityCode{Offset: 11, Length: 1},
+			},
+		},
+		{
+			name:  "escapes and punctuation",
+			input: `1\*2 = 2. Done - ok\_`,
+			text:  "1*2 = 2. Done - ok_",
+		},
+		{
+			name:  "nested with emoji",
+			input: "👋 *bold _both_*",
+			text:  "👋 bold both",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 3, Length: 9},
+				&tg.MessageEntityItalic{Offset: 8, Length: 4},
+			},
+		},
+		{
+			name:  "code block",
+			input: "```go\nfmt.Println(`\\`x`)\n```",
+			text:  "fmt.Println(``x`)\n",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityPre{Offset: 0, Length: 18, Language: "go"},
+			},
+		},
+		{
+			name:  "links",
+			input: "[site](https://example.com/a_\\(b\\)) [*Bob*](tg://user?id=42)",
+			text:  "site Bob",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityTextURL{Offset: 0, Length: 4, URL: "https://example.com/a_(b)"},
+				&tg.MessageEntityBold{Offset: 5, Length: 3},
+				&tg.InputMessageEntityMentionName{Offset: 5, Length: 3, UserID: &tg.InputUser{UserID: 42}},
+			},
+		},
+		{
+			name:  "quotes",
+			input: ">one\n>two\nafter\n**>hidden\n>more||",
+			text:  "one\ntwo\nafter\nhidden\nmore",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 0, Length: 7},
+				&tg.MessageEntityBlockquote{Offset: 14, Length: 11, Collapsed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, entities, err := ParseMarkdown(tt.input)
+			if err != nil {
+				t.Fatalf("ParseMarkdown() error = %v", err)
+			}
+			if text != tt.text {
+				t.Errorf("ParseMarkdown() text = %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(entities, tt.entities) {
+				t.Errorf("ParseMarkdown() entities = %+v, want %+v", entities, tt.entities)
+			}
+		})
+	}
+}
+
+func TestParseMarkdownErrors(t *testing.T) {
+	inputs := []string{
+		"*unclosed",
+		"`code",
+		"```pre",
+		"[text]",
+		"[text](url",
+		"text]",
+	}
+	for _, input := range inputs {
+		if _, _, err := ParseMarkdown(input); !errors.Is(err, ErrInvalidMarkup) {
+			t.Errorf("ParseMarkdown(%q) error = %v, want %v", input, err, ErrInvalidMarkup)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package telekit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// Edit replaces the text of the message msgID in the current chat, usually
+// one the bot sent earlier, and returns the ID of the edited message. The
+// inline keyboard is removed unless opts attach one.
+func (c *Context) Edit(msgID int, text string, opts ...SendOption) (int, error) {
+	return c.EditStyled(msgID, text, nil, opts...)
+}
+
+// EditStyled replaces the text of the message msgID in the current chat
+// with text formatted by entities and returns the ID of the edited message.
+func (c *Context) EditStyled(msgID int, text string, entities []tg.MessageEntityClass, opts ...SendOption) (int, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return 0, ErrNoMessage
+	}
+	req := &tg.MessagesEditMessageRequest{Peer: peer, ID: msgID}
+	req.SetMessage(text)
+	if len(entities) > 0 {
+		req.SetEntities(entities)
+	}
+	return c.bot.editMessage(c, req, opts)
+}
+
+// EditMarkup replaces the inline keyboard of the message msgID in the
+// current chat with the one attached by opts, or removes it if there is
+// none, and returns the ID of the edited message. The text is kept.
+func (c *Context) EditMarkup(msgID int, opts ...SendOption) (int, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return 0, ErrNoMessage
+	}
+	req := &tg.MessagesEditMessageRequest{Peer: peer, ID: msgID}
+	// an empty keyboard removes the current one
+	req.SetReplyMarkup(&tg.ReplyInlineMarkup{})
+	return c.bot.editMessage(c, req, opts)
+}
+
+// EditCaption replaces the caption of the media message msgID in the
+// current chat with caption formatted by entities and returns the ID of
+// the edited message. An empty caption removes the caption; use
+// EditMarkup to change only the keyboard. The inline keyboard is removed
+// unless opts attach one.
+func (c *Context) EditCaption(msgID int, caption string, entities []tg.MessageEntityClass, opts ...SendOption) (int, error) {
+	return c.EditStyled(msgID, caption, entities, opts...)
+}
+
+// Delete deletes messages in the current chat for everyone, or the current
+// message if msgIDs is empty.
+func (c *Context) Delete(msgIDs ...int) error {
+	peer := c.inputPeer()
+	if peer == nil {
+		return ErrNoMessage
+	}
+	if len(msgIDs) == 0 {
+		msgIDs = []int{c.message.ID}
+	}
+	if err := c.bot.deleteMessages(c, peer, msgIDs); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	return nil
+}
+
+// Pin pins the message msgID in the current chat. silent pins it without
+// notifying chat members. It returns the ID of the "pinned a message"
+// service message, or 0 if Telegram created none, e.g. because the
+// message was already pinned.
+func (c *Context) Pin(msgID int, silent bool) (int, error) {
+	return c.updatePinned(msgID, silent, false)
+}
+
+// Unpin unpins the message msgID in the current chat. Unpinning creates no
+// message, so there is no ID to return.
+func (c *Context) Unpin(msgID int) error {
+	_, err := c.updatePinned(msgID, false, true)
+	return err
+}
+
+func (c *Context) updatePinned(msgID int, silent, unpin bool) (int, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return 0, ErrNoMessage
+	}
+	upd, err := c.bot.api.MessagesUpdatePinnedMessage(c, &tg.MessagesUpdatePinnedMessageRequest{
+		Peer:   peer,
+		ID:     msgID,
+		Silent: silent,
+		Unpin:  unpin,
+	})
+	if tg.IsMessageNotModified(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update pinned message: %w", err)
+	}
+	return sentMessageID(upd), nil
+}
+
+// Forward forwards messages of the current chat to another chat, or the
+// current message if msgIDs is empty. It returns the IDs of the new
+// messages in the order of msgIDs.
+func (c *Context) Forward(to Peer, msgIDs ...int) ([]int, error) {
+	return c.forward(to, msgIDs, false)
+}
+
+// Copy sends copies of messages of the current chat to another chat, or of
+// the current message if msgIDs is empty. Copies keep their media and
+// entities but have no forward header. It returns the IDs of the new
+// messages in the order of msgIDs.
+func (c *Context) Copy(to Peer, msgIDs ...int) ([]int, error) {
+	return c.forward(to, msgIDs, true)
+}
+
+func (c *Context) forward(to Peer, msgIDs []int, dropAuthor bool) ([]int, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return nil, ErrNoMessage
+	}
+	if len(msgIDs) == 0 {
+		msgIDs = []int{c.message.ID}
+	}
+	ids, err := c.bot.forwardMessages(c, peer, to.InputPeer(), msgIDs, dropAuthor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to forward messages: %w", err)
+	}
+	return ids, nil
+}
+
+// EditMessage replaces the text of the message with the pressed button
+// and returns its ID. The inline keyboard is removed unless opts attach
+// one.
+func (c *CallbackContext) EditMessage(text string, opts ...SendOption) (int, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return 0, ErrNoMessage
+	}
+	req := &tg.MessagesEditMessageRequest{Peer: peer, ID: c.msgID}
+	req.SetMessage(text)
+	return c.bot.editMessage(c, req, opts)
+}
+
+// inputPeer returns the chat of the pressed button as an input peer.
+func (c *CallbackContext) inputPeer() tg.InputPeerClass {
+	if c.query == nil {
+		return nil
+	}
+	return inputPeerOf(c.query.Peer, c.entities)
+}
+
+// editMessage sends req with the inline keyboard attached by opts and
+// returns the ID of the edited message, which edits keep. Edits that
+// change nothing are not an error.
+func (b *Bot) editMessage(ctx context.Context, req *tg.MessagesEditMessageRequest, opts []SendOption) (int, error) {
+	o, err := applySendOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	if o.markup != nil {
+		if _, ok := o.markup.(*tg.ReplyInlineMarkup); !ok {
+			return 0, fmt.Errorf("%w: only inline keyboards can be attached to edited messages", ErrInvalidKeyboard)
+		}
+		req.SetReplyMarkup(o.markup)
+	}
+	if _, err := b.api.MessagesEditMessage(ctx, req); err != nil && !tg.IsMessageNotModified(err) {
+		return 0, fmt.Errorf("failed to edit message: %w", err)
+	}
+	return req.ID, nil
+}
+
+// deleteMessages deletes messages in peer for everyone.
+func (b *Bot) deleteMessages(ctx context.Context, peer tg.InputPeerClass, ids []int) error {
+	if ch, ok := peer.(*tg.InputPeerChannel); ok {
+		_, err := b.api.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash},
+			ID:      ids,
+		})
+		return err
+	}
+	_, err := b.api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{Revoke: true, ID: ids})
+	return err
+}
+
+// forwardMessages forwards ids from one peer to another and returns the IDs
+// of the new messages. dropAuthor removes the forward header.
+func (b *Bot) forwardMessages(ctx context.Context, from, to tg.InputPeerClass, ids []int, dropAuthor bool) ([]int, error) {
+	randomIDs := make([]int64, len(ids))
+	for i := range randomIDs {
+		randomIDs[i] = randomID()
+	}
+	upd, err := b.api.MessagesForwardMessages(ctx, &tg.MessagesForwardMessagesRequest{
+		FromPeer:   from,
+		ToPeer:     to,
+		ID:         ids,
+		RandomID:   randomIDs,
+		DropAuthor: dropAuthor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return forwardedMessageIDs(upd, randomIDs), nil
+}
+
+// forwardedMessageIDs returns the IDs of forwarded messages in the order of
+// randomIDs; IDs missing from upd are zero.
+func forwardedMessageIDs(upd tg.UpdatesClass, randomIDs []int64) []int {
+	var updates []tg.UpdateClass
+	switch u := upd.(type) {
+	case *tg.Updates:
+		updates = u.Updates
+	case *tg.UpdatesCombined:
+		updates = u.Updates
+	}
+
+	byRandomID := make(map[int64]int, len(updates))
+	for _, update := range updates {
+		if u, ok := update.(*tg.UpdateMessageID); ok {
+			byRandomID[u.RandomID] = u.ID
+		}
+	}
+	ids := make([]int, len(randomIDs))
+	for i, r := range randomIDs {
+		ids[i] = byRandomID[r]
+	}
+	return ids
+}
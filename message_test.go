@@ -0,0 +1,163 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestDeleteMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		peer tg.PeerClass
+		ids  []int
+		want any
+	}{
+		{
+			"channel",
+			&tg.PeerChannel{ChannelID: 10},
+			[]int{5, 6},
+			&tg.ChannelsDeleteMessagesRequest{Channel: &tg.InputChannel{ChannelID: 10, AccessHash: 99}, ID: []int{5, 6}},
+		},
+		{
+			"current message",
+			&tg.PeerUser{UserID: 1},
+			nil,
+			&tg.MessagesDeleteMessagesRequest{Revoke: true, ID: []int{3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &recordingInvoker{}
+			ctx := &Context{
+				Context:  context.Background(),
+				bot:      &Bot{api: tg.NewClient(invoker)},
+				message:  &tg.Message{ID: 3, PeerID: tt.peer},
+				entities: tg.Entities{Channels: map[int64]*tg.Channel{10: {ID: 10, AccessHash: 99}}},
+			}
+			if err := ctx.Delete(tt.ids...); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if len(invoker.requests) != 1 || !reflect.DeepEqual(invoker.requests[0], tt.want) {
+				t.Errorf("Delete() requests = %#v, want %#v", invoker.requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	invoker := &recordingInvoker{}
+	b := &Bot{api: tg.NewClient(invoker)}
+	ctx := &Context{
+		Context:  context.Background(),
+		bot:      b,
+		message:  &tg.Message{ID: 3, PeerID: &tg.PeerUser{UserID: 1}},
+		entities: tg.Entities{Users: map[int64]*tg.User{1: {ID: 1, AccessHash: 7}}},
+	}
+
+	if id, err := ctx.EditMarkup(8); err != nil || id != 8 {
+		t.Fatalf("EditMarkup() = %d, %v, want 8", id, err)
+	}
+	req := invoker.requests[0].(*tg.MessagesEditMessageRequest)
+	if markup, ok := req.GetReplyMarkup(); !ok || !reflect.DeepEqual(markup, &tg.ReplyInlineMarkup{}) {
+		t.Errorf("EditMarkup() markup = %#v, want empty inline markup", markup)
+	}
+	if _, ok := req.GetMessage(); ok {
+		t.Error("EditMarkup() changed the text")
+	}
+
+	_, err := ctx.Edit(8, "text", NewReplyKeyboard().Row(TextButton("A")))
+	if !errors.Is(err, ErrInvalidKeyboard) {
+		t.Errorf("Edit() with reply keyboard error = %v, want %v", err, ErrInvalidKeyboard)
+	}
+
+	cb := &CallbackContext{
+		Context:  context.Background(),
+		bot:      b,
+		query:    &tg.UpdateBotCallbackQuery{Peer: &tg.PeerUser{UserID: 1}, MsgID: 4},
+		msgID:    4,
+		entities: ctx.entities,
+	}
+	kb := NewInlineKeyboard().Row(CallbackButton("A", "a"))
+	if id, err := cb.EditMessage("done", kb); err != nil || id != 4 {
+		t.Fatalf("EditMessage() = %d, %v, want 4", id, err)
+	}
+	req = invoker.requests[len(invoker.requests)-1].(*tg.MessagesEditMessageRequest)
+	if !reflect.DeepEqual(req.Peer, &tg.InputPeerUser{UserID: 1, AccessHash: 7}) || req.ID != 4 || req.Message != "done" {
+		t.Errorf("EditMessage() request = %#v", req)
+	}
+	if _, ok := req.ReplyMarkup.(*tg.ReplyInlineMarkup); !ok {
+		t.Errorf("EditMessage() markup = %T, want *tg.ReplyInlineMarkup", req.ReplyMarkup)
+	}
+}
+
+func TestForwardedMessageIDs(t *testing.T) {
+	upd := &tg.Updates{Updates: []tg.UpdateClass{
+		&tg.UpdateMessageID{ID: 20, RandomID: 2},
+		&tg.UpdateNewMessage{Message: &tg.Message{ID: 20}},
+		&tg.UpdateMessageID{ID: 10, RandomID: 1},
+	}}
+	if got := forwardedMessageIDs(upd, []int64{1, 2, 3}); !reflect.DeepEqual(got, []int{10, 20, 0}) {
+		t.Errorf("forwardedMessageIDs() = %v, want [10 20 0]", got)
+	}
+	if got := forwardedMessageIDs(&tg.UpdatesTooLong{}, []int64{1}); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("forwardedMessageIDs() = %v, want [0]", got)
+	}
+}
+
+func TestEditCaption(t *testing.T) {
+	invoker := &recordingInvoker{}
+	ctx := &Context{
+		Context: context.Background(),
+		bot:     &Bot{api: tg.NewClient(invoker)},
+		message: &tg.Message{ID: 3, PeerID: &tg.PeerUser{UserID: 1}},
+	}
+
+	bold := []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 3}}
+	if id, err := ctx.EditCaption(8, "new", bold); err != nil || id != 8 {
+		t.Fatalf("EditCaption() = %d, %v, want 8", id, err)
+	}
+	req := invoker.requests[0].(*tg.MessagesEditMessageRequest)
+	if caption, ok := req.GetMessage(); !ok || caption != "new" || !reflect.DeepEqual(req.Entities, bold) {
+		t.Errorf("EditCaption() request = %#v", req)
+	}
+
+	if _, err := ctx.EditCaption(8, "", nil); err != nil {
+		t.Fatalf("EditCaption() error = %v", err)
+	}
+	req = invoker.requests[1].(*tg.MessagesEditMessageRequest)
+	if caption, ok := req.GetMessage(); !ok || caption != "" {
+		t.Errorf("EditCaption() with empty caption = %q, %v, want the caption removed", caption, ok)
+	}
+}
+
+// respondingInvoker answers every request with response.
+type respondingInvoker struct {
+	response bin.Encoder
+}
+
+func (r respondingInvoker) Invoke(_ context.Context, _ bin.Encoder, output bin.Decoder) error {
+	var buf bin.Buffer
+	if err := r.response.Encode(&buf); err != nil {
+		return err
+	}
+	return output.Decode(&buf)
+}
+
+func TestPinReturnsServiceMessage(t *testing.T) {
+	upd := &tg.Updates{Updates: []tg.UpdateClass{
+		&tg.UpdateNewMessage{Message: &tg.MessageService{ID: 12, PeerID: &tg.PeerUser{UserID: 1}, Action: &tg.MessageActionPinMessage{}}},
+	}}
+	ctx := &Context{
+		Context: context.Background(),
+		bot:     &Bot{api: tg.NewClient(respondingInvoker{response: &tg.UpdatesBox{Updates: upd}})},
+		message: &tg.Message{ID: 3, PeerID: &tg.PeerUser{UserID: 1}},
+	}
+	if id, err := ctx.Pin(3, true); err != nil || id != 12 {
+		t.Errorf("Pin() = %d, %v, want 12", id, err)
+	}
+}
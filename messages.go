@@ -0,0 +1,264 @@
+package telekit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ErrorReplyMode controls where command errors are reported.
+type ErrorReplyMode int
+
+const (
+	// ErrorReplyInChat replies to the command message in the originating chat.
+	ErrorReplyInChat ErrorReplyMode = iota
+
+	// ErrorReplyPrivate sends the error to the sender's private chat.
+	// This fails if the user never started the bot.
+	ErrorReplyPrivate
+
+	// ErrorReplySilent does not report errors to the user.
+	ErrorReplySilent
+)
+
+// ParamErrorFunc handles a rejected command invocation. err is a *ParseError
+// or ValidationErrors. Setting it replaces the built-in error reply;
+// use Context.ErrorText to render the configured message.
+type ParamErrorFunc func(ctx *Context, err error) error
+
+// Messages holds the user-facing texts of built-in replies.
+//
+// Validation templates use text/template syntax and receive the *ParamError,
+// e.g. `Parameter "{{.Param}}" must be at least {{.Limit}}`. The Syntax
+// template receives the *ParseError. Available functions are join (for enum
+// values) and type (a readable name of a ParamType). Empty fields fall back
+// to the English defaults.
+type Messages struct {
+	// Validation maps each validation kind to its template.
+	Validation map[ValidationKind]string
+
+	// Syntax is the template for malformed command text.
+	Syntax string
+
+	// Usage is the label in front of the command usage line.
+	Usage string
+
+	// HelpHeader is the first line of the command list.
+	HelpHeader string
+
+	// HelpFooter follows the command list; it receives {{.Command}}.
+	HelpFooter string
+
+	// HelpEmpty is sent when no commands are visible to the user.
+	HelpEmpty string
+
+	// UnknownCommand is sent by help for unknown names; it receives {{.Command}}.
+	UnknownCommand string
+
+	// RateLimited is sent when a command hits its cooldown or rate limit;
+	// it receives {{.Command}}, {{.Wait}} (e.g. "3s") and {{.Seconds}}.
+	RateLimited string
+
+	// AdminRequired is sent when a command needs admin rights the sender
+	// lacks; it receives {{.Command}}.
+	AdminRequired string
+
+	// CommandLocked is sent when a locked command is still running;
+	// it receives {{.Command}}.
+	CommandLocked string
+
+	// Cancelled confirms the built-in cancel command.
+	Cancelled string
+
+	// NothingToCancel is sent by the cancel command if nothing is running.
+	NothingToCancel string
+
+	// ProgressFailed replaces a status message on Progress.Fail;
+	// it receives {{.Error}}.
+	ProgressFailed string
+}
+
+// DefaultMessages returns the built-in English texts.
+func DefaultMessages() Messages {
+	return Messages{
+		Validation: map[ValidationKind]string{
+			ValidationRequired:   `Parameter "{{.Param}}" is required.`,
+			ValidationUnknown:    `Unknown parameter "{{.Param}}".`,
+			ValidationType:       `Parameter "{{.Param}}" must be {{type .Limit}}, got "{{.Value}}".`,
+			ValidationEnum:       `Parameter "{{.Param}}" must be one of: {{join .Limit ", "}}.`,
+			ValidationMin:        `Parameter "{{.Param}}" must be at least {{.Limit}}.`,
+			ValidationMax:        `Parameter "{{.Param}}" must be at most {{.Limit}}.`,
+			ValidationMinLen:     `Parameter "{{.Param}}" is too short (minimum {{.Limit}}).`,
+			ValidationMaxLen:     `Parameter "{{.Param}}" is too long (maximum {{.Limit}}).`,
+			ValidationPattern:    `Parameter "{{.Param}}" has an invalid format.`,
+			ValidationNotFound:   `Could not find "{{.Value}}" for parameter "{{.Param}}".`,
+			ValidationSubcommand: `{{if .Value}}Unknown subcommand "{{.Value}}".{{else}}A subcommand is required.{{end}}`,
+			ValidationCustom:     `Invalid parameter "{{.Param}}": {{.Err}}`,
+			ValidationCommand:    `{{.Msg}}`,
+		},
+		Syntax:          `Syntax error at position {{.Pos}}: {{.Msg}}.`,
+		Usage:           "Usage:",
+		HelpHeader:      "Available commands:",
+		HelpFooter:      "Send /{{.Command}} <command> for details.",
+		HelpEmpty:       "No commands available.",
+		UnknownCommand:  "Unknown command /{{.Command}}",
+		RateLimited:     "Too many requests. Try again in {{.Wait}}.",
+		AdminRequired:   "Only chat administrators with the required rights can use /{{.Command}}.",
+		CommandLocked:   "Please wait until your previous command finishes.",
+		Cancelled:       "Cancelled.",
+		NothingToCancel: "Nothing to cancel.",
+		ProgressFailed:  "Failed: {{.Error}}",
+	}
+}
+
+// merge fills empty fields of m from fallback.
+func (m Messages) merge(fallback Messages) Messages {
+	validation := make(map[ValidationKind]string, len(fallback.Validation))
+	for k, v := range fallback.Validation {
+		validation[k] = v
+	}
+	for k, v := range m.Validation {
+		if v != "" {
+			validation[k] = v
+		}
+	}
+	m.Validation = validation
+
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&m.Syntax, fallback.Syntax},
+		{&m.Usage, fallback.Usage},
+		{&m.HelpHeader, fallback.HelpHeader},
+		{&m.HelpFooter, fallback.HelpFooter},
+		{&m.HelpEmpty, fallback.HelpEmpty},
+		{&m.UnknownCommand, fallback.UnknownCommand},
+		{&m.RateLimited, fallback.RateLimited},
+		{&m.AdminRequired, fallback.AdminRequired},
+		{&m.CommandLocked, fallback.CommandLocked},
+		{&m.Cancelled, fallback.Cancelled},
+		{&m.NothingToCancel, fallback.NothingToCancel},
+		{&m.ProgressFailed, fallback.ProgressFailed},
+	}
+	for _, f := range fields {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	return m
+}
+
+// messagesFor returns the texts for a language code. It tries the exact
+// code, then its base language ("pt-br" -> "pt"), then the "" entry,
+// then the English defaults.
+func (b *Bot) messagesFor(langCode string) Messages {
+	m := DefaultMessages()
+	if fallback, ok := b.config.Messages[""]; ok {
+		m = fallback.merge(m)
+	}
+
+	langCode = strings.ToLower(langCode)
+	if base, _, found := strings.Cut(langCode, "-"); found {
+		if lm, ok := b.config.Messages[base]; ok {
+			m = lm.merge(m)
+		}
+	}
+	if langCode != "" {
+		if lm, ok := b.config.Messages[langCode]; ok {
+			m = lm.merge(m)
+		}
+	}
+	return m
+}
+
+// ErrorText renders a command error in the sender's language using the
+// configured Messages. ValidationErrors produce one line per error.
+func (c *Context) ErrorText(err error) string {
+	m := c.bot.messagesFor(c.LangCode())
+
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return renderMessage(m.Syntax, perr)
+	}
+
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		lines := make([]string, len(verrs))
+		for i, e := range verrs {
+			lines[i] = renderMessage(m.Validation[e.Kind], e)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	return err.Error()
+}
+
+var templateCache sync.Map // string -> *template.Template
+
+var templateFuncs = template.FuncMap{
+	"join": func(v any, sep string) string {
+		if items, ok := v.([]string); ok {
+			return strings.Join(items, sep)
+		}
+		return fmt.Sprint(v)
+	},
+	"type": func(v any) string {
+		if t, ok := v.(ParamType); ok {
+			return typeName(t)
+		}
+		return fmt.Sprint(v)
+	},
+}
+
+// renderMessage executes a message template. Invalid templates are
+// returned as-is so a typo never hides the error from the user.
+func renderMessage(text string, data any) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	var tmpl *template.Template
+	if cached, ok := templateCache.Load(text); ok {
+		tmpl = cached.(*template.Template)
+	} else {
+		parsed, err := template.New("message").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return text
+		}
+		templateCache.Store(text, parsed)
+		tmpl = parsed
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return text
+	}
+	return buf.String()
+}
+
+// typeName is the readable name of a parameter type used in error messages.
+func typeName(t ParamType) string {
+	switch t {
+	case TypeInt:
+		return "a whole number"
+	case TypeFloat:
+		return "a number"
+	case TypeBool:
+		return "yes or no"
+	case TypeDuration:
+		return "a duration like 1h30m"
+	case TypeTime:
+		return "a date like 2006-01-02"
+	case TypeURL:
+		return "a URL"
+	case TypeUser:
+		return "a @username, user ID or mention"
+	case TypeChat:
+		return "a chat @username or ID"
+	}
+	return "a " + string(t)
+}
@@ -0,0 +1,62 @@
+package telekit
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestErrorText(t *testing.T) {
+	b := &Bot{config: Config{Messages: map[string]Messages{
+		"de": {Validation: map[ValidationKind]string{
+			ValidationRequired: `Parameter "{{.Param}}" fehlt.`,
+		}},
+	}}}
+
+	newCtx := func(lang string) *Context {
+		return &Context{
+			bot:     b,
+			message: &tg.Message{PeerID: &tg.PeerUser{UserID: 1}},
+			entities: tg.Entities{Users: map[int64]*tg.User{
+				1: {ID: 1, LangCode: lang},
+			}},
+		}
+	}
+
+	errs := ValidationErrors{
+		{Kind: ValidationRequired, Param: "title"},
+		{Kind: ValidationEnum, Param: "mode", Value: "x", Limit: []string{"a", "b"}},
+		{Kind: ValidationType, Param: "limit", Value: "ten", Limit: TypeInt},
+	}
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"en", "Parameter \"title\" is required.\n" +
+			"Parameter \"mode\" must be one of: a, b.\n" +
+			"Parameter \"limit\" must be a whole number, got \"ten\"."},
+		{"de-at", "Parameter \"title\" fehlt.\n" +
+			"Parameter \"mode\" must be one of: a, b.\n" +
+			"Parameter \"limit\" must be a whole number, got \"ten\"."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			if got := newCtx(tt.lang).ErrorText(errs); got != tt.want {
+				t.Errorf("ErrorText() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	perr := &ParseError{Pos: 7, Msg: "unterminated double quote"}
+	if got, want := newCtx("en").ErrorText(perr), "Syntax error at position 7: unterminated double quote."; got != want {
+		t.Errorf("ErrorText(ParseError) = %q, want %q", got, want)
+	}
+}
+
+func TestRenderMessageInvalidTemplate(t *testing.T) {
+	if got := renderMessage("broken {{.Param", &ParamError{}); got != "broken {{.Param" {
+		t.Errorf("renderMessage() = %q", got)
+	}
+}
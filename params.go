@@ -2,9 +2,11 @@ package telekit
 
 import (
 	"fmt"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ParamType defines the type of a command parameter.
@@ -15,13 +17,40 @@ const (
 	TypeInt    ParamType = "int"
 	TypeBool   ParamType = "bool"
 	TypeEnum   ParamType = "enum"
+
+	// TypeFloat is a float64 value.
+	TypeFloat ParamType = "float"
+
+	// TypeDuration is a time.Duration such as "90s", "1h30m" or "2d12h".
+	TypeDuration ParamType = "duration"
+
+	// TypeTime is a time.Time in RFC 3339, "2006-01-02 15:04" or "2006-01-02" format.
+	// Values without a zone are interpreted as UTC.
+	TypeTime ParamType = "time"
+
+	// TypeURL is an absolute URL with scheme and host, parsed into *url.URL.
+	TypeURL ParamType = "url"
+
+	// TypeList is a comma-separated list of values of type ParamSchema.Elem.
+	TypeList ParamType = "list"
+
+	// TypeUser is a Telegram user given as @username, numeric ID or a text
+	// mention, resolved to a Peer through the bot's API.
+	TypeUser ParamType = "user"
+
+	// TypeChat is a Telegram group or channel given as @username or numeric ID
+	// (Bot API style -100... IDs are accepted), resolved to a Peer.
+	TypeChat ParamType = "chat"
 )
 
 // ParamSchema defines validation rules for a command parameter.
 type ParamSchema struct {
-	// Type is the parameter type (string, int, bool, enum).
+	// Type is the parameter type (see the Type* constants).
 	Type ParamType
 
+	// Elem is the element type for TypeList (default: TypeString).
+	Elem ParamType
+
 	// Required indicates if the parameter must be provided.
 	Required bool
 
@@ -31,6 +60,24 @@ type ParamSchema struct {
 	// Enum contains allowed values for enum type.
 	Enum []string
 
+	// Min and Max bound numeric, duration and time values (and the items of
+	// lists of those types). Use a value of the matching Go type, e.g.
+	// 10, 0.5, time.Minute or a time.Time. Nil means no bound.
+	Min any
+	Max any
+
+	// MinLen and MaxLen bound the length of strings (in characters) and
+	// lists (in items). Zero means no bound.
+	MinLen int
+	MaxLen int
+
+	// Pattern is a regular expression string values must match.
+	// Use ^ and $ to match the whole value.
+	Pattern string
+
+	// Validate is called with the converted value after the built-in checks.
+	Validate func(value any) error
+
 	// Description is a human-readable description for help text.
 	Description string
 }
@@ -65,29 +112,242 @@ func (p ParsedParams) Bool(key string) bool {
 	return false
 }
 
+// Float returns the float64 value of a parameter.
+func (p ParsedParams) Float(key string) float64 {
+	if v, ok := p[key].(float64); ok {
+		return v
+	}
+	return 0
+}
+
+// Duration returns the time.Duration value of a parameter.
+func (p ParsedParams) Duration(key string) time.Duration {
+	if v, ok := p[key].(time.Duration); ok {
+		return v
+	}
+	return 0
+}
+
+// Time returns the time.Time value of a parameter.
+func (p ParsedParams) Time(key string) time.Time {
+	if v, ok := p[key].(time.Time); ok {
+		return v
+	}
+	return time.Time{}
+}
+
+// URL returns the *url.URL value of a parameter, or nil.
+func (p ParsedParams) URL(key string) *url.URL {
+	if v, ok := p[key].(*url.URL); ok {
+		return v
+	}
+	return nil
+}
+
+// Strings returns a list parameter of strings or enum values.
+func (p ParsedParams) Strings(key string) []string {
+	if v, ok := p[key].([]string); ok {
+		return v
+	}
+	return nil
+}
+
+// Ints returns a list parameter of ints.
+func (p ParsedParams) Ints(key string) []int64 {
+	if v, ok := p[key].([]int64); ok {
+		return v
+	}
+	return nil
+}
+
+// Floats returns a list parameter of floats.
+func (p ParsedParams) Floats(key string) []float64 {
+	if v, ok := p[key].([]float64); ok {
+		return v
+	}
+	return nil
+}
+
+// Durations returns a list parameter of durations.
+func (p ParsedParams) Durations(key string) []time.Duration {
+	if v, ok := p[key].([]time.Duration); ok {
+		return v
+	}
+	return nil
+}
+
+// Bools returns a list parameter of bools.
+func (p ParsedParams) Bools(key string) []bool {
+	if v, ok := p[key].([]bool); ok {
+		return v
+	}
+	return nil
+}
+
+// Times returns a list parameter of times.
+func (p ParsedParams) Times(key string) []time.Time {
+	if v, ok := p[key].([]time.Time); ok {
+		return v
+	}
+	return nil
+}
+
+// URLs returns a list parameter of URLs.
+func (p ParsedParams) URLs(key string) []*url.URL {
+	if v, ok := p[key].([]*url.URL); ok {
+		return v
+	}
+	return nil
+}
+
+// Peer returns a resolved user or chat parameter.
+func (p ParsedParams) Peer(key string) Peer {
+	if v, ok := p[key].(Peer); ok {
+		return v
+	}
+	return Peer{}
+}
+
+// Peers returns a list parameter of resolved users or chats.
+func (p ParsedParams) Peers(key string) []Peer {
+	if v, ok := p[key].([]Peer); ok {
+		return v
+	}
+	return nil
+}
+
 // Has returns true if the parameter was provided.
 func (p ParsedParams) Has(key string) bool {
 	_, ok := p[key]
 	return ok
 }
 
-// parseParams parses command text and validates against schema.
-// Command format: /command key1=value1 key2=value2
-func parseParams(text string, schema Params) (ParsedParams, error) {
-	parts := strings.Fields(text)
-	if len(parts) == 0 {
-		return nil, nil
+// commandLine is a tokenized and validated command message.
+type commandLine struct {
+	// name is the command name without the leading slash and @botname suffix.
+	name string
+
+	// mention is the @botname suffix without the "@", empty if absent.
+	mention string
+
+	// args holds positional (non key=value) arguments in order.
+	args []string
+
+	// body is the message text after the first line not consumed by heredocs.
+	body string
+
+	// raw holds named arguments before conversion.
+	raw map[string]string
+
+	params ParsedParams
+}
+
+// splitCommandName extracts the command name and @botname suffix from the
+// first word of text.
+func splitCommandName(text string) (name, mention string) {
+	end := strings.IndexAny(text, " \t\r\n")
+	if end < 0 {
+		end = len(text)
+	}
+	name = strings.TrimPrefix(text[:end], "/")
+	if idx := strings.Index(name, "@"); idx > 0 {
+		name, mention = name[:idx], name[idx+1:]
+	}
+	return name, mention
+}
+
+// parseCommand tokenizes command text and validates it against schema.
+// Command format: /command key1=value1 --key2 value2 --flag "quoted value"
+func parseCommand(text string, schema Params) (*commandLine, error) {
+	tokens, body, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	return parseTokens(tokens, body, 0, schema)
+}
+
+// parseTokens builds a command line from tokenized text. The first token is
+// the command word, followed by skip subcommand words, followed by arguments.
+func parseTokens(tokens []token, body string, skip int, schema Params) (*commandLine, error) {
+	if len(tokens) == 0 {
+		return &commandLine{}, nil
+	}
+
+	cmd := &commandLine{body: body}
+	cmd.name, cmd.mention = splitCommandName(tokens[0].value)
+
+	raw, args, err := collectArgs(tokens[1+skip:], schema)
+	if err != nil {
+		return nil, err
+	}
+	cmd.args = args
+	cmd.raw = raw
+
+	cmd.params, err = parseParams(raw, schema)
+	if err != nil {
+		return nil, err
 	}
+	return cmd, nil
+}
 
+// collectArgs sorts tokens into named and positional arguments.
+// Supported forms are key=value, --key=value, --key value and --flag.
+// A bare "--" ends named arguments; the rest are positional.
+func collectArgs(tokens []token, schema Params) (map[string]string, []string, error) {
 	raw := make(map[string]string)
-	for _, part := range parts[1:] {
-		if idx := strings.Index(part, "="); idx > 0 {
-			key := part[:idx]
-			value := part[idx+1:]
-			raw[key] = value
+	var args []string
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch {
+		case tok.isTerminator():
+			for _, rest := range tokens[i+1:] {
+				args = append(args, rest.value)
+			}
+			return raw, args, nil
+
+		case tok.isFlag():
+			name := tok.value[2:]
+			if tok.eq >= 0 {
+				name = tok.value[2:tok.eq]
+				if name == "" {
+					return nil, nil, &ParseError{Pos: tok.pos, Msg: "missing parameter name"}
+				}
+				raw[name] = tok.value[tok.eq+1:]
+				continue
+			}
+
+			if s, ok := schema[name]; ok && s.Type == TypeBool {
+				raw[name] = "true"
+				continue
+			}
+			if i+1 < len(tokens) {
+				next := tokens[i+1]
+				if !next.isFlag() && !next.isTerminator() && (next.eq < 0 || next.startQuoted) {
+					raw[name] = next.value
+					i++
+					continue
+				}
+			}
+			raw[name] = "true"
+
+		case tok.eq == 0 && !tok.startQuoted:
+			return nil, nil, &ParseError{Pos: tok.pos, Msg: "missing parameter name"}
+
+		case tok.eq > 0 && !tok.startQuoted:
+			raw[tok.value[:tok.eq]] = tok.value[tok.eq+1:]
+
+		default:
+			args = append(args, tok.value)
 		}
 	}
 
+	return raw, args, nil
+}
+
+// parseParams validates raw named arguments against schema.
+func parseParams(raw map[string]string, schema Params) (ParsedParams, error) {
 	if schema == nil {
 		params := make(ParsedParams)
 		for k, v := range raw {
@@ -97,13 +357,18 @@ func parseParams(text string, schema Params) (ParsedParams, error) {
 	}
 
 	params := make(ParsedParams)
-	var errs []string
+	var errs ValidationErrors
 
-	for name, s := range schema {
+	for _, name := range sortedKeys(schema) {
+		s := schema[name]
 		rawValue, provided := raw[name]
 
 		if s.Required && !provided {
-			errs = append(errs, fmt.Sprintf("parameter %q is required", name))
+			errs = append(errs, &ParamError{
+				Kind:  ValidationRequired,
+				Param: name,
+				Msg:   fmt.Sprintf("parameter %q is required", name),
+			})
 			continue
 		}
 
@@ -114,50 +379,224 @@ func parseParams(text string, schema Params) (ParsedParams, error) {
 			continue
 		}
 
-		switch s.Type {
-		case TypeString:
-			params[name] = rawValue
+		value, perr := convertParam(s, rawValue)
+		if perr != nil {
+			perr.Param = name
+			perr.Value = rawValue
+			perr.Msg = fmt.Sprintf("parameter %q %s", name, perr.Msg)
+			errs = append(errs, perr)
+			continue
+		}
+		params[name] = value
+	}
 
-		case TypeInt:
-			n, err := strconv.ParseInt(rawValue, 10, 64)
-			if err != nil {
-				errs = append(errs, fmt.Sprintf("parameter %q must be a number", name))
-			} else {
-				params[name] = n
-			}
+	for _, name := range sortedKeys(raw) {
+		if _, exists := schema[name]; !exists {
+			errs = append(errs, &ParamError{
+				Kind:  ValidationUnknown,
+				Param: name,
+				Value: raw[name],
+				Msg:   fmt.Sprintf("unknown parameter %q", name),
+			})
+		}
+	}
 
-		case TypeBool:
-			switch strings.ToLower(rawValue) {
-			case "true", "1", "yes":
-				params[name] = true
-			case "false", "0", "no":
-				params[name] = false
-			default:
-				errs = append(errs, fmt.Sprintf("parameter %q must be true or false", name))
-			}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-		case TypeEnum:
-			if slices.Contains(s.Enum, rawValue) {
-				params[name] = rawValue
-			} else {
-				errs = append(errs, fmt.Sprintf("parameter %q must be one of: %s",
-					name, strings.Join(s.Enum, ", ")))
-			}
+	return params, nil
+}
 
-		default:
-			params[name] = rawValue
+// convertParam converts a raw value according to the schema type.
+// On failure it returns an error whose Msg completes "parameter X ...".
+func convertParam(s ParamSchema, raw string) (any, *ParamError) {
+	if s.Type != TypeList {
+		return convertValue(s.Type, s.Enum, raw)
+	}
+
+	elem := s.Elem
+	if elem == "" {
+		elem = TypeString
+	}
+
+	var items []string
+	for item := range strings.SplitSeq(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
 
-	for name := range raw {
-		if _, exists := schema[name]; !exists {
-			errs = append(errs, fmt.Sprintf("unknown parameter %q", name))
+	values := make([]any, 0, len(items))
+	for _, item := range items {
+		v, perr := convertValue(elem, s.Enum, item)
+		if perr != nil {
+			perr.Msg = fmt.Sprintf("item %q %s", item, perr.Msg)
+			return nil, perr
 		}
+		values = append(values, v)
 	}
+	return typedList(elem, values), nil
+}
 
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+func convertValue(typ ParamType, enum []string, raw string) (any, *ParamError) {
+	typeErr := func(msg string) *ParamError {
+		return &ParamError{Kind: ValidationType, Limit: typ, Msg: msg}
 	}
 
-	return params, nil
+	switch typ {
+	case TypeString:
+		return raw, nil
+
+	case TypeInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, typeErr("must be a number")
+		}
+		return n, nil
+
+	case TypeBool:
+		switch strings.ToLower(raw) {
+		case "true", "1", "yes":
+			return true, nil
+		case "false", "0", "no":
+			return false, nil
+		}
+		return nil, typeErr("must be true or false")
+
+	case TypeEnum:
+		if slices.Contains(enum, raw) {
+			return raw, nil
+		}
+		return nil, &ParamError{
+			Kind:  ValidationEnum,
+			Limit: enum,
+			Msg:   "must be one of: " + strings.Join(enum, ", "),
+		}
+
+	case TypeFloat:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, typeErr("must be a decimal number")
+		}
+		return f, nil
+
+	case TypeDuration:
+		d, err := parseDuration(raw)
+		if err != nil {
+			return nil, typeErr("must be a duration like 90s, 1h30m or 2d")
+		}
+		return d, nil
+
+	case TypeTime:
+		t, err := parseTime(raw)
+		if err != nil {
+			return nil, typeErr("must be a date (2006-01-02) or time (RFC 3339)")
+		}
+		return t, nil
+
+	case TypeURL:
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, typeErr("must be an absolute URL")
+		}
+		return u, nil
+
+	case TypeUser, TypeChat:
+		ref, ok := parsePeerRef(raw)
+		if typ == TypeChat {
+			if !ok || ref.mention {
+				return nil, typeErr("must be a chat @username or numeric ID")
+			}
+			ref.chat = true
+			return ref, nil
+		}
+		if !ok {
+			return nil, typeErr("must be an @username, numeric ID or mention")
+		}
+		return ref, nil
+	}
+
+	return raw, nil
+}
+
+// typedList converts converted list items into a slice of the element type.
+func typedList(elem ParamType, values []any) any {
+	switch elem {
+	case TypeInt:
+		return listOf[int64](values)
+	case TypeFloat:
+		return listOf[float64](values)
+	case TypeBool:
+		return listOf[bool](values)
+	case TypeDuration:
+		return listOf[time.Duration](values)
+	case TypeTime:
+		return listOf[time.Time](values)
+	case TypeURL:
+		return listOf[*url.URL](values)
+	case TypeUser, TypeChat:
+		return listOf[peerRef](values)
+	default:
+		return listOf[string](values)
+	}
+}
+
+func listOf[T any](values []any) []T {
+	out := make([]T, len(values))
+	for i, v := range values {
+		out[i] = v.(T)
+	}
+	return out
+}
+
+// parseDuration extends time.ParseDuration with a leading day component
+// ("2d", "1d12h"). A sign applies to the whole duration ("-1d12h").
+func parseDuration(s string) (time.Duration, error) {
+	sign, rest := time.Duration(1), s
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		sign, rest = -1, rest[1:]
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+
+	var days time.Duration
+	if idx := strings.IndexByte(rest, 'd'); idx > 0 {
+		n, err := strconv.ParseUint(rest[:idx], 10, 63)
+		if err != nil {
+			return 0, err
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		rest = rest[idx+1:]
+		if rest == "" {
+			return sign * days, nil
+		}
+	}
+	if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, err
+	}
+	return sign * (days + d), nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
 }
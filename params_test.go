@@ -0,0 +1,170 @@
+package telekit
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseParamsTypes(t *testing.T) {
+	schema := Params{
+		"ratio":   {Type: TypeFloat},
+		"wait":    {Type: TypeDuration},
+		"long":    {Type: TypeDuration},
+		"since":   {Type: TypeTime},
+		"at":      {Type: TypeTime},
+		"link":    {Type: TypeURL},
+		"tags":    {Type: TypeList},
+		"ids":     {Type: TypeList, Elem: TypeInt},
+		"levels":  {Type: TypeList, Elem: TypeEnum, Enum: []string{"low", "high"}},
+		"flags":   {Type: TypeList, Elem: TypeBool},
+		"dates":   {Type: TypeList, Elem: TypeTime},
+		"links":   {Type: TypeList, Elem: TypeURL},
+		"owner":   {Type: TypeUser},
+		"target":  {Type: TypeChat},
+		"members": {Type: TypeList, Elem: TypeUser},
+	}
+	raw := map[string]string{
+		"ratio":   "0.75",
+		"wait":    "1h30m",
+		"long":    "2d12h",
+		"since":   "2024-03-01",
+		"at":      "2024-03-01T10:00:00+02:00",
+		"link":    "https://example.com/path",
+		"tags":    "a, b,,c",
+		"ids":     "1,2,3",
+		"levels":  "low,high",
+		"flags":   "yes,false",
+		"dates":   "2024-03-01,2024-03-02",
+		"links":   "https://a.example,https://b.example",
+		"owner":   "@someone",
+		"target":  "-1001234567890",
+		"members": "@first_user, 42",
+	}
+
+	params, err := parseParams(raw, schema)
+	if err != nil {
+		t.Fatalf("parseParams() error = %v", err)
+	}
+
+	if got := params.Float("ratio"); got != 0.75 {
+		t.Errorf("ratio = %v, want 0.75", got)
+	}
+	if got := params.Duration("wait"); got != 90*time.Minute {
+		t.Errorf("wait = %v, want 1h30m", got)
+	}
+	if got := params.Duration("long"); got != 60*time.Hour {
+		t.Errorf("long = %v, want 60h", got)
+	}
+	if got := params.Time("since"); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("since = %v", got)
+	}
+	if got := params.Time("at"); !got.Equal(time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("at = %v", got)
+	}
+	if got := params.URL("link"); got == nil || got.Host != "example.com" {
+		t.Errorf("link = %v", got)
+	}
+	if got := params.Strings("tags"); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("tags = %q", got)
+	}
+	if got := params.Ints("ids"); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("ids = %v", got)
+	}
+	if got := params.Strings("levels"); !slices.Equal(got, []string{"low", "high"}) {
+		t.Errorf("levels = %q", got)
+	}
+	if got := params.Bools("flags"); !slices.Equal(got, []bool{true, false}) {
+		t.Errorf("flags = %v", got)
+	}
+	if got := params.Times("dates"); len(got) != 2 || !got[1].Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("dates = %v", got)
+	}
+	if got := params.URLs("links"); len(got) != 2 || got[1].Host != "b.example" {
+		t.Errorf("links = %v", got)
+	}
+	if ref, ok := params["owner"].(peerRef); !ok || ref.username != "someone" {
+		t.Errorf("owner = %#v", params["owner"])
+	}
+	if ref, ok := params["target"].(peerRef); !ok || !ref.chat || ref.id != -1001234567890 {
+		t.Errorf("target = %#v", params["target"])
+	}
+	if refs, ok := params["members"].([]peerRef); !ok || len(refs) != 2 || refs[1].id != 42 {
+		t.Errorf("members = %#v", params["members"])
+	}
+}
+
+func TestParseParamsTypeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		param ParamSchema
+		value string
+	}{
+		{"float", ParamSchema{Type: TypeFloat}, "abc"},
+		{"duration", ParamSchema{Type: TypeDuration}, "soon"},
+		{"time", ParamSchema{Type: TypeTime}, "yesterday"},
+		{"relative url", ParamSchema{Type: TypeURL}, "/path"},
+		{"list item", ParamSchema{Type: TypeList, Elem: TypeInt}, "1,x"},
+		{"invalid username", ParamSchema{Type: TypeUser}, "@a!"},
+		{"chat mention", ParamSchema{Type: TypeChat}, "John"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseParams(map[string]string{"p": tt.value}, Params{"p": tt.param})
+			if err == nil {
+				t.Errorf("parseParams(%q) expected error", tt.value)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90m", 90 * time.Minute, false},
+		{"2d", 48 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"-1d12h", -36 * time.Hour, false},
+		{"+2d", 48 * time.Hour, false},
+		{"-30m", -30 * time.Minute, false},
+		{"--1h", 0, true},
+		{"1d-2h", 0, true},
+		{"-d", 0, true},
+		{"-", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePeerRef(t *testing.T) {
+	tests := []struct {
+		raw      string
+		username string
+		id       int64
+		mention  bool
+	}{
+		{"@durov", "durov", 0, false},
+		{"https://t.me/durov", "durov", 0, false},
+		{"12345", "", 12345, false},
+		{"John Smith", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		ref, ok := parsePeerRef(tt.raw)
+		if !ok {
+			t.Errorf("parsePeerRef(%q) failed", tt.raw)
+			continue
+		}
+		if ref.username != tt.username || ref.id != tt.id || ref.mention != tt.mention {
+			t.Errorf("parsePeerRef(%q) = %#v", tt.raw, ref)
+		}
+	}
+}
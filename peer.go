@@ -0,0 +1,304 @@
+package telekit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+// PeerKind identifies the type of a resolved Peer.
+type PeerKind int
+
+const (
+	PeerKindUser PeerKind = iota + 1
+	PeerKindChat
+	PeerKindChannel
+)
+
+// Peer is a resolved Telegram user, basic group or channel/supergroup.
+type Peer struct {
+	Kind       PeerKind
+	ID         int64
+	AccessHash int64
+
+	// Username is the public username without "@", empty if none.
+	Username string
+
+	// Title is the chat title or the user's full name.
+	Title string
+}
+
+// InputPeer returns the peer for use in API requests.
+func (p Peer) InputPeer() tg.InputPeerClass {
+	switch p.Kind {
+	case PeerKindUser:
+		return &tg.InputPeerUser{UserID: p.ID, AccessHash: p.AccessHash}
+	case PeerKindChat:
+		return &tg.InputPeerChat{ChatID: p.ID}
+	case PeerKindChannel:
+		return &tg.InputPeerChannel{ChannelID: p.ID, AccessHash: p.AccessHash}
+	}
+	return &tg.InputPeerEmpty{}
+}
+
+// IsZero returns true if the peer was not set.
+func (p Peer) IsZero() bool {
+	return p.Kind == 0
+}
+
+func peerFromUser(u *tg.User) Peer {
+	return Peer{
+		Kind:       PeerKindUser,
+		ID:         u.ID,
+		AccessHash: u.AccessHash,
+		Username:   u.Username,
+		Title:      strings.TrimSpace(u.FirstName + " " + u.LastName),
+	}
+}
+
+func peerFromChat(chat tg.ChatClass) (Peer, bool) {
+	switch c := chat.(type) {
+	case *tg.Channel:
+		return Peer{
+			Kind:       PeerKindChannel,
+			ID:         c.ID,
+			AccessHash: c.AccessHash,
+			Username:   c.Username,
+			Title:      c.Title,
+		}, true
+	case *tg.Chat:
+		return Peer{Kind: PeerKindChat, ID: c.ID, Title: c.Title}, true
+	}
+	return Peer{}, false
+}
+
+// peerRef is an unresolved TypeUser/TypeChat parameter value.
+type peerRef struct {
+	raw      string
+	username string
+	id       int64
+	mention  bool // plain text, matched against mention entities
+	chat     bool
+}
+
+// botAPIChannelOffset is added to channel IDs in Bot API style -100... IDs.
+const botAPIChannelOffset = 1000000000000
+
+// parsePeerRef parses @username, t.me/username, numeric IDs and plain text.
+func parsePeerRef(raw string) (peerRef, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return peerRef{}, false
+	}
+	ref := peerRef{raw: raw}
+
+	name := raw
+	for _, prefix := range []string{"https://t.me/", "http://t.me/", "t.me/"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	if name != raw || strings.HasPrefix(name, "@") {
+		name = strings.TrimPrefix(name, "@")
+		if !isUsername(name) {
+			return peerRef{}, false
+		}
+		ref.username = name
+		return ref, true
+	}
+
+	if id, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		ref.id = id
+		return ref, true
+	}
+
+	ref.mention = true
+	return ref, true
+}
+
+func isUsername(s string) bool {
+	if len(s) < 4 || len(s) > 32 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePeerParams replaces unresolved TypeUser/TypeChat values in params
+// with Peers, using the update's entities where possible and the API otherwise.
+func (b *Bot) resolvePeerParams(ctx *Context, params ParsedParams) error {
+	for name, value := range params {
+		switch v := value.(type) {
+		case peerRef:
+			peer, err := b.resolvePeerRef(ctx, v)
+			if err != nil {
+				return notFoundError(name, v.raw, err)
+			}
+			params[name] = peer
+
+		case []peerRef:
+			peers := make([]Peer, 0, len(v))
+			for _, ref := range v {
+				peer, err := b.resolvePeerRef(ctx, ref)
+				if err != nil {
+					return notFoundError(name, ref.raw, err)
+				}
+				peers = append(peers, peer)
+			}
+			params[name] = peers
+		}
+	}
+	return nil
+}
+
+func notFoundError(name, raw string, err error) ValidationErrors {
+	return ValidationErrors{{
+		Kind:  ValidationNotFound,
+		Param: name,
+		Value: raw,
+		Err:   err,
+		Msg:   fmt.Sprintf("parameter %q: %v", name, err),
+	}}
+}
+
+func (b *Bot) resolvePeerRef(ctx *Context, ref peerRef) (Peer, error) {
+	if b.api == nil {
+		return Peer{}, ErrBotNotRunning
+	}
+
+	switch {
+	case ref.username != "":
+		return b.resolveUsernamePeer(ctx, ref.username, ref.chat)
+
+	case ref.mention:
+		if ref.chat {
+			return Peer{}, fmt.Errorf("%q is not a chat username or ID", ref.raw)
+		}
+		userID, ok := mentionedUserID(ctx.Text(), ctx.Entities(), ref.raw)
+		if !ok {
+			return Peer{}, fmt.Errorf("%q is not a username, ID or mention", ref.raw)
+		}
+		return b.resolveUserID(ctx, userID)
+
+	case ref.chat:
+		return b.resolveChatID(ctx, ref.id)
+
+	default:
+		return b.resolveUserID(ctx, ref.id)
+	}
+}
+
+func (b *Bot) resolveUsernamePeer(ctx *Context, username string, chat bool) (Peer, error) {
+	resolved, err := b.api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
+		Username: username,
+	})
+	if err != nil {
+		return Peer{}, fmt.Errorf("failed to resolve @%s: %w", username, err)
+	}
+
+	if chat {
+		for _, c := range resolved.Chats {
+			if peer, ok := peerFromChat(c); ok {
+				return peer, nil
+			}
+		}
+		return Peer{}, fmt.Errorf("@%s is not a chat", username)
+	}
+
+	for _, u := range resolved.Users {
+		if user, ok := u.(*tg.User); ok {
+			return peerFromUser(user), nil
+		}
+	}
+	return Peer{}, fmt.Errorf("@%s is not a user", username)
+}
+
+func (b *Bot) resolveUserID(ctx *Context, userID int64) (Peer, error) {
+	if u, ok := ctx.entities.Users[userID]; ok {
+		return peerFromUser(u), nil
+	}
+
+	users, err := b.api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: userID}})
+	if err != nil {
+		return Peer{}, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok && user.ID == userID {
+			return peerFromUser(user), nil
+		}
+	}
+	return Peer{}, fmt.Errorf("user %d not found", userID)
+}
+
+func (b *Bot) resolveChatID(ctx *Context, id int64) (Peer, error) {
+	channel, basic := true, true
+	switch {
+	case id <= -botAPIChannelOffset:
+		id = -id - botAPIChannelOffset
+		basic = false
+	case id < 0:
+		id = -id
+		channel = false
+	}
+
+	if channel {
+		if c, ok := ctx.entities.Channels[id]; ok {
+			peer, _ := peerFromChat(c)
+			return peer, nil
+		}
+		chats, err := b.api.ChannelsGetChannels(ctx, []tg.InputChannelClass{&tg.InputChannel{ChannelID: id}})
+		if err == nil {
+			for _, c := range chats.GetChats() {
+				if peer, ok := peerFromChat(c); ok && peer.ID == id {
+					return peer, nil
+				}
+			}
+		}
+	}
+
+	if basic {
+		if c, ok := ctx.entities.Chats[id]; ok {
+			peer, _ := peerFromChat(c)
+			return peer, nil
+		}
+		chats, err := b.api.MessagesGetChats(ctx, []int64{id})
+		if err == nil {
+			for _, c := range chats.GetChats() {
+				if peer, ok := peerFromChat(c); ok && peer.ID == id {
+					return peer, nil
+				}
+			}
+		}
+	}
+
+	return Peer{}, fmt.Errorf("chat %d not found", id)
+}
+
+// mentionedUserID finds a text mention entity whose text equals name.
+func mentionedUserID(text string, entities []tg.MessageEntityClass, name string) (int64, bool) {
+	for _, entity := range entities {
+		e, ok := entity.(*tg.MessageEntityMentionName)
+		if !ok {
+			continue
+		}
+		if utf16Substring(text, e.Offset, e.Length) == name {
+			return e.UserID, true
+		}
+	}
+	return 0, false
+}
+
+// utf16Substring returns the part of text covered by a UTF-16 based
+// entity offset and length, as used by Telegram.
+func utf16Substring(text string, offset, length int) string {
+	units := utf16.Encode([]rune(text))
+	if offset < 0 || length < 0 || offset+length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[offset : offset+length]))
+}
@@ -0,0 +1,259 @@
+package telekit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// typingInterval is how often the typing action is repeated. Telegram
+// shows it for about five seconds.
+const typingInterval = 4 * time.Second
+
+// Progress is a status message of a long-running handler that is edited
+// in place as the work advances. Updates are debounced to
+// Config.ProgressInterval, and the typing action is shown until Done or
+// Fail is called. Create it with Context.Progress.
+type Progress struct {
+	ctx        *Context
+	interval   time.Duration
+	edit       func(ctx context.Context, text string) error
+	stopTyping func()
+
+	editMu sync.Mutex // serializes edits
+
+	mu       sync.Mutex
+	text     string // text of the status message
+	pending  string
+	lastEdit time.Time
+	timer    *time.Timer
+	done     bool
+}
+
+// Progress sends text as a status message to the current chat and returns
+// a handle to update it. opts apply to the initial message. An inline
+// keyboard among them, e.g. with a cancel button, is kept on every edit
+// including Done and Fail.
+func (c *Context) Progress(text string, opts ...SendOption) (*Progress, error) {
+	peer := c.inputPeer()
+	if peer == nil {
+		return nil, ErrNoMessage
+	}
+	o, err := applySendOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := c.bot.sendMessage(c, peer, 0, text, nil, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send progress message: %w", err)
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("failed to send progress message: no message ID")
+	}
+
+	// edits without markup would remove the keyboard of the message
+	markup, _ := o.markup.(*tg.ReplyInlineMarkup)
+	return &Progress{
+		ctx:        c,
+		interval:   c.bot.config.ProgressInterval,
+		edit:       progressEdit(c.bot.api, peer, id, markup),
+		stopTyping: c.Typing(),
+		text:       text,
+		pending:    text,
+		lastEdit:   time.Now(),
+	}, nil
+}
+
+// progressEdit returns a func editing the text of message id, keeping
+// markup if it is not nil.
+func progressEdit(api *tg.Client, peer tg.InputPeerClass, id int, markup *tg.ReplyInlineMarkup) func(ctx context.Context, text string) error {
+	return func(ctx context.Context, text string) error {
+		req := &tg.MessagesEditMessageRequest{
+			Peer:    peer,
+			ID:      id,
+			Message: text,
+		}
+		if markup != nil {
+			req.SetReplyMarkup(markup)
+		}
+		_, err := api.MessagesEditMessage(ctx, req)
+		if tg.IsMessageNotModified(err) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Update sets the text of the status message. Edits are sent in the
+// background at most once per Config.ProgressInterval; intermediate texts
+// are skipped.
+func (p *Progress) Update(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return
+	}
+	p.pending = text
+	if p.timer != nil {
+		return
+	}
+	wait := max(p.interval-time.Since(p.lastEdit), 0)
+	p.timer = time.AfterFunc(wait, p.flush)
+}
+
+// UpdatePercent sets the status message to text followed by a progress
+// bar, e.g. "Exporting\n▓▓▓▓░░░░░░ 40%". percent is clamped to 0..100.
+func (p *Progress) UpdatePercent(percent int, text string) {
+	p.Update(formatProgress(percent, text))
+}
+
+// Done replaces the status message with text and stops the typing action.
+// Later updates are ignored.
+func (p *Progress) Done(text string) error {
+	return p.finish(text)
+}
+
+// Fail replaces the status message with Messages.ProgressFailed for err
+// and stops the typing action. It returns the error of the edit, not err,
+// so handlers that already reported err this way can return the result.
+func (p *Progress) Fail(err error) error {
+	m := p.ctx.bot.messagesFor(p.ctx.LangCode())
+	return p.finish(renderMessage(m.ProgressFailed, map[string]string{"Error": p.ctx.ErrorText(err)}))
+}
+
+func (p *Progress) finish(text string) error {
+	p.mu.Lock()
+	if p.done {
+		p.mu.Unlock()
+		return nil
+	}
+	p.done = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	if p.stopTyping != nil {
+		p.stopTyping()
+	}
+
+	p.editMu.Lock()
+	defer p.editMu.Unlock()
+
+	// the final edit must succeed even if the handler was cancelled
+	if err := p.edit(context.WithoutCancel(p.ctx), text); err != nil {
+		return fmt.Errorf("failed to edit progress message: %w", err)
+	}
+	return nil
+}
+
+// flush sends the pending text. It runs on the debounce timer.
+func (p *Progress) flush() {
+	p.editMu.Lock()
+	defer p.editMu.Unlock()
+
+	p.mu.Lock()
+	p.timer = nil
+	if p.done || p.pending == p.text {
+		p.mu.Unlock()
+		return
+	}
+	text := p.pending
+	p.lastEdit = time.Now()
+	p.mu.Unlock()
+
+	if err := p.edit(context.WithoutCancel(p.ctx), text); err != nil {
+		p.ctx.bot.config.Logger.Warn("failed to edit progress message", "error", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.text = text
+	p.mu.Unlock()
+}
+
+// formatProgress renders text followed by a ten-step progress bar.
+func formatProgress(percent int, text string) string {
+	percent = min(max(percent, 0), 100)
+	filled := percent / 10
+	bar := strings.Repeat("▓", filled) + strings.Repeat("░", 10-filled) + " " + strconv.Itoa(percent) + "%"
+	if text == "" {
+		return bar
+	}
+	return text + "\n" + bar
+}
+
+// Typing shows the "typing…" action in the current chat until the returned
+// func is called or the handler's context is done.
+func (c *Context) Typing() (stop func()) {
+	peer := c.inputPeer()
+	if peer == nil || c.bot.api == nil {
+		return func() {}
+	}
+
+	setTyping := func(ctx context.Context, action tg.SendMessageActionClass) {
+		_, err := c.bot.api.MessagesSetTyping(ctx, &tg.MessagesSetTypingRequest{
+			Peer:   peer,
+			Action: action,
+		})
+		if err != nil && ctx.Err() == nil {
+			c.bot.config.Logger.Debug("failed to set chat action", "error", err)
+		}
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(typingInterval)
+		defer ticker.Stop()
+		for {
+			setTyping(c, &tg.SendMessageTypingAction{})
+			select {
+			case <-ticker.C:
+			case <-done:
+				setTyping(context.WithoutCancel(c), &tg.SendMessageCancelAction{})
+				return
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// sentMessageID returns the ID of the message created by a send request.
+func sentMessageID(upd tg.UpdatesClass) int {
+	switch u := upd.(type) {
+	case *tg.UpdateShortSentMessage:
+		return u.ID
+	case *tg.Updates:
+		return messageIDFromUpdates(u.Updates)
+	case *tg.UpdatesCombined:
+		return messageIDFromUpdates(u.Updates)
+	}
+	return 0
+}
+
+func messageIDFromUpdates(updates []tg.UpdateClass) int {
+	for _, update := range updates {
+		switch u := update.(type) {
+		case *tg.UpdateMessageID:
+			return u.ID
+		case *tg.UpdateNewMessage:
+			return u.Message.GetID()
+		case *tg.UpdateNewChannelMessage:
+			return u.Message.GetID()
+		}
+	}
+	return 0
+}
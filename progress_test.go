@@ -0,0 +1,165 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+type recordedEdits struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (r *recordedEdits) edit(_ context.Context, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.texts = append(r.texts, text)
+	return nil
+}
+
+func (r *recordedEdits) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.texts)
+}
+
+func newTestProgress(interval time.Duration) (*Progress, *recordedEdits) {
+	b := &Bot{config: Config{Logger: slog.Default()}}
+	ctx := groupMessage(1, 10, "/export")
+	ctx.bot = b
+
+	edits := &recordedEdits{}
+	return &Progress{
+		ctx:      ctx,
+		interval: interval,
+		edit:     edits.edit,
+		text:     "Starting",
+		pending:  "Starting",
+		lastEdit: time.Now(),
+	}, edits
+}
+
+func TestProgressDebounce(t *testing.T) {
+	p, edits := newTestProgress(30 * time.Millisecond)
+
+	p.Update("step 1")
+	p.Update("step 2")
+	p.Update("step 3")
+	if got := edits.list(); len(got) != 0 {
+		t.Fatalf("edits before the interval = %q", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := edits.list(); !slices.Equal(got, []string{"step 3"}) {
+		t.Fatalf("edits after the interval = %q, want only the latest text", got)
+	}
+
+	p.Update("step 3")
+	time.Sleep(60 * time.Millisecond)
+	if got := edits.list(); len(got) != 1 {
+		t.Errorf("unchanged text was edited again: %q", got)
+	}
+
+	p.Update("step 4")
+	if err := p.Done("Finished"); err != nil {
+		t.Fatalf("Done() error = %v", err)
+	}
+	p.Update("late")
+	time.Sleep(60 * time.Millisecond)
+	if got := edits.list(); !slices.Equal(got, []string{"step 3", "Finished"}) {
+		t.Errorf("edits = %q, want pending update dropped after Done", got)
+	}
+}
+
+func TestProgressFail(t *testing.T) {
+	p, edits := newTestProgress(time.Minute)
+
+	if err := p.Fail(errors.New("disk full")); err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	if err := p.Done("ignored"); err != nil {
+		t.Fatalf("Done() after Fail() error = %v", err)
+	}
+	if got := edits.list(); !slices.Equal(got, []string{"Failed: disk full"}) {
+		t.Errorf("edits = %q", got)
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		percent int
+		text    string
+		want    string
+	}{
+		{40, "Exporting", "Exporting\n▓▓▓▓░░░░░░ 40%"},
+		{-5, "", "░░░░░░░░░░ 0%"},
+		{250, "Done", "Done\n▓▓▓▓▓▓▓▓▓▓ 100%"},
+	}
+	for _, tt := range tests {
+		if got := formatProgress(tt.percent, tt.text); got != tt.want {
+			t.Errorf("formatProgress(%d, %q) = %q, want %q", tt.percent, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSentMessageID(t *testing.T) {
+	tests := []struct {
+		name string
+		upd  tg.UpdatesClass
+		want int
+	}{
+		{"short", &tg.UpdateShortSentMessage{ID: 5}, 5},
+		{"message id", &tg.Updates{Updates: []tg.UpdateClass{&tg.UpdateMessageID{ID: 7}}}, 7},
+		{"channel", &tg.Updates{Updates: []tg.UpdateClass{
+			&tg.UpdateNewChannelMessage{Message: &tg.Message{ID: 9}},
+		}}, 9},
+		{"none", &tg.UpdatesTooLong{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sentMessageID(tt.upd); got != tt.want {
+				t.Errorf("sentMessageID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressEditKeepsKeyboard(t *testing.T) {
+	inline, err := NewInlineKeyboard().Row(CallbackButton("Cancel", "cancel")).Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	peer := &tg.InputPeerUser{UserID: 1}
+
+	for _, tt := range []struct {
+		name   string
+		markup *tg.ReplyInlineMarkup
+	}{
+		{"keyboard", inline},
+		{"no keyboard", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &recordingInvoker{}
+			edit := progressEdit(tg.NewClient(invoker), peer, 5, tt.markup)
+			for _, text := range []string{"step 1", "done"} {
+				if err := edit(context.Background(), text); err != nil {
+					t.Fatalf("edit() error = %v", err)
+				}
+			}
+			for _, r := range invoker.requests {
+				req := r.(*tg.MessagesEditMessageRequest)
+				got, _ := req.GetReplyMarkup()
+				if tt.markup == nil && got != nil || tt.markup != nil && got != tt.markup {
+					t.Errorf("edit %q markup = %#v, want %#v", req.Message, got, tt.markup)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package telekit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitScope selects who shares a rate limit bucket.
+type RateLimitScope int
+
+const (
+	// RateLimitPerUser gives each user their own bucket.
+	RateLimitPerUser RateLimitScope = iota
+
+	// RateLimitPerChat shares a bucket between all users of a chat.
+	RateLimitPerChat
+
+	// RateLimitGlobal shares one bucket between everyone.
+	RateLimitGlobal
+)
+
+// RateLimit is a token bucket: Rate invocations per Per, with bursts of up
+// to Burst invocations.
+type RateLimit struct {
+	Rate int
+	Per  time.Duration
+
+	// Burst is the bucket size. Defaults to Rate if zero.
+	Burst int
+
+	// Scope selects who shares a bucket. Defaults to RateLimitPerUser.
+	// Ignored by Config.UserThrottle, which is always per user.
+	Scope RateLimitScope
+}
+
+// limiter implements token buckets keyed by user, chat or a global key.
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	scope   RateLimitScope
+	buckets map[int64]*tokenBucket
+	calls   int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+
+	// notified is when the sender of a rejected invocation was last told
+	// to wait; later rejections until then are dropped silently.
+	notified time.Time
+}
+
+// pruneEvery is how many calls pass between removals of full buckets and
+// expired cache entries.
+const pruneEvery = 1024
+
+func newLimiter(rl RateLimit) *limiter {
+	if rl.Rate <= 0 || rl.Per <= 0 {
+		return nil
+	}
+	burst := rl.Burst
+	if burst <= 0 {
+		burst = rl.Rate
+	}
+	return &limiter{
+		rate:    float64(rl.Rate) / rl.Per.Seconds(),
+		burst:   float64(burst),
+		scope:   rl.Scope,
+		buckets: make(map[int64]*tokenBucket),
+	}
+}
+
+func rateLimiter(rl *RateLimit) *limiter {
+	if rl == nil {
+		return nil
+	}
+	return newLimiter(*rl)
+}
+
+// newCooldown returns a limiter allowing one invocation per user per d.
+func newCooldown(d time.Duration) *limiter {
+	return newLimiter(RateLimit{Rate: 1, Per: d})
+}
+
+// key returns the bucket key of ctx for the limiter's scope.
+func (l *limiter) key(ctx *Context) int64 {
+	switch l.scope {
+	case RateLimitPerChat:
+		return ctx.ChatID()
+	case RateLimitGlobal:
+		return 0
+	}
+	return ctx.SenderID()
+}
+
+// allow takes a token from the bucket of key. If the bucket is empty it
+// returns false and the time until the next token is available.
+func (l *limiter) allow(key int64, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, now)
+	if wait := l.wait(b); wait > 0 {
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// bucket returns the bucket of key refilled up to now. The caller must
+// hold l.mu.
+func (l *limiter) bucket(key int64, now time.Time) *tokenBucket {
+	l.calls++
+	if l.calls%pruneEvery == 0 {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	return b
+}
+
+// wait returns how long until b has a token, or zero if it has one now.
+func (l *limiter) wait(b *tokenBucket) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune removes buckets that have refilled completely. The caller must hold l.mu.
+func (l *limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// checkCommandLimits applies the cooldown and rate limit of h to ctx and
+// returns how long the sender has to wait if the command is rejected. A
+// token is only taken if both limits allow the command, so a rejected
+// invocation costs nothing. notify is true for the first rejection of a
+// sender in each waiting period.
+func checkCommandLimits(ctx *Context, h commandHandler, now time.Time) (ok bool, wait time.Duration, notify bool) {
+	return applyCommandLimits(ctx, h, now, true)
+}
+
+// peekCommandLimits is checkCommandLimits without taking a token, to
+// reject a command early that is only charged once its handler runs.
+func peekCommandLimits(ctx *Context, h commandHandler, now time.Time) (ok bool, wait time.Duration, notify bool) {
+	return applyCommandLimits(ctx, h, now, false)
+}
+
+func applyCommandLimits(ctx *Context, h commandHandler, now time.Time, charge bool) (ok bool, wait time.Duration, notify bool) {
+	type check struct {
+		l *limiter
+		b *tokenBucket
+	}
+	var checks []check
+	for _, l := range []*limiter{h.rateLimit, h.cooldown} {
+		if l == nil {
+			continue
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		checks = append(checks, check{l: l, b: l.bucket(l.key(ctx), now)})
+	}
+
+	var blocked *tokenBucket
+	for _, c := range checks {
+		if w := c.l.wait(c.b); w > wait {
+			wait, blocked = w, c.b
+		}
+	}
+	if blocked != nil {
+		notify = !now.Before(blocked.notified)
+		if notify {
+			blocked.notified = now.Add(wait)
+		}
+		return false, wait, notify
+	}
+
+	if charge {
+		for _, c := range checks {
+			c.b.tokens--
+		}
+	}
+	return true, 0, false
+}
+
+// chargeCommandLimits takes the tokens of an invocation of h that is about
+// to run. It tells the sender and returns false if the limits were used up
+// since the command was checked.
+func (b *Bot) chargeCommandLimits(ctx *Context, h commandHandler) bool {
+	ok, wait, notify := checkCommandLimits(ctx, h, time.Now())
+	if !ok {
+		b.replyRateLimited(ctx, h, wait, notify)
+	}
+	return ok
+}
+
+// replyRateLimited tells the sender when they can use the command again.
+// Only the first rejection in a waiting period is answered, so a user
+// hammering a command does not make the bot flood the chat.
+func (b *Bot) replyRateLimited(ctx *Context, h commandHandler, wait time.Duration, notify bool) {
+	b.config.Logger.Debug("command rate limited",
+		"command", h.path,
+		"sender_id", ctx.SenderID(),
+		"wait", wait)
+	if !notify {
+		return
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	m := b.messagesFor(ctx.LangCode())
+	b.sendErrorReply(ctx, renderMessage(m.RateLimited, map[string]any{
+		"Command": h.path,
+		"Wait":    time.Duration(seconds) * time.Second,
+		"Seconds": seconds,
+	}))
+}
@@ -0,0 +1,122 @@
+package telekit
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 2, Per: time.Second})
+	now := time.Unix(1700000000, 0)
+
+	steps := []struct {
+		key      int64
+		after    time.Duration
+		want     bool
+		wantWait time.Duration
+	}{
+		{1, 0, true, 0},
+		{1, 0, true, 0},
+		{1, 0, false, 500 * time.Millisecond},
+		{2, 0, true, 0},
+		{1, 250 * time.Millisecond, false, 250 * time.Millisecond},
+		{1, 250 * time.Millisecond, true, 0},
+		{1, 0, false, 500 * time.Millisecond},
+	}
+	for i, s := range steps {
+		now = now.Add(s.after)
+		ok, wait := l.allow(s.key, now)
+		if ok != s.want || wait != s.wantWait {
+			t.Errorf("step %d: allow() = %v, %v, want %v, %v", i, ok, wait, s.want, s.wantWait)
+		}
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	if newLimiter(RateLimit{}) != nil || newCooldown(0) != nil || rateLimiter(nil) != nil {
+		t.Error("limiter created without a rate")
+	}
+}
+
+func TestCheckCommandLimits(t *testing.T) {
+	h := commandHandler{
+		cooldown:  newCooldown(10 * time.Second),
+		rateLimit: newLimiter(RateLimit{Rate: 5, Per: time.Minute, Scope: RateLimitPerChat}),
+	}
+	now := time.Unix(1700000000, 0)
+
+	if ok, _, _ := checkCommandLimits(groupMessage(1, 10, "/report"), h, now); !ok {
+		t.Fatal("first invocation rejected")
+	}
+	ok, wait, notify := checkCommandLimits(groupMessage(1, 10, "/report"), h, now.Add(time.Second))
+	if ok || wait != 9*time.Second || !notify {
+		t.Errorf("invocation during cooldown = %v, %v, notify %v", ok, wait, notify)
+	}
+	if ok, _, notify := checkCommandLimits(groupMessage(1, 10, "/report"), h, now.Add(2*time.Second)); ok || notify {
+		t.Errorf("second invocation during cooldown = %v, notify %v, want a silent rejection", ok, notify)
+	}
+	if ok, _, _ := checkCommandLimits(groupMessage(1, 11, "/report"), h, now.Add(time.Second)); !ok {
+		t.Error("cooldown of one user blocked another user")
+	}
+}
+
+func TestCheckCommandLimitsChargesOnlyAllowed(t *testing.T) {
+	h := commandHandler{
+		cooldown:  newCooldown(10 * time.Second),
+		rateLimit: newLimiter(RateLimit{Rate: 2, Per: time.Minute, Scope: RateLimitPerChat}),
+	}
+	now := time.Unix(1700000000, 0)
+
+	if ok, _, _ := checkCommandLimits(groupMessage(1, 10, "/report"), h, now); !ok {
+		t.Fatal("first invocation rejected")
+	}
+	// rejected by the cooldown; must not use up the chat's rate limit
+	for range 3 {
+		if ok, _, _ := checkCommandLimits(groupMessage(1, 10, "/report"), h, now); ok {
+			t.Fatal("invocation during cooldown allowed")
+		}
+	}
+	if ok, wait, _ := checkCommandLimits(groupMessage(1, 11, "/report"), h, now); !ok {
+		t.Errorf("another user rejected after cooldown rejections, wait %v", wait)
+	}
+
+	later := now.Add(10 * time.Second)
+	ok, wait, notify := checkCommandLimits(groupMessage(1, 12, "/report"), h, later)
+	if ok || !notify {
+		t.Fatalf("invocation over the rate limit = %v, notify %v", ok, notify)
+	}
+	if ok, _, notify := checkCommandLimits(groupMessage(1, 13, "/report"), h, later.Add(wait/2)); ok || notify {
+		t.Errorf("rejection in the same waiting period = %v, notify %v, want a silent rejection", ok, notify)
+	}
+	if ok, _, _ := checkCommandLimits(groupMessage(1, 12, "/report"), h, later.Add(wait)); !ok {
+		t.Error("invocation after the waiting period rejected")
+	}
+}
+
+func TestRunCommandChargesLimitsOnRun(t *testing.T) {
+	b := &Bot{invocations: newInvocationRegistry(), config: Config{Logger: slog.Default(), ErrorReply: ErrorReplySilent}}
+	runs := 0
+	h := commandHandler{
+		name:     "report",
+		path:     "report",
+		params:   Params{"n": {Type: TypeInt}},
+		cooldown: newCooldown(time.Minute),
+		fn: func(*Context) error {
+			runs++
+			return nil
+		},
+	}
+
+	// a malformed invocation must not use up the cooldown
+	for i, text := range []string{"/report n=x", "/report n=1", "/report n=2"} {
+		ctx := groupMessage(1, 10, text)
+		ctx.bot = b
+		if err := b.runCommand(ctx, h); err != nil {
+			t.Fatalf("runCommand(%d) error = %v", i, err)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("handler ran %d times, want 1", runs)
+	}
+}
@@ -0,0 +1,356 @@
+package telekit
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gotd/td/tg"
+)
+
+// Telegram limits of reply keyboards.
+const (
+	maxReplyButtonsPerRow   = 12
+	maxReplyKeyboardButtons = 300
+	maxPlaceholderLen       = 64
+	maxRequestedPeers       = 10
+)
+
+// ReplyButton is a button of a ReplyKeyboard. Create it with one of the
+// reply button constructors, such as TextButton.
+type ReplyButton struct {
+	button   tg.KeyboardButtonClass
+	buttonID int // ID of request peer buttons
+	err      error
+}
+
+// TextButton sends its text as a message when pressed.
+func TextButton(text string) ReplyButton {
+	return newReplyButton(text, &tg.KeyboardButton{Text: text})
+}
+
+// ContactButton shares the user's phone number when pressed.
+// Only available in private chats.
+func ContactButton(text string) ReplyButton {
+	return newReplyButton(text, &tg.KeyboardButtonRequestPhone{Text: text})
+}
+
+// LocationButton shares the user's location when pressed.
+// Only available in private chats.
+func LocationButton(text string) ReplyButton {
+	return newReplyButton(text, &tg.KeyboardButtonRequestGeoLocation{Text: text})
+}
+
+// PollButton asks the user to create a poll and send it to the bot.
+// quiz restricts it to quizzes. Only available in private chats.
+func PollButton(text string, quiz bool) ReplyButton {
+	button := &tg.KeyboardButtonRequestPoll{Text: text}
+	if quiz {
+		button.SetQuiz(true)
+	}
+	return newReplyButton(text, button)
+}
+
+// RequestUsersOptions configures a RequestUsersButton. Unset criteria
+// allow any user.
+type RequestUsersOptions struct {
+	// Bot only allows bots.
+	Bot bool
+
+	// Premium only allows Telegram Premium users.
+	Premium bool
+
+	// MaxQuantity is how many users can be picked, 1-10. Defaults to 1.
+	MaxQuantity int
+
+	// RequestName, RequestUsername and RequestPhoto ask for these details
+	// of the picked users.
+	RequestName     bool
+	RequestUsername bool
+	RequestPhoto    bool
+}
+
+// RequestUsersButton lets the user pick users to share with the bot. The
+// choice is delivered to the Bot.OnSharedPeer handler of buttonID, which
+// must be unique within the keyboard. Only available in private chats.
+func RequestUsersButton(text string, buttonID int, opts RequestUsersOptions) ReplyButton {
+	peerType := &tg.RequestPeerTypeUser{}
+	if opts.Bot {
+		peerType.SetBot(true)
+	}
+	if opts.Premium {
+		peerType.SetPremium(true)
+	}
+	return requestPeerButton(text, buttonID, peerType, opts.MaxQuantity,
+		opts.RequestName, opts.RequestUsername, opts.RequestPhoto)
+}
+
+// RequestChatOptions configures a RequestChatButton. Unset criteria allow
+// any chat.
+type RequestChatOptions struct {
+	// Channel requests a channel instead of a group.
+	Channel bool
+
+	// Creator only allows chats owned by the user.
+	Creator bool
+
+	// HasUsername only allows public chats.
+	HasUsername bool
+
+	// Forum only allows forum supergroups. Ignored for channels.
+	Forum bool
+
+	// BotIsMember only allows groups the bot is a member of. Ignored for
+	// channels.
+	BotIsMember bool
+
+	// UserRights and BotRights are the admin rights the user and the bot
+	// must have in the chat. Nil requires none.
+	UserRights *AdminRights
+	BotRights  *AdminRights
+
+	// RequestTitle, RequestUsername and RequestPhoto ask for these details
+	// of the picked chat.
+	RequestTitle    bool
+	RequestUsername bool
+	RequestPhoto    bool
+}
+
+// RequestChatButton lets the user pick a group or channel to share with
+// the bot. The choice is delivered to the Bot.OnSharedPeer handler of
+// buttonID, which must be unique within the keyboard. Only available in
+// private chats.
+func RequestChatButton(text string, buttonID int, opts RequestChatOptions) ReplyButton {
+	var peerType tg.RequestPeerTypeClass
+	if opts.Channel {
+		broadcast := &tg.RequestPeerTypeBroadcast{Creator: opts.Creator}
+		if opts.HasUsername {
+			broadcast.SetHasUsername(true)
+		}
+		if opts.UserRights != nil {
+			broadcast.SetUserAdminRights(opts.UserRights.chatAdminRights())
+		}
+		if opts.BotRights != nil {
+			broadcast.SetBotAdminRights(opts.BotRights.chatAdminRights())
+		}
+		peerType = broadcast
+	} else {
+		chat := &tg.RequestPeerTypeChat{Creator: opts.Creator, BotParticipant: opts.BotIsMember}
+		if opts.HasUsername {
+			chat.SetHasUsername(true)
+		}
+		if opts.Forum {
+			chat.SetForum(true)
+		}
+		if opts.UserRights != nil {
+			chat.SetUserAdminRights(opts.UserRights.chatAdminRights())
+		}
+		if opts.BotRights != nil {
+			chat.SetBotAdminRights(opts.BotRights.chatAdminRights())
+		}
+		peerType = chat
+	}
+	return requestPeerButton(text, buttonID, peerType, 1,
+		opts.RequestTitle, opts.RequestUsername, opts.RequestPhoto)
+}
+
+func requestPeerButton(text string, buttonID int, peerType tg.RequestPeerTypeClass, quantity int, name, username, photo bool) ReplyButton {
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity < 1 || quantity > maxRequestedPeers {
+		return ReplyButton{err: fmt.Errorf("%w: button %q: quantity must be 1-%d, got %d",
+			ErrInvalidKeyboard, text, maxRequestedPeers, quantity)}
+	}
+	b := newReplyButton(text, &tg.InputKeyboardButtonRequestPeer{
+		Text:              text,
+		ButtonID:          buttonID,
+		PeerType:          peerType,
+		MaxQuantity:       quantity,
+		NameRequested:     name,
+		UsernameRequested: username,
+		PhotoRequested:    photo,
+	})
+	b.buttonID = buttonID
+	return b
+}
+
+func newReplyButton(text string, button tg.KeyboardButtonClass) ReplyButton {
+	if text == "" {
+		return ReplyButton{err: fmt.Errorf("%w: button text is required", ErrInvalidKeyboard)}
+	}
+	return ReplyButton{button: button}
+}
+
+// ReplyKeyboard builds a custom keyboard that replaces the user's
+// keyboard. It is a SendOption:
+//
+//	kb := telekit.NewReplyKeyboard().
+//		Row(telekit.TextButton("Yes"), telekit.TextButton("No")).
+//		Row(telekit.ContactButton("Share phone")).
+//		Resize().OneTime()
+//	ctx.Reply("Continue?", kb)
+type ReplyKeyboard struct {
+	rows        [][]ReplyButton
+	resize      bool
+	oneTime     bool
+	persistent  bool
+	selective   bool
+	placeholder string
+}
+
+// NewReplyKeyboard creates an empty reply keyboard.
+func NewReplyKeyboard() *ReplyKeyboard {
+	return &ReplyKeyboard{}
+}
+
+// Row appends a row of buttons.
+func (k *ReplyKeyboard) Row(buttons ...ReplyButton) *ReplyKeyboard {
+	if len(buttons) > 0 {
+		k.rows = append(k.rows, buttons)
+	}
+	return k
+}
+
+// Grid appends buttons in rows of the given number of columns; the last
+// row may be shorter.
+func (k *ReplyKeyboard) Grid(columns int, buttons ...ReplyButton) *ReplyKeyboard {
+	if columns <= 0 {
+		columns = 1
+	}
+	for len(buttons) > 0 {
+		n := min(columns, len(buttons))
+		k.Row(buttons[:n]...)
+		buttons = buttons[n:]
+	}
+	return k
+}
+
+// Resize fits the keyboard's height to its buttons.
+func (k *ReplyKeyboard) Resize() *ReplyKeyboard {
+	k.resize = true
+	return k
+}
+
+// OneTime hides the keyboard after a button is pressed.
+func (k *ReplyKeyboard) OneTime() *ReplyKeyboard {
+	k.oneTime = true
+	return k
+}
+
+// Persistent keeps the keyboard shown when the user hides the system
+// keyboard.
+func (k *ReplyKeyboard) Persistent() *ReplyKeyboard {
+	k.persistent = true
+	return k
+}
+
+// Selective shows the keyboard only to users mentioned in the message and
+// to the sender of the replied message.
+func (k *ReplyKeyboard) Selective() *ReplyKeyboard {
+	k.selective = true
+	return k
+}
+
+// Placeholder sets the input field placeholder (at most 64 characters).
+func (k *ReplyKeyboard) Placeholder(text string) *ReplyKeyboard {
+	k.placeholder = text
+	return k
+}
+
+// Build validates the keyboard and returns its markup.
+func (k *ReplyKeyboard) Build() (*tg.ReplyKeyboardMarkup, error) {
+	if utf8.RuneCountInString(k.placeholder) > maxPlaceholderLen {
+		return nil, fmt.Errorf("%w: placeholder must be at most %d characters", ErrInvalidKeyboard, maxPlaceholderLen)
+	}
+
+	markup := &tg.ReplyKeyboardMarkup{
+		Resize:     k.resize,
+		SingleUse:  k.oneTime,
+		Persistent: k.persistent,
+		Selective:  k.selective,
+		Rows:       make([]tg.KeyboardButtonRow, 0, len(k.rows)),
+	}
+	if k.placeholder != "" {
+		markup.SetPlaceholder(k.placeholder)
+	}
+
+	total := 0
+	buttonIDs := make(map[int]bool)
+	for i, row := range k.rows {
+		if len(row) > maxReplyButtonsPerRow {
+			return nil, fmt.Errorf("%w: row %d has %d buttons, at most %d are allowed",
+				ErrInvalidKeyboard, i+1, len(row), maxReplyButtonsPerRow)
+		}
+		buttons := make([]tg.KeyboardButtonClass, 0, len(row))
+		for j, b := range row {
+			if b.err != nil {
+				return nil, b.err
+			}
+			if b.button == nil {
+				return nil, fmt.Errorf("%w: button %d of row %d is empty", ErrInvalidKeyboard, j+1, i+1)
+			}
+			if _, ok := b.button.(*tg.InputKeyboardButtonRequestPeer); ok {
+				if buttonIDs[b.buttonID] {
+					return nil, fmt.Errorf("%w: duplicate button ID %d", ErrInvalidKeyboard, b.buttonID)
+				}
+				buttonIDs[b.buttonID] = true
+			}
+			buttons = append(buttons, b.button)
+		}
+		total += len(buttons)
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: buttons})
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: keyboard has no buttons", ErrInvalidKeyboard)
+	}
+	if total > maxReplyKeyboardButtons {
+		return nil, fmt.Errorf("%w: keyboard has %d buttons, at most %d are allowed",
+			ErrInvalidKeyboard, total, maxReplyKeyboardButtons)
+	}
+	return markup, nil
+}
+
+func (k *ReplyKeyboard) applySend(opts *sendOptions) error {
+	markup, err := k.Build()
+	if err != nil {
+		return err
+	}
+	opts.markup = markup
+	return nil
+}
+
+// RemoveKeyboard is a SendOption that hides the reply keyboard shown by an
+// earlier message.
+type RemoveKeyboard struct {
+	// Selective hides the keyboard only for users mentioned in the message
+	// and the sender of the replied message.
+	Selective bool
+}
+
+func (r RemoveKeyboard) applySend(opts *sendOptions) error {
+	opts.markup = &tg.ReplyKeyboardHide{Selective: r.Selective}
+	return nil
+}
+
+// ForceReply is a SendOption that opens a reply to the sent message in the
+// user's client, as if they had selected "Reply".
+type ForceReply struct {
+	// Selective forces the reply only for users mentioned in the message
+	// and the sender of the replied message.
+	Selective bool
+
+	// Placeholder is shown in the input field (at most 64 characters).
+	Placeholder string
+}
+
+func (f ForceReply) applySend(opts *sendOptions) error {
+	if utf8.RuneCountInString(f.Placeholder) > maxPlaceholderLen {
+		return fmt.Errorf("%w: placeholder must be at most %d characters", ErrInvalidKeyboard, maxPlaceholderLen)
+	}
+	markup := &tg.ReplyKeyboardForceReply{Selective: f.Selective}
+	if f.Placeholder != "" {
+		markup.SetPlaceholder(f.Placeholder)
+	}
+	opts.markup = markup
+	return nil
+}
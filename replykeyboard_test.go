@@ -0,0 +1,108 @@
+package telekit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestReplyKeyboardBuild(t *testing.T) {
+	kb := NewReplyKeyboard().
+		Row(TextButton("Yes"), TextButton("No")).
+		Grid(2, ContactButton("Phone"), LocationButton("Location"), PollButton("Quiz", true)).
+		Row(
+			RequestUsersButton("Users", 1, RequestUsersOptions{Bot: true, MaxQuantity: 3, RequestName: true}),
+			RequestChatButton("Channel", 2, RequestChatOptions{Channel: true, BotRights: &AdminRights{PostMessages: true}}),
+		).
+		Resize().OneTime().Persistent().Selective().Placeholder("Pick one")
+
+	markup, err := kb.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !markup.Resize || !markup.SingleUse || !markup.Persistent || !markup.Selective {
+		t.Errorf("Build() flags = %+v", markup)
+	}
+	if placeholder, ok := markup.GetPlaceholder(); !ok || placeholder != "Pick one" {
+		t.Errorf("Build() placeholder = %q, %v", placeholder, ok)
+	}
+	if len(markup.Rows) != 4 || len(markup.Rows[1].Buttons) != 2 || len(markup.Rows[2].Buttons) != 1 {
+		t.Fatalf("Build() rows = %+v", markup.Rows)
+	}
+
+	poll := markup.Rows[2].Buttons[0].(*tg.KeyboardButtonRequestPoll)
+	if quiz, ok := poll.GetQuiz(); !ok || !quiz {
+		t.Errorf("poll button quiz = %v, %v", quiz, ok)
+	}
+
+	users := markup.Rows[3].Buttons[0].(*tg.InputKeyboardButtonRequestPeer)
+	if users.ButtonID != 1 || users.MaxQuantity != 3 || !users.NameRequested {
+		t.Errorf("users button = %+v", users)
+	}
+	if bot, ok := users.PeerType.(*tg.RequestPeerTypeUser).GetBot(); !ok || !bot {
+		t.Errorf("users button bot criterion = %v, %v", bot, ok)
+	}
+
+	channel := markup.Rows[3].Buttons[1].(*tg.InputKeyboardButtonRequestPeer)
+	broadcast, ok := channel.PeerType.(*tg.RequestPeerTypeBroadcast)
+	if !ok || channel.MaxQuantity != 1 {
+		t.Fatalf("channel button = %+v", channel)
+	}
+	if rights, ok := broadcast.GetBotAdminRights(); !ok || !rights.PostMessages {
+		t.Errorf("channel button bot rights = %+v, %v", rights, ok)
+	}
+}
+
+func TestReplyKeyboardValidation(t *testing.T) {
+	many := make([]ReplyButton, 13)
+	for i := range many {
+		many[i] = TextButton("x")
+	}
+
+	tests := []struct {
+		name string
+		kb   *ReplyKeyboard
+	}{
+		{"empty", NewReplyKeyboard()},
+		{"empty text", NewReplyKeyboard().Row(TextButton(""))},
+		{"wide row", NewReplyKeyboard().Row(many...)},
+		{"long placeholder", NewReplyKeyboard().Row(TextButton("a")).Placeholder(strings.Repeat("x", 65))},
+		{"quantity", NewReplyKeyboard().Row(RequestUsersButton("a", 1, RequestUsersOptions{MaxQuantity: 11}))},
+		{"duplicate button ID", NewReplyKeyboard().Row(
+			RequestUsersButton("a", 1, RequestUsersOptions{}),
+			RequestChatButton("b", 1, RequestChatOptions{}),
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.kb.Build(); !errors.Is(err, ErrInvalidKeyboard) {
+				t.Errorf("Build() error = %v, want %v", err, ErrInvalidKeyboard)
+			}
+		})
+	}
+}
+
+func TestKeyboardSendOptions(t *testing.T) {
+	o, err := applySendOptions([]SendOption{RemoveKeyboard{Selective: true}})
+	if err != nil {
+		t.Fatalf("applySendOptions() error = %v", err)
+	}
+	if hide, ok := o.markup.(*tg.ReplyKeyboardHide); !ok || !hide.Selective {
+		t.Errorf("RemoveKeyboard markup = %+v", o.markup)
+	}
+
+	o, err = applySendOptions([]SendOption{ForceReply{Placeholder: "Your name"}})
+	if err != nil {
+		t.Fatalf("applySendOptions() error = %v", err)
+	}
+	force, ok := o.markup.(*tg.ReplyKeyboardForceReply)
+	if placeholder, _ := force.GetPlaceholder(); !ok || placeholder != "Your name" {
+		t.Errorf("ForceReply markup = %+v", o.markup)
+	}
+
+	if _, err := applySendOptions([]SendOption{ForceReply{Placeholder: strings.Repeat("x", 65)}}); !errors.Is(err, ErrInvalidKeyboard) {
+		t.Errorf("ForceReply with long placeholder error = %v, want %v", err, ErrInvalidKeyboard)
+	}
+}
@@ -0,0 +1,354 @@
+package telekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// RoleStore persists the roles granted to users, such as "owner" or
+// "moderator". Implementations must be safe for concurrent use.
+type RoleStore interface {
+	// Roles returns the roles of a user.
+	Roles(ctx context.Context, userID int64) ([]string, error)
+
+	// Grant adds a role to a user. Granting a role twice is not an error.
+	Grant(ctx context.Context, userID int64, role string) error
+
+	// Revoke removes a role from a user. Revoking a missing role is not an error.
+	Revoke(ctx context.Context, userID int64, role string) error
+
+	// All returns the roles of every user that has at least one.
+	All(ctx context.Context) (map[int64][]string, error)
+}
+
+// MemoryRoleStore keeps roles in memory. Roles are lost on restart.
+type MemoryRoleStore struct {
+	mu    sync.Mutex
+	roles map[int64][]string
+}
+
+// NewMemoryRoleStore creates an empty in-memory role store.
+func NewMemoryRoleStore() *MemoryRoleStore {
+	return &MemoryRoleStore{
+		roles: make(map[int64][]string),
+	}
+}
+
+func (s *MemoryRoleStore) Roles(_ context.Context, userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.roles[userID]), nil
+}
+
+func (s *MemoryRoleStore) Grant(_ context.Context, userID int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grant(userID, role)
+	return nil
+}
+
+func (s *MemoryRoleStore) Revoke(_ context.Context, userID int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoke(userID, role)
+	return nil
+}
+
+func (s *MemoryRoleStore) All(_ context.Context) (map[int64][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make(map[int64][]string, len(s.roles))
+	for userID, roles := range s.roles {
+		all[userID] = slices.Clone(roles)
+	}
+	return all, nil
+}
+
+// grant adds a role. The caller must hold s.mu; it reports whether roles changed.
+func (s *MemoryRoleStore) grant(userID int64, role string) bool {
+	if slices.Contains(s.roles[userID], role) {
+		return false
+	}
+	s.roles[userID] = append(s.roles[userID], role)
+	slices.Sort(s.roles[userID])
+	return true
+}
+
+// revoke removes a role. The caller must hold s.mu; it reports whether roles changed.
+func (s *MemoryRoleStore) revoke(userID int64, role string) bool {
+	i := slices.Index(s.roles[userID], role)
+	if i < 0 {
+		return false
+	}
+	s.roles[userID] = slices.Delete(s.roles[userID], i, i+1)
+	if len(s.roles[userID]) == 0 {
+		delete(s.roles, userID)
+	}
+	return true
+}
+
+// FileRoleStore keeps roles in memory and writes them to a JSON file on
+// every change.
+type FileRoleStore struct {
+	mem  *MemoryRoleStore
+	path string
+}
+
+// NewFileRoleStore loads roles from path, if it exists.
+func NewFileRoleStore(path string) (*FileRoleStore, error) {
+	s := &FileRoleStore{mem: NewMemoryRoleStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role file: %w", err)
+	}
+
+	var stored map[string][]string
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse role file: %w", err)
+	}
+	for k, roles := range stored {
+		userID, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q in role file", k)
+		}
+		for _, role := range roles {
+			s.mem.grant(userID, role)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileRoleStore) Roles(ctx context.Context, userID int64) ([]string, error) {
+	return s.mem.Roles(ctx, userID)
+}
+
+func (s *FileRoleStore) Grant(_ context.Context, userID int64, role string) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if !s.mem.grant(userID, role) {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *FileRoleStore) Revoke(_ context.Context, userID int64, role string) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if !s.mem.revoke(userID, role) {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *FileRoleStore) All(ctx context.Context) (map[int64][]string, error) {
+	return s.mem.All(ctx)
+}
+
+// save writes all roles to the file. The caller must hold s.mem.mu.
+func (s *FileRoleStore) save() error {
+	stored := make(map[string][]string, len(s.mem.roles))
+	for userID, roles := range s.mem.roles {
+		stored[strconv.FormatInt(userID, 10)] = roles
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to write role file: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write role file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write role file: %w", err)
+	}
+	return nil
+}
+
+// GrantRole gives a user a role and updates the user's command menu if
+// the bot is running.
+func (b *Bot) GrantRole(ctx context.Context, userID int64, role string) error {
+	if err := b.config.RoleStore.Grant(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+	return b.syncUserMenu(ctx, userID)
+}
+
+// RevokeRole takes a role from a user and updates the user's command menu
+// if the bot is running.
+func (b *Bot) RevokeRole(ctx context.Context, userID int64, role string) error {
+	if err := b.config.RoleStore.Revoke(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+	return b.syncUserMenu(ctx, userID)
+}
+
+// UserRoles returns the roles of a user.
+func (b *Bot) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	return b.config.RoleStore.Roles(ctx, userID)
+}
+
+// Roles returns the roles of the sender.
+func (c *Context) Roles() []string {
+	if c.roles != nil {
+		return c.roles
+	}
+
+	c.roles = []string{}
+	if userID := c.SenderID(); userID != 0 {
+		roles, err := c.bot.config.RoleStore.Roles(c, userID)
+		if err != nil {
+			c.bot.config.Logger.Warn("failed to load user roles", "error", err)
+		} else if roles != nil {
+			c.roles = roles
+		}
+	}
+	return c.roles
+}
+
+// HasRole reports whether the sender has any of the given roles.
+func (c *Context) HasRole(roles ...string) bool {
+	return slices.ContainsFunc(c.Roles(), func(r string) bool {
+		return slices.Contains(roles, r)
+	})
+}
+
+// rolesAllow reports whether a user with userRoles may use h.
+func rolesAllow(h commandHandler, userRoles []string) bool {
+	if len(h.roles) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(userRoles, func(r string) bool {
+		return slices.Contains(h.roles, r)
+	})
+}
+
+// userMenu returns the private chat menu of a user with the given roles:
+// the commands of the default and private scopes the roles allow. It
+// returns nil if no command requires one of the roles, since the default
+// menu applies then.
+func userMenu(handlers []commandHandler, userRoles []string) []CommandRegistration {
+	var commands []CommandRegistration
+	restricted := false
+	for _, h := range handlers {
+		if h.description == "" || h.langCode != "" || !rolesAllow(h, userRoles) {
+			continue
+		}
+		switch h.scope.(type) {
+		case nil, ScopeDefault, ScopeAllPrivate:
+		default:
+			continue
+		}
+		if slices.ContainsFunc(commands, func(c CommandRegistration) bool { return c.Name == h.name }) {
+			continue
+		}
+		if len(h.roles) > 0 {
+			restricted = true
+		}
+		commands = append(commands, CommandRegistration{
+			Name:        h.name,
+			Description: menuDescription(h),
+		})
+	}
+	if !restricted {
+		return nil
+	}
+	return commands
+}
+
+// syncUserMenu sets the private chat menu of a user from their roles, or
+// resets it to the default menu if the roles unlock no commands.
+func (b *Bot) syncUserMenu(ctx context.Context, userID int64) error {
+	if b.api == nil {
+		return nil
+	}
+
+	roles, err := b.config.RoleStore.Roles(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user roles: %w", err)
+	}
+
+	b.mu.RLock()
+	handlers := b.commandHandlers
+	b.mu.RUnlock()
+
+	scope := ScopeUser{UserID: userID, AccessHash: b.userAccessHash(ctx, userID)}
+	commands := userMenu(handlers, roles)
+	if commands == nil {
+		_, err := b.api.BotsResetBotCommands(ctx, &tg.BotsResetBotCommandsRequest{
+			Scope: scope.toTG(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reset user commands: %w", err)
+		}
+		return nil
+	}
+
+	if err := b.SetCommandsForScope(ctx, scope, "", commands); err != nil {
+		return fmt.Errorf("failed to set user commands: %w", err)
+	}
+
+	b.rememberCommandScope(scopeKeyString(scope, ""))
+	return nil
+}
+
+// syncRoleMenus sets the menus of all users with roles.
+func (b *Bot) syncRoleMenus(ctx context.Context) {
+	all, err := b.config.RoleStore.All(ctx)
+	if err != nil {
+		b.config.Logger.Warn("failed to load roles", "error", err)
+		return
+	}
+	for userID := range all {
+		if err := b.syncUserMenu(ctx, userID); err != nil {
+			b.config.Logger.Warn("failed to sync user commands", "user_id", userID, "error", err)
+		}
+	}
+}
+
+// userAccessHash looks up the access hash of a user, returning 0 if unknown.
+func (b *Bot) userAccessHash(ctx context.Context, userID int64) int64 {
+	users, err := b.api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: userID}})
+	if err != nil {
+		return 0
+	}
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok && user.ID == userID {
+			return user.AccessHash
+		}
+	}
+	return 0
+}
+
+// rememberCommandScope adds a scope key to the saved scopes so that
+// ResetCommands clears it.
+func (b *Bot) rememberCommandScope(key string) {
+	b.scopesMu.Lock()
+	defer b.scopesMu.Unlock()
+
+	scopes := b.loadCommandScopes()
+	if slices.Contains(scopes, key) {
+		return
+	}
+	if err := b.saveCommandScopes(append(scopes, key)); err != nil {
+		b.config.Logger.Warn("failed to save command scopes", "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package telekit
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestRoleStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.json")
+	file, err := NewFileRoleStore(path)
+	if err != nil {
+		t.Fatalf("NewFileRoleStore() error = %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store RoleStore
+	}{
+		{"memory", NewMemoryRoleStore()},
+		{"file", file},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, role := range []string{"moderator", "admin", "admin"} {
+				if err := tt.store.Grant(ctx, 7, role); err != nil {
+					t.Fatalf("Grant() error = %v", err)
+				}
+			}
+			if roles, _ := tt.store.Roles(ctx, 7); !slices.Equal(roles, []string{"admin", "moderator"}) {
+				t.Errorf("Roles() = %v", roles)
+			}
+
+			if err := tt.store.Revoke(ctx, 7, "admin"); err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+			all, _ := tt.store.All(ctx)
+			if len(all) != 1 || !slices.Equal(all[7], []string{"moderator"}) {
+				t.Errorf("All() = %v", all)
+			}
+		})
+	}
+
+	reloaded, err := NewFileRoleStore(path)
+	if err != nil {
+		t.Fatalf("NewFileRoleStore() error = %v", err)
+	}
+	if roles, _ := reloaded.Roles(context.Background(), 7); !slices.Equal(roles, []string{"moderator"}) {
+		t.Errorf("Roles() after reload = %v", roles)
+	}
+}
+
+func TestUserMenu(t *testing.T) {
+	handlers := []commandHandler{
+		{name: "start", description: "Start"},
+		{name: "ban", description: "Ban a user", roles: []string{"moderator", "admin"}},
+		{name: "config", description: "Configure", roles: []string{"owner"}},
+		{name: "groupinfo", description: "Group info", scope: ScopeAllGroups{}},
+		{name: "hidden"},
+	}
+
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"admin"}, []string{"start", "ban"}},
+		{[]string{"owner", "moderator"}, []string{"start", "ban", "config"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, cmd := range userMenu(handlers, tt.roles) {
+			got = append(got, cmd.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("userMenu(%v) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleCommandsRoles(t *testing.T) {
+	b := &Bot{config: Config{RoleStore: NewMemoryRoleStore(), Logger: slog.Default()}}
+	b.commandHandlers = []commandHandler{
+		{name: "start"},
+		{name: "ban", roles: []string{"moderator"}},
+	}
+	if err := b.GrantRole(context.Background(), 1, "moderator"); err != nil {
+		t.Fatalf("GrantRole() error = %v", err)
+	}
+
+	newCtx := func(userID int64) *Context {
+		return &Context{
+			Context: context.Background(),
+			bot:     b,
+			message: &tg.Message{PeerID: &tg.PeerUser{UserID: userID}},
+		}
+	}
+
+	for userID, want := range map[int64][]string{1: {"ban", "start"}, 2: {"start"}} {
+		var got []string
+		for _, h := range b.visibleCommands(newCtx(userID)) {
+			got = append(got, h.name)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("visibleCommands() for user %d = %v, want %v", userID, got, want)
+		}
+	}
+}
+
+func TestSyncUserMenu(t *testing.T) {
+	invoker := &recordingInvoker{}
+	b := &Bot{
+		api:    tg.NewClient(invoker),
+		config: Config{RoleStore: NewMemoryRoleStore(), SessionDir: t.TempDir(), Logger: slog.Default()},
+	}
+	b.commandHandlers = []commandHandler{
+		{name: "start", description: "Start"},
+		{name: "ban", description: "Ban a user", roles: []string{"moderator"}},
+	}
+	ctx := context.Background()
+	_ = b.config.RoleStore.Grant(ctx, 7, "moderator")
+
+	if err := b.syncUserMenu(ctx, 7); err != nil {
+		t.Fatalf("syncUserMenu() error = %v", err)
+	}
+	var req *tg.BotsSetBotCommandsRequest
+	for _, r := range invoker.requests {
+		if set, ok := r.(*tg.BotsSetBotCommandsRequest); ok {
+			req = set
+		}
+	}
+	if req == nil {
+		t.Fatalf("syncUserMenu() sent no commands, requests %#v", invoker.requests)
+	}
+	if scope, ok := req.Scope.(*tg.BotCommandScopePeer); !ok ||
+		!reflect.DeepEqual(scope.Peer, &tg.InputPeerUser{UserID: 7}) {
+		t.Errorf("syncUserMenu() scope = %#v, want the user's private chat", req.Scope)
+	}
+	var got []string
+	for _, cmd := range req.Commands {
+		got = append(got, cmd.Command)
+	}
+	if !slices.Equal(got, []string{"start", "ban"}) {
+		t.Errorf("syncUserMenu() commands = %v, want [start ban]", got)
+	}
+}
@@ -0,0 +1,124 @@
+package telekit
+
+import "sync"
+
+// commandRunner runs command handlers off the update loop, so handlers
+// waiting for the user's next message do not block it.
+//
+// Only the dispatch of a command is ordered: the commands of a user in a
+// chat are dispatched one at a time in arrival order, and each dispatch
+// ends when the handler is about to run or waits for its lock. Handlers
+// then run concurrently, so a long-running command does not hold up the
+// user's next one, e.g. the cancel command or another invocation that has
+// to see its lock taken. Other messages of the user wait for pending
+// dispatches, so an answer is handed to WaitMessage of a command sent
+// before it.
+//
+// At most limit commands run at once; when all slots are taken, the update
+// loop waits for one to free up. A handler that starts waiting with
+// Context.WaitMessage detaches and gives up its slot.
+type commandRunner struct {
+	slots   chan struct{}
+	workers *sync.WaitGroup
+
+	mu    sync.Mutex
+	tails map[conversationKey]chan struct{} // dispatched of the last command per key
+}
+
+// commandTurn is a command's place in the dispatch order of its user and
+// chat.
+type commandTurn struct {
+	runner     *commandRunner
+	key        conversationKey
+	dispatched chan struct{}
+
+	dispatchOnce sync.Once
+	releaseOnce  sync.Once
+}
+
+func newCommandRunner(limit int, workers *sync.WaitGroup) *commandRunner {
+	return &commandRunner{
+		slots:   make(chan struct{}, limit),
+		workers: workers,
+		tails:   make(map[conversationKey]chan struct{}),
+	}
+}
+
+// run runs fn for the sender of ctx in a new goroutine once the sender's
+// earlier commands in the chat are dispatched. fn must call
+// ctx.turn.dispatch before it runs the handler. run reports false without
+// running fn if ctx is done before a slot frees up.
+func (r *commandRunner) run(ctx *Context, fn func()) bool {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	turn := &commandTurn{
+		runner:     r,
+		key:        conversationKey{chatID: ctx.ChatID(), userID: ctx.SenderID()},
+		dispatched: make(chan struct{}),
+	}
+	r.mu.Lock()
+	prev := r.tails[turn.key]
+	r.tails[turn.key] = turn.dispatched
+	r.mu.Unlock()
+	ctx.turn = turn
+
+	r.workers.Add(1)
+	go func() {
+		defer r.workers.Done()
+		defer turn.release()
+		if prev != nil {
+			<-prev
+		}
+		fn()
+	}()
+	return true
+}
+
+// wait blocks until the commands the sender of ctx sent earlier in the
+// chat are dispatched, or ctx is done.
+func (r *commandRunner) wait(ctx *Context) {
+	key := conversationKey{chatID: ctx.ChatID(), userID: ctx.SenderID()}
+	r.mu.Lock()
+	tail := r.tails[key]
+	r.mu.Unlock()
+
+	if tail != nil {
+		select {
+		case <-tail:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// dispatch lets the next command of the turn's user and chat be
+// dispatched. It is safe to call more than once and on a nil turn.
+func (t *commandTurn) dispatch() {
+	if t == nil {
+		return
+	}
+	t.dispatchOnce.Do(func() {
+		close(t.dispatched)
+
+		t.runner.mu.Lock()
+		defer t.runner.mu.Unlock()
+		if t.runner.tails[t.key] == t.dispatched {
+			delete(t.runner.tails, t.key)
+		}
+	})
+}
+
+// release ends the dispatch and frees the turn's slot. It is safe to call
+// more than once and on a nil turn.
+func (t *commandTurn) release() {
+	if t == nil {
+		return
+	}
+	t.dispatch()
+	t.releaseOnce.Do(func() {
+		<-t.runner.slots
+	})
+}
@@ -0,0 +1,154 @@
+package telekit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func newRunnerContext(chatID, userID int64) *Context {
+	return &Context{
+		Context: context.Background(),
+		message: &tg.Message{
+			PeerID: &tg.PeerChat{ChatID: chatID},
+			FromID: &tg.PeerUser{UserID: userID},
+		},
+	}
+}
+
+func TestCommandRunnerOrdersPerUser(t *testing.T) {
+	var workers sync.WaitGroup
+	r := newCommandRunner(8, &workers)
+
+	var mu sync.Mutex
+	var order []int
+	for i := range 5 {
+		r.run(newRunnerContext(1, 1), func() {
+			// later commands must wait even if earlier ones are slow
+			time.Sleep(time.Duration(5-i) * time.Millisecond)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+	}
+	workers.Wait()
+
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("commands ran in order %v, want 0..4", order)
+		}
+	}
+	if len(r.tails) != 0 {
+		t.Errorf("runner keeps %d queues after all commands finished", len(r.tails))
+	}
+}
+
+func TestCommandRunnerDispatch(t *testing.T) {
+	var workers sync.WaitGroup
+	r := newCommandRunner(8, &workers)
+
+	unblock := make(chan struct{})
+	first := newRunnerContext(1, 1)
+	r.run(first, func() {
+		// the handler is about to run
+		first.turn.dispatch()
+		<-unblock
+	})
+
+	done := make(chan struct{})
+	r.run(newRunnerContext(1, 1), func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("next command was not dispatched while the first one ran")
+	}
+
+	// messages of the user wait for pending dispatches only
+	waited := make(chan struct{})
+	go func() {
+		r.wait(newRunnerContext(1, 1))
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait() blocked on a dispatched command")
+	}
+	close(unblock)
+	workers.Wait()
+}
+
+func TestCommandRunnerParallelAcrossUsers(t *testing.T) {
+	var workers sync.WaitGroup
+	r := newCommandRunner(8, &workers)
+
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	for user := range int64(2) {
+		r.run(newRunnerContext(1, user+1), func() {
+			started <- struct{}{}
+			<-unblock
+		})
+	}
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("commands of different users did not run concurrently")
+		}
+	}
+	close(unblock)
+	workers.Wait()
+}
+
+func TestCommandRunnerLimit(t *testing.T) {
+	var workers sync.WaitGroup
+	r := newCommandRunner(1, &workers)
+
+	unblock := make(chan struct{})
+	r.run(newRunnerContext(1, 1), func() { <-unblock })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	blocked := newRunnerContext(1, 2)
+	blocked.Context = ctx
+	if r.run(blocked, func() {}) {
+		t.Error("run() succeeded while all slots were taken")
+	}
+
+	close(unblock)
+	workers.Wait()
+	if !r.run(newRunnerContext(1, 2), func() {}) {
+		t.Error("run() failed after a slot was freed")
+	}
+	workers.Wait()
+}
+
+func TestCommandRunnerRelease(t *testing.T) {
+	var workers sync.WaitGroup
+	r := newCommandRunner(1, &workers)
+
+	waiting := make(chan struct{})
+	unblock := make(chan struct{})
+	first := newRunnerContext(1, 1)
+	r.run(first, func() {
+		// a detached handler, as in WaitMessage
+		first.turn.release()
+		close(waiting)
+		<-unblock
+	})
+	<-waiting
+
+	done := make(chan struct{})
+	r.run(newRunnerContext(1, 1), func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("next command did not run while the first one was detached")
+	}
+	close(unblock)
+	workers.Wait()
+}
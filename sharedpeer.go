@@ -0,0 +1,146 @@
+package telekit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// SharedPeers is the choice a user made with a RequestUsersButton or
+// RequestChatButton.
+type SharedPeers struct {
+	// ButtonID identifies the pressed button.
+	ButtonID int
+
+	// Peers are the picked users or the picked chat. Title and Username
+	// are only set if the button requested them.
+	Peers []Peer
+}
+
+// SharedPeerFunc handles users and chats shared with the bot. ctx refers to
+// the service message carrying the choice; it has no text.
+type SharedPeerFunc func(ctx *Context, shared SharedPeers) error
+
+type sharedPeerHandler struct {
+	buttonID int
+	fn       SharedPeerFunc
+}
+
+// OnSharedPeer registers a handler for users and chats shared through the
+// request button with buttonID.
+func (b *Bot) OnSharedPeer(buttonID int, fn SharedPeerFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sharedPeerHandlers = append(b.sharedPeerHandlers, sharedPeerHandler{buttonID: buttonID, fn: fn})
+}
+
+func (b *Bot) handleServiceMessage(ctx context.Context, msg *tg.MessageService, update tg.UpdateClass, entities tg.Entities) error {
+	if msg.Out {
+		return nil
+	}
+	shared, ok := sharedPeers(msg.Action, entities)
+	if !ok {
+		return nil
+	}
+
+	botCtx := &Context{
+		Context: ctx,
+		bot:     b,
+		message: &tg.Message{
+			ID:     msg.ID,
+			PeerID: msg.PeerID,
+			FromID: msg.FromID,
+			Date:   msg.Date,
+		},
+		update:   update,
+		entities: entities,
+	}
+
+	b.mu.RLock()
+	handlers := b.sharedPeerHandlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if h.buttonID != shared.ButtonID {
+			continue
+		}
+		if err := h.fn(botCtx, shared); err != nil {
+			b.config.Logger.Error("shared peer handler error", "error", err)
+		}
+	}
+	return nil
+}
+
+// sharedPeers extracts the peers shared by a request button from a service
+// message action. Access hashes are taken from entities.
+func sharedPeers(action tg.MessageActionClass, entities tg.Entities) (SharedPeers, bool) {
+	var shared SharedPeers
+
+	switch a := action.(type) {
+	case *tg.MessageActionRequestedPeerSentMe:
+		shared.ButtonID = a.ButtonID
+		for _, requested := range a.Peers {
+			switch p := requested.(type) {
+			case *tg.RequestedPeerUser:
+				shared.Peers = append(shared.Peers, Peer{
+					Kind:     PeerKindUser,
+					ID:       p.UserID,
+					Username: p.Username,
+					Title:    strings.TrimSpace(p.FirstName + " " + p.LastName),
+				})
+			case *tg.RequestedPeerChat:
+				shared.Peers = append(shared.Peers, Peer{Kind: PeerKindChat, ID: p.ChatID, Title: p.Title})
+			case *tg.RequestedPeerChannel:
+				shared.Peers = append(shared.Peers, Peer{
+					Kind:     PeerKindChannel,
+					ID:       p.ChannelID,
+					Username: p.Username,
+					Title:    p.Title,
+				})
+			}
+		}
+	case *tg.MessageActionRequestedPeer:
+		shared.ButtonID = a.ButtonID
+		for _, peer := range a.Peers {
+			switch p := peer.(type) {
+			case *tg.PeerUser:
+				if u, ok := entities.Users[p.UserID]; ok {
+					shared.Peers = append(shared.Peers, peerFromUser(u))
+					continue
+				}
+				shared.Peers = append(shared.Peers, Peer{Kind: PeerKindUser, ID: p.UserID})
+			case *tg.PeerChat:
+				if c, ok := entities.Chats[p.ChatID]; ok {
+					resolved, _ := peerFromChat(c)
+					shared.Peers = append(shared.Peers, resolved)
+					continue
+				}
+				shared.Peers = append(shared.Peers, Peer{Kind: PeerKindChat, ID: p.ChatID})
+			case *tg.PeerChannel:
+				if c, ok := entities.Channels[p.ChannelID]; ok {
+					resolved, _ := peerFromChat(c)
+					shared.Peers = append(shared.Peers, resolved)
+					continue
+				}
+				shared.Peers = append(shared.Peers, Peer{Kind: PeerKindChannel, ID: p.ChannelID})
+			}
+		}
+	default:
+		return SharedPeers{}, false
+	}
+
+	for i, p := range shared.Peers {
+		switch p.Kind {
+		case PeerKindUser:
+			if u, ok := entities.Users[p.ID]; ok {
+				shared.Peers[i].AccessHash = u.AccessHash
+			}
+		case PeerKindChannel:
+			if c, ok := entities.Channels[p.ID]; ok {
+				shared.Peers[i].AccessHash = c.AccessHash
+			}
+		}
+	}
+	return shared, true
+}
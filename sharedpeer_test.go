@@ -0,0 +1,84 @@
+package telekit
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestSharedPeers(t *testing.T) {
+	entities := tg.Entities{
+		Users:    map[int64]*tg.User{10: {ID: 10, AccessHash: 100, FirstName: "Ann", Username: "ann"}},
+		Channels: map[int64]*tg.Channel{20: {ID: 20, AccessHash: 200, Title: "News"}},
+	}
+
+	sentMe := &tg.MessageActionRequestedPeerSentMe{
+		ButtonID: 7,
+		Peers: []tg.RequestedPeerClass{
+			&tg.RequestedPeerUser{UserID: 10, FirstName: "Ann", LastName: "Lee", Username: "ann"},
+			&tg.RequestedPeerChannel{ChannelID: 20, Title: "News"},
+			&tg.RequestedPeerChat{ChatID: 30, Title: "Group"},
+		},
+	}
+	got, ok := sharedPeers(sentMe, entities)
+	want := SharedPeers{ButtonID: 7, Peers: []Peer{
+		{Kind: PeerKindUser, ID: 10, AccessHash: 100, Username: "ann", Title: "Ann Lee"},
+		{Kind: PeerKindChannel, ID: 20, AccessHash: 200, Title: "News"},
+		{Kind: PeerKindChat, ID: 30, Title: "Group"},
+	}}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("sharedPeers(SentMe) = %+v, %v, want %+v", got, ok, want)
+	}
+
+	requested := &tg.MessageActionRequestedPeer{
+		ButtonID: 8,
+		Peers:    []tg.PeerClass{&tg.PeerUser{UserID: 10}, &tg.PeerUser{UserID: 11}},
+	}
+	got, ok = sharedPeers(requested, entities)
+	want = SharedPeers{ButtonID: 8, Peers: []Peer{
+		{Kind: PeerKindUser, ID: 10, AccessHash: 100, Username: "ann", Title: "Ann"},
+		{Kind: PeerKindUser, ID: 11},
+	}}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("sharedPeers(Requested) = %+v, %v, want %+v", got, ok, want)
+	}
+
+	if _, ok := sharedPeers(&tg.MessageActionPinMessage{}, entities); ok {
+		t.Error("sharedPeers() accepted an unrelated action")
+	}
+}
+
+func TestHandleServiceMessage(t *testing.T) {
+	b := &Bot{config: Config{Logger: slog.Default()}}
+
+	var calls []int
+	b.OnSharedPeer(1, func(ctx *Context, shared SharedPeers) error {
+		if ctx.ChatID() != 5 || ctx.MessageID() != 42 {
+			t.Errorf("context chat = %d, message = %d", ctx.ChatID(), ctx.MessageID())
+		}
+		calls = append(calls, shared.ButtonID)
+		return nil
+	})
+	b.OnSharedPeer(2, func(ctx *Context, shared SharedPeers) error {
+		calls = append(calls, shared.ButtonID)
+		return nil
+	})
+
+	msg := &tg.MessageService{
+		ID:     42,
+		PeerID: &tg.PeerUser{UserID: 5},
+		Action: &tg.MessageActionRequestedPeerSentMe{
+			ButtonID: 1,
+			Peers:    []tg.RequestedPeerClass{&tg.RequestedPeerUser{UserID: 10}},
+		},
+	}
+	if err := b.handleServiceMessage(context.Background(), msg, &tg.UpdateNewMessage{Message: msg}, tg.Entities{}); err != nil {
+		t.Fatalf("handleServiceMessage() error = %v", err)
+	}
+	if !reflect.DeepEqual(calls, []int{1}) {
+		t.Errorf("handlers called for buttons %v, want [1]", calls)
+	}
+}
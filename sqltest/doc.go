@@ -0,0 +1,4 @@
+// Package sqltest runs the SQL-backed stores of telekit against SQLite.
+// It is a separate module so the root module does not depend on a
+// database driver; run its tests from this directory with go test.
+package sqltest
@@ -0,0 +1,119 @@
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/en9inerd/telekit"
+)
+
+// newSharedLocks returns two SQLLocks on separate connections to one
+// database, like two bot processes.
+func newSharedLocks(t *testing.T) (*telekit.SQLLock, *telekit.SQLLock) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "locks.db")
+	var locks []*telekit.SQLLock
+	for range 2 {
+		db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+		if err != nil {
+			t.Fatalf("sql.Open() error = %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		l, err := telekit.NewSQLLock(context.Background(), db, "locks", telekit.SQLDialectQuestion)
+		if err != nil {
+			t.Fatalf("NewSQLLock() error = %v", err)
+		}
+		locks = append(locks, l)
+	}
+	return locks[0], locks[1]
+}
+
+func TestSQLLockLease(t *testing.T) {
+	first, second := newSharedLocks(t)
+	ctx := context.Background()
+
+	token, ok, err := first.Acquire(ctx, "/user:1", 20*time.Millisecond)
+	if !ok || err != nil {
+		t.Fatalf("Acquire() = %v, %v", ok, err)
+	}
+	if _, ok, err := second.Acquire(ctx, "/user:1", time.Minute); ok || err != nil {
+		t.Errorf("Acquire() of a held lock = %v, %v, want false", ok, err)
+	}
+	if err := first.Refresh(ctx, "/user:1", token, 20*time.Millisecond); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	taken, ok, err := second.Acquire(ctx, "/user:1", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("Acquire() after expiry = %v, %v", ok, err)
+	}
+	if err := first.Refresh(ctx, "/user:1", token, time.Minute); !errors.Is(err, telekit.ErrLockLost) {
+		t.Errorf("Refresh() of a lost lease error = %v, want %v", err, telekit.ErrLockLost)
+	}
+	if err := first.Release(ctx, "/user:1", token); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, ok, _ := first.Acquire(ctx, "/user:1", time.Minute); ok {
+		t.Error("Release() of a lost lease freed the new holder's lock")
+	}
+	if err := second.Release(ctx, "/user:1", taken); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, ok, _ := first.Acquire(ctx, "/user:1", time.Minute); !ok {
+		t.Error("Acquire() after Release() failed")
+	}
+}
+
+func TestSQLLockContention(t *testing.T) {
+	first, second := newSharedLocks(t)
+	ctx := context.Background()
+
+	// Holders release right after taking the lock, so acquires race with
+	// releases. Every acquire must either get the lock or see it held.
+	var (
+		mu      sync.Mutex
+		holders int
+		wg      sync.WaitGroup
+	)
+	for i := range 8 {
+		l := first
+		if i%2 == 1 {
+			l = second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				token, ok, err := l.Acquire(ctx, "/chat:5", time.Minute)
+				if err != nil {
+					t.Errorf("Acquire() error = %v", err)
+					return
+				}
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				holders++
+				if holders > 1 {
+					t.Error("two holders of one lock")
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				holders--
+				mu.Unlock()
+				if err := l.Release(ctx, "/chat:5", token); err != nil {
+					t.Errorf("Release() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
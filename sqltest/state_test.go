@@ -0,0 +1,124 @@
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/en9inerd/telekit"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "telekit.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLStateStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := telekit.NewSQLStateStore(ctx, openDB(t), "states", telekit.SQLDialectQuestion)
+	if err != nil {
+		t.Fatalf("NewSQLStateStore() error = %v", err)
+	}
+	key := telekit.StateKey{ChatID: -100, UserID: 7}
+
+	if _, ok, err := s.Get(ctx, key); ok || err != nil {
+		t.Fatalf("Get() on empty store = %v, %v", ok, err)
+	}
+
+	expires := time.Unix(1700000000, 5)
+	want := telekit.State{Name: "order:address", Data: map[string]string{"item": "tea"}, ExpiresAt: expires}
+	if err := s.Set(ctx, key, want); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, ok, err := s.Get(ctx, key)
+	if !ok || err != nil || got.Name != want.Name || got.Data["item"] != "tea" || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("Get() = %+v, %v, %v", got, ok, err)
+	}
+
+	want.Name = "order:confirm"
+	want.ExpiresAt = time.Time{}
+	if err := s.Set(ctx, key, want); err != nil {
+		t.Fatalf("Set() over an existing state error = %v", err)
+	}
+	got, _, _ = s.Get(ctx, key)
+	if got.Name != "order:confirm" || !got.ExpiresAt.IsZero() {
+		t.Errorf("Get() after update = %+v", got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, key); ok {
+		t.Error("Get() after Delete() found a state")
+	}
+}
+
+func TestSQLStateStoreTakeExpired(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	// two bot processes sharing the table
+	first, err := telekit.NewSQLStateStore(ctx, db, "states", telekit.SQLDialectQuestion)
+	if err != nil {
+		t.Fatalf("NewSQLStateStore() error = %v", err)
+	}
+	second, _ := telekit.NewSQLStateStore(ctx, db, "states", telekit.SQLDialectQuestion)
+
+	now := time.Now()
+	old := telekit.StateKey{ChatID: 1, UserID: 1}
+	_ = first.Set(ctx, old, telekit.State{Name: "old", Data: map[string]string{"a": "b"}, ExpiresAt: now.Add(-time.Second)})
+	_ = first.Set(ctx, telekit.StateKey{ChatID: 1, UserID: 2}, telekit.State{Name: "live", ExpiresAt: now.Add(time.Hour)})
+	_ = first.Set(ctx, telekit.StateKey{ChatID: 1, UserID: 3}, telekit.State{Name: "forever"})
+
+	expired, err := first.TakeExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("TakeExpired() error = %v", err)
+	}
+	if len(expired) != 1 || expired[old].Name != "old" || expired[old].Data["a"] != "b" {
+		t.Errorf("TakeExpired() = %+v, want only %+v", expired, old)
+	}
+	if again, _ := second.TakeExpired(ctx, now); len(again) != 0 {
+		t.Errorf("TakeExpired() on the second store = %+v, want none", again)
+	}
+	for _, user := range []int64{2, 3} {
+		if _, ok, _ := second.Get(ctx, telekit.StateKey{ChatID: 1, UserID: user}); !ok {
+			t.Errorf("TakeExpired() removed the live state of user %d", user)
+		}
+	}
+}
+
+func TestSQLStateStoreDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store, err := telekit.NewSQLStateStore(ctx, db, "states", telekit.SQLDialectQuestion)
+	if err != nil {
+		t.Fatalf("NewSQLStateStore() error = %v", err)
+	}
+
+	key := telekit.StateKey{ChatID: 1, UserID: 1}
+	_ = store.Set(ctx, key, telekit.State{Name: "old", ExpiresAt: time.Now().Add(-time.Second)})
+	stored, _, _ := store.Get(ctx, key)
+
+	if ok, err := store.DeleteExpired(ctx, key, stored.ExpiresAt.Add(time.Second)); err != nil || ok {
+		t.Errorf("DeleteExpired() of a renewed state = %v, %v; want false", ok, err)
+	}
+	if ok, err := store.DeleteExpired(ctx, key, stored.ExpiresAt); err != nil || !ok {
+		t.Errorf("DeleteExpired() = %v, %v; want true", ok, err)
+	}
+	if ok, err := store.DeleteExpired(ctx, key, stored.ExpiresAt); err != nil || ok {
+		t.Errorf("DeleteExpired() again = %v, %v; want false", ok, err)
+	}
+}
+
+func TestNewSQLStateStoreRejectsTableName(t *testing.T) {
+	if _, err := telekit.NewSQLStateStore(context.Background(), openDB(t), "states; DROP TABLE x", telekit.SQLDialectQuestion); err == nil {
+		t.Error("NewSQLStateStore() accepted an invalid table name")
+	}
+}
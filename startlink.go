@@ -0,0 +1,183 @@
+package telekit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StartPayloadFunc handles a /start command opened from a deep link.
+// payload is the decoded link payload with the handler's prefix removed.
+type StartPayloadFunc func(ctx *Context, payload string) error
+
+type startHandler struct {
+	prefix string
+	fn     StartPayloadFunc
+}
+
+// Start link payload layout, before base64url encoding:
+//
+//	flags (1 byte) | payload | expiry (4 bytes, if flagged) | MAC (8 bytes, if flagged)
+const (
+	startFlagExpiry byte = 1 << iota
+	startFlagSigned
+
+	startExpiryLen = 4
+	startMACLen    = 8
+
+	// maxStartParam is Telegram's limit for the start parameter.
+	maxStartParam = 64
+)
+
+// OnStartPayload registers a handler for /start commands opened from links
+// created with StartLink or StartGroupLink whose payload begins with prefix.
+// Handlers are tried in registration order; the first matching prefix wins.
+// Links with an invalid signature or an expired payload are ignored and the
+// command is handled like a plain /start.
+//
+// If a start command is registered, payloads are only routed for users it
+// accepts, after its filter, roles, admin requirement, rate limits and lock,
+// and instead of its handler.
+func (b *Bot) OnStartPayload(prefix string, fn StartPayloadFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.startHandlers = append(b.startHandlers, startHandler{prefix: prefix, fn: fn})
+}
+
+// StartLink returns a t.me link that opens a private chat with the bot and
+// sends /start with payload. The payload is signed with Config.StartLinkSecret
+// and expires after Config.StartLinkTTL when those are set.
+func (b *Bot) StartLink(payload string) (string, error) {
+	return b.startLink("start", payload)
+}
+
+// StartGroupLink returns a t.me link that asks the user to add the bot to a
+// group and sends /start with payload there.
+func (b *Bot) StartGroupLink(payload string) (string, error) {
+	return b.startLink("startgroup", payload)
+}
+
+func (b *Bot) startLink(param, payload string) (string, error) {
+	if b.selfUsername == "" {
+		return "", ErrBotNotRunning
+	}
+
+	var expiry time.Time
+	if b.config.StartLinkTTL > 0 {
+		expiry = time.Now().Add(b.config.StartLinkTTL)
+	}
+	encoded, err := encodeStartPayload(payload, b.config.StartLinkSecret, expiry)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://t.me/%s?%s=%s", b.selfUsername, param, encoded), nil
+}
+
+// startPayloadHandler returns a func running the first start handler
+// matching the payload of the /start command in ctx, or nil if there is no
+// payload or no handler for it.
+func (b *Bot) startPayloadHandler(ctx *Context) func() error {
+	fields := strings.Fields(ctx.Text())
+	if len(fields) != 2 {
+		return nil
+	}
+
+	b.mu.RLock()
+	handlers := b.startHandlers
+	b.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	payload, err := decodeStartPayload(fields[1], b.config.StartLinkSecret, time.Now())
+	if err != nil {
+		b.config.Logger.Debug("invalid start payload",
+			"sender_id", ctx.SenderID(),
+			"error", err)
+		return nil
+	}
+
+	for _, h := range handlers {
+		if rest, ok := strings.CutPrefix(payload, h.prefix); ok {
+			return func() error { return h.fn(ctx, rest) }
+		}
+	}
+	return nil
+}
+
+// encodeStartPayload encodes payload for use as a start parameter, signing
+// it if secret is not empty and adding expiry if it is not zero.
+func encodeStartPayload(payload string, secret []byte, expiry time.Time) (string, error) {
+	var flags byte
+	data := []byte{0}
+	data = append(data, payload...)
+	if !expiry.IsZero() {
+		flags |= startFlagExpiry
+		data = binary.BigEndian.AppendUint32(data, uint32(expiry.Unix()))
+	}
+	if len(secret) > 0 {
+		flags |= startFlagSigned
+	}
+	data[0] = flags
+	if len(secret) > 0 {
+		data = append(data, startMAC(secret, data)...)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	if len(encoded) > maxStartParam {
+		return "", fmt.Errorf("%w: %d characters encoded, maximum is %d",
+			ErrStartPayloadTooLong, len(encoded), maxStartParam)
+	}
+	return encoded, nil
+}
+
+// decodeStartPayload reverses encodeStartPayload. When secret is set,
+// unsigned payloads are rejected.
+func decodeStartPayload(param string, secret []byte, now time.Time) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(param)
+	if err != nil || len(data) == 0 {
+		return "", ErrInvalidStartPayload
+	}
+
+	flags := data[0]
+	if flags&^(startFlagExpiry|startFlagSigned) != 0 {
+		return "", ErrInvalidStartPayload
+	}
+
+	if flags&startFlagSigned != 0 {
+		if len(secret) == 0 || len(data) < 1+startMACLen {
+			return "", ErrInvalidStartPayload
+		}
+		body, mac := data[:len(data)-startMACLen], data[len(data)-startMACLen:]
+		if !hmac.Equal(mac, startMAC(secret, body)) {
+			return "", ErrInvalidStartPayload
+		}
+		data = body
+	} else if len(secret) > 0 {
+		return "", ErrInvalidStartPayload
+	}
+
+	data = data[1:]
+	if flags&startFlagExpiry != 0 {
+		if len(data) < startExpiryLen {
+			return "", ErrInvalidStartPayload
+		}
+		expiry := binary.BigEndian.Uint32(data[len(data)-startExpiryLen:])
+		if now.Unix() > int64(expiry) {
+			return "", ErrStartPayloadExpired
+		}
+		data = data[:len(data)-startExpiryLen]
+	}
+	return string(data), nil
+}
+
+func startMAC(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:startMACLen]
+}
@@ -0,0 +1,139 @@
+package telekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestStartPayloadRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := []byte("secret")
+
+	tests := []struct {
+		name    string
+		payload string
+		secret  []byte
+		expiry  time.Time
+	}{
+		{"plain", "ref:42", nil, time.Time{}},
+		{"signed", "invite:abc", secret, time.Time{}},
+		{"expiring", "promo", nil, now.Add(time.Hour)},
+		{"signed and expiring", "promo", secret, now.Add(time.Hour)},
+		{"empty", "", secret, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeStartPayload(tt.payload, tt.secret, tt.expiry)
+			if err != nil {
+				t.Fatalf("encodeStartPayload() error = %v", err)
+			}
+			if strings.ContainsAny(encoded, "+/=") {
+				t.Errorf("encoded payload %q is not base64url", encoded)
+			}
+			got, err := decodeStartPayload(encoded, tt.secret, now)
+			if err != nil || got != tt.payload {
+				t.Errorf("decodeStartPayload() = %q, %v, want %q", got, err, tt.payload)
+			}
+		})
+	}
+}
+
+func TestDecodeStartPayloadRejects(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := []byte("secret")
+
+	signed, _ := encodeStartPayload("ref:42", secret, time.Time{})
+	unsigned, _ := encodeStartPayload("ref:42", nil, time.Time{})
+	expired, _ := encodeStartPayload("ref:42", secret, now.Add(-time.Minute))
+	forged, _ := encodeStartPayload("ref:43", []byte("other"), time.Time{})
+
+	tests := []struct {
+		name   string
+		param  string
+		secret []byte
+		want   error
+	}{
+		{"unsigned with secret", unsigned, secret, ErrInvalidStartPayload},
+		{"wrong secret", forged, secret, ErrInvalidStartPayload},
+		{"signed without secret", signed, nil, ErrInvalidStartPayload},
+		{"tampered", signed[:len(signed)-1] + "A", secret, ErrInvalidStartPayload},
+		{"expired", expired, secret, ErrStartPayloadExpired},
+		{"not base64", "a+b", nil, ErrInvalidStartPayload},
+		{"empty", "", nil, ErrInvalidStartPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeStartPayload(tt.param, tt.secret, now); !errors.Is(err, tt.want) {
+				t.Errorf("decodeStartPayload() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeStartPayloadTooLong(t *testing.T) {
+	_, err := encodeStartPayload(strings.Repeat("x", 60), nil, time.Time{})
+	if !errors.Is(err, ErrStartPayloadTooLong) {
+		t.Errorf("encodeStartPayload() error = %v, want %v", err, ErrStartPayloadTooLong)
+	}
+}
+
+func TestStartPayloadRouting(t *testing.T) {
+	param, err := encodeStartPayload("ref:42", nil, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		register    bool
+		sender      int64
+		wantPayload string
+		wantStart   bool
+	}{
+		{"no start command", false, 2, "42", false},
+		{"allowed by start filter", true, 1, "42", false},
+		{"rejected by start filter", true, 2, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{
+				invocations: newInvocationRegistry(),
+				config:      Config{Logger: slog.Default(), ErrorReply: ErrorReplySilent, RoleStore: NewMemoryRoleStore()},
+			}
+			var gotPayload string
+			b.OnStartPayload("ref:", func(_ *Context, payload string) error {
+				gotPayload = payload
+				return nil
+			})
+			started := false
+			if tt.register {
+				b.CommandWithFilter(CommandDef{Name: "start"}, Filter{Users: []int64{1}}, func(*Context) error {
+					started = true
+					return nil
+				})
+			}
+
+			ctx := &Context{
+				Context: context.Background(),
+				bot:     b,
+				message: &tg.Message{
+					Message: "/start " + param,
+					PeerID:  &tg.PeerUser{UserID: tt.sender},
+					FromID:  &tg.PeerUser{UserID: tt.sender},
+				},
+			}
+			if err := b.handleCommand(ctx); err != nil {
+				t.Fatalf("handleCommand() error = %v", err)
+			}
+			if gotPayload != tt.wantPayload || started != tt.wantStart {
+				t.Errorf("payload = %q, start ran = %v; want %q, %v", gotPayload, started, tt.wantPayload, tt.wantStart)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package telekit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateKey identifies a dialog: one user in one chat.
+type StateKey struct {
+	ChatID int64
+	UserID int64
+}
+
+// State is the dialog state of a user in a chat.
+type State struct {
+	// Name is the current state, e.g. "order:address".
+	Name string `json:"name"`
+
+	// Data holds values collected during the dialog.
+	Data map[string]string `json:"data,omitempty"`
+
+	// ExpiresAt is when the state times out. Zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func (s State) expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+// StateStore persists dialog states. Implementations must be safe for
+// concurrent use. Get returns false if there is no state for key.
+type StateStore interface {
+	Get(ctx context.Context, key StateKey) (State, bool, error)
+	Set(ctx context.Context, key StateKey, state State) error
+	Delete(ctx context.Context, key StateKey) error
+}
+
+// StateSweeper is implemented by state stores that can find expired
+// states. The bot sweeps such stores every Config.StateSweepInterval, so
+// dialogs abandoned by their users time out and run StateDef.OnTimeout.
+type StateSweeper interface {
+	// TakeExpired removes the states that expired before now and returns
+	// them. A state taken by one caller is not returned to another.
+	TakeExpired(ctx context.Context, now time.Time) (map[StateKey]State, error)
+
+	// DeleteExpired removes the state of key if it still expires at
+	// expiresAt and reports whether it did, so an expired state found by
+	// several callers is cleared by only one of them.
+	DeleteExpired(ctx context.Context, key StateKey, expiresAt time.Time) (bool, error)
+}
+
+// MemoryStateStore keeps states in memory. States are lost on restart.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[StateKey]State
+}
+
+// NewMemoryStateStore creates an empty in-memory state store.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states: make(map[StateKey]State),
+	}
+}
+
+func (s *MemoryStateStore) Get(_ context.Context, key StateKey) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	state.Data = maps.Clone(state.Data)
+	return state, ok, nil
+}
+
+func (s *MemoryStateStore) Set(_ context.Context, key StateKey, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Data = maps.Clone(state.Data)
+	s.states[key] = state
+	return nil
+}
+
+func (s *MemoryStateStore) Delete(_ context.Context, key StateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
+}
+
+func (s *MemoryStateStore) DeleteExpired(_ context.Context, key StateKey, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteExpired(key, expiresAt), nil
+}
+
+// deleteExpired removes the state of key if it expires at expiresAt. The
+// caller must hold s.mu.
+func (s *MemoryStateStore) deleteExpired(key StateKey, expiresAt time.Time) bool {
+	state, ok := s.states[key]
+	if !ok || !state.ExpiresAt.Equal(expiresAt) {
+		return false
+	}
+	delete(s.states, key)
+	return true
+}
+
+func (s *MemoryStateStore) TakeExpired(_ context.Context, now time.Time) (map[StateKey]State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.takeExpired(now), nil
+}
+
+// takeExpired removes and returns expired states. The caller must hold s.mu.
+func (s *MemoryStateStore) takeExpired(now time.Time) map[StateKey]State {
+	var expired map[StateKey]State
+	for key, state := range s.states {
+		if !state.expired(now) {
+			continue
+		}
+		if expired == nil {
+			expired = make(map[StateKey]State)
+		}
+		expired[key] = state
+		delete(s.states, key)
+	}
+	return expired
+}
+
+// FileStateStore keeps states in memory and writes them to a JSON file on
+// every change, so dialogs survive restarts of a single bot process.
+type FileStateStore struct {
+	mem  *MemoryStateStore
+	path string
+}
+
+// NewFileStateStore loads states from path, if it exists.
+func NewFileStateStore(path string) (*FileStateStore, error) {
+	s := &FileStateStore{mem: NewMemoryStateStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var stored map[string]State
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	for k, state := range stored {
+		key, ok := parseStateKey(k)
+		if !ok {
+			return nil, fmt.Errorf("invalid key %q in state file", k)
+		}
+		s.mem.states[key] = state
+	}
+	return s, nil
+}
+
+func (s *FileStateStore) Get(ctx context.Context, key StateKey) (State, bool, error) {
+	return s.mem.Get(ctx, key)
+}
+
+func (s *FileStateStore) Set(_ context.Context, key StateKey, state State) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	state.Data = maps.Clone(state.Data)
+	s.mem.states[key] = state
+	return s.save()
+}
+
+func (s *FileStateStore) Delete(_ context.Context, key StateKey) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if _, ok := s.mem.states[key]; !ok {
+		return nil
+	}
+	delete(s.mem.states, key)
+	return s.save()
+}
+
+func (s *FileStateStore) DeleteExpired(_ context.Context, key StateKey, expiresAt time.Time) (bool, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if !s.mem.deleteExpired(key, expiresAt) {
+		return false, nil
+	}
+	if err := s.save(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileStateStore) TakeExpired(_ context.Context, now time.Time) (map[StateKey]State, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	expired := s.mem.takeExpired(now)
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// save writes all states to the file. The caller must hold s.mem.mu.
+func (s *FileStateStore) save() error {
+	stored := make(map[string]State, len(s.mem.states))
+	for key, state := range s.mem.states {
+		stored[formatStateKey(key)] = state
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+func formatStateKey(key StateKey) string {
+	return strconv.FormatInt(key.ChatID, 10) + ":" + strconv.FormatInt(key.UserID, 10)
+}
+
+func parseStateKey(s string) (StateKey, bool) {
+	chat, user, ok := strings.Cut(s, ":")
+	if !ok {
+		return StateKey{}, false
+	}
+	chatID, err1 := strconv.ParseInt(chat, 10, 64)
+	userID, err2 := strconv.ParseInt(user, 10, 64)
+	if err1 != nil || err2 != nil {
+		return StateKey{}, false
+	}
+	return StateKey{ChatID: chatID, UserID: userID}, true
+}
+
+// SQLDialect selects the placeholder style of SQL statements.
+type SQLDialect int
+
+const (
+	// SQLDialectQuestion uses "?" placeholders (SQLite, MySQL).
+	SQLDialectQuestion SQLDialect = iota
+
+	// SQLDialectDollar uses "$1" placeholders (PostgreSQL).
+	SQLDialectDollar
+)
+
+// SQLStateStore keeps states in a database table, so several bot processes
+// can share dialogs. The caller opens db with a driver of their choice.
+type SQLStateStore struct {
+	db      *sql.DB
+	table   string
+	dialect SQLDialect
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewSQLStateStore creates the table if needed and returns a store using it.
+func NewSQLStateStore(ctx context.Context, db *sql.DB, table string, dialect SQLDialect) (*SQLStateStore, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	s := &SQLStateStore{db: db, table: table, dialect: dialect}
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		chat_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		data TEXT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state table: %w", err)
+	}
+	return s, nil
+}
+
+func (s *SQLStateStore) query(q string) string {
+	return rebindQuery(q, s.dialect)
+}
+
+// rebindQuery replaces "?" placeholders according to the dialect.
+func rebindQuery(q string, dialect SQLDialect) string {
+	if dialect != SQLDialectDollar {
+		return q
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (s *SQLStateStore) Get(ctx context.Context, key StateKey) (State, bool, error) {
+	var (
+		state     State
+		data      string
+		expiresAt int64
+	)
+	err := s.db.QueryRowContext(ctx,
+		s.query(`SELECT name, data, expires_at FROM `+s.table+` WHERE chat_id = ? AND user_id = ?`),
+		key.ChatID, key.UserID,
+	).Scan(&state.Name, &data, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return State{}, false, nil
+	}
+	if err != nil {
+		return State{}, false, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(data), &state.Data); err != nil {
+		return State{}, false, fmt.Errorf("failed to parse state data: %w", err)
+	}
+	if expiresAt != 0 {
+		state.ExpiresAt = time.Unix(0, expiresAt)
+	}
+	return state, true, nil
+}
+
+func (s *SQLStateStore) Set(ctx context.Context, key StateKey, state State) error {
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return err
+	}
+	var expiresAt int64
+	if !state.ExpiresAt.IsZero() {
+		expiresAt = state.ExpiresAt.UnixNano()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		s.query(`DELETE FROM `+s.table+` WHERE chat_id = ? AND user_id = ?`),
+		key.ChatID, key.UserID,
+	); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		s.query(`INSERT INTO `+s.table+` (chat_id, user_id, name, data, expires_at) VALUES (?, ?, ?, ?, ?)`),
+		key.ChatID, key.UserID, state.Name, string(data), expiresAt,
+	); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStateStore) Delete(ctx context.Context, key StateKey) error {
+	_, err := s.db.ExecContext(ctx,
+		s.query(`DELETE FROM `+s.table+` WHERE chat_id = ? AND user_id = ?`),
+		key.ChatID, key.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete state: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStateStore) DeleteExpired(ctx context.Context, key StateKey, expiresAt time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		s.query(`DELETE FROM `+s.table+` WHERE chat_id = ? AND user_id = ? AND expires_at = ?`),
+		key.ChatID, key.UserID, expiresAt.UnixNano(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired state: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired state: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLStateStore) TakeExpired(ctx context.Context, now time.Time) (map[StateKey]State, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.query(`SELECT chat_id, user_id, name, data, expires_at FROM `+s.table+` WHERE expires_at != 0 AND expires_at < ?`),
+		now.UnixNano(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load expired states: %w", err)
+	}
+
+	type expiredRow struct {
+		key       StateKey
+		state     State
+		expiresAt int64
+	}
+	var found []expiredRow
+	for rows.Next() {
+		var (
+			r    expiredRow
+			data string
+		)
+		if err := rows.Scan(&r.key.ChatID, &r.key.UserID, &r.state.Name, &data, &r.expiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to load expired states: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &r.state.Data); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse state data: %w", err)
+		}
+		r.state.ExpiresAt = time.Unix(0, r.expiresAt)
+		found = append(found, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load expired states: %w", err)
+	}
+
+	// Only states this call deletes are returned, so several bot processes
+	// sweeping the same table do not report a state twice, and states that
+	// were renewed meanwhile are kept.
+	expired := make(map[StateKey]State)
+	for _, r := range found {
+		res, err := s.db.ExecContext(ctx,
+			s.query(`DELETE FROM `+s.table+` WHERE chat_id = ? AND user_id = ? AND expires_at = ?`),
+			r.key.ChatID, r.key.UserID, r.expiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete expired state: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			expired[r.key] = r.state
+		}
+	}
+	return expired, nil
+}
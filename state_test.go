@@ -0,0 +1,258 @@
+package telekit
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateStores(t *testing.T) {
+	file, err := NewFileStateStore(filepath.Join(t.TempDir(), "states.json"))
+	if err != nil {
+		t.Fatalf("NewFileStateStore() error = %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store StateStore
+	}{
+		{"memory", NewMemoryStateStore()},
+		{"file", file},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := StateKey{ChatID: -100, UserID: 7}
+
+			if _, ok, err := tt.store.Get(ctx, key); ok || err != nil {
+				t.Fatalf("Get() on empty store = %v, %v", ok, err)
+			}
+
+			want := State{Name: "order:address", Data: map[string]string{"item": "tea"}}
+			if err := tt.store.Set(ctx, key, want); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			want.Data["item"] = "changed"
+
+			got, ok, err := tt.store.Get(ctx, key)
+			if !ok || err != nil || got.Name != "order:address" || got.Data["item"] != "tea" {
+				t.Errorf("Get() = %+v, %v, %v", got, ok, err)
+			}
+
+			if err := tt.store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, ok, _ := tt.store.Get(ctx, key); ok {
+				t.Error("Get() after Delete() found a state")
+			}
+		})
+	}
+}
+
+func TestFileStateStoreReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "states.json")
+	key := StateKey{ChatID: 1, UserID: 2}
+	expires := time.Unix(1700000000, 0)
+
+	s, _ := NewFileStateStore(path)
+	if err := s.Set(ctx, key, State{Name: "ticket", Data: map[string]string{"id": "9"}, ExpiresAt: expires}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	reloaded, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore() error = %v", err)
+	}
+	got, ok, _ := reloaded.Get(ctx, key)
+	if !ok || got.Name != "ticket" || got.Data["id"] != "9" || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("Get() after reload = %+v, %v", got, ok)
+	}
+}
+
+func TestContextState(t *testing.T) {
+	b := &Bot{config: Config{StateStore: NewMemoryStateStore(), Logger: slog.Default()}}
+	var timedOut []string
+	b.OnState(StateDef{
+		Name:    "short",
+		Timeout: time.Nanosecond,
+		OnTimeout: func(_ context.Context, _ StateKey, state State) error {
+			timedOut = append(timedOut, state.Name)
+			return nil
+		},
+	}, func(*Context) error { return nil })
+
+	newCtx := func() *Context {
+		ctx := groupMessage(1, 10, "hello")
+		ctx.bot = b
+		return ctx
+	}
+
+	ctx := newCtx()
+	if err := ctx.SetState("name"); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+	if err := ctx.SetStateData("name", "Ann"); err != nil {
+		t.Fatalf("SetStateData() error = %v", err)
+	}
+
+	ctx = newCtx()
+	if ctx.State() != "name" || ctx.StateData()["name"] != "Ann" {
+		t.Errorf("State() = %q, data %v", ctx.State(), ctx.StateData())
+	}
+	if f := (Filter{States: []string{"name"}}); !f.matches(ctx) {
+		t.Error("Filter with the current state did not match")
+	}
+	if f := (Filter{States: []string{"other"}}); f.matches(ctx) {
+		t.Error("Filter with another state matched")
+	}
+
+	if err := ctx.SetState("short"); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if got := newCtx().State(); got != "" {
+		t.Errorf("State() after timeout = %q, want empty", got)
+	}
+	if len(timedOut) != 1 {
+		t.Errorf("OnTimeout ran %d times, want 1", len(timedOut))
+	}
+
+	if err := ctx.ClearState(); err != nil || ctx.State() != "" {
+		t.Errorf("ClearState() = %v, state %q", err, ctx.State())
+	}
+}
+
+// sweptStateStore is a store whose expired states are swept between Get
+// and the caller's delete.
+type sweptStateStore struct {
+	*MemoryStateStore
+}
+
+func (s sweptStateStore) Get(ctx context.Context, key StateKey) (State, bool, error) {
+	state, ok, err := s.MemoryStateStore.Get(ctx, key)
+	_, _ = s.TakeExpired(ctx, time.Now())
+	return state, ok, err
+}
+
+func TestContextStateTimeoutOnce(t *testing.T) {
+	store := sweptStateStore{NewMemoryStateStore()}
+	b := &Bot{config: Config{StateStore: store, Logger: slog.Default()}}
+	timedOut := 0
+	b.OnState(StateDef{
+		Name: "short",
+		OnTimeout: func(context.Context, StateKey, State) error {
+			timedOut++
+			return nil
+		},
+	}, func(*Context) error { return nil })
+
+	key := StateKey{ChatID: 1, UserID: 10}
+	_ = store.Set(context.Background(), key, State{Name: "short", ExpiresAt: time.Now().Add(-time.Second)})
+
+	ctx := groupMessage(1, 10, "hello")
+	ctx.bot = b
+	if got := ctx.State(); got != "" {
+		t.Errorf("State() = %q, want empty", got)
+	}
+	if timedOut != 0 {
+		t.Errorf("OnTimeout ran %d times for a state the sweep took, want 0", timedOut)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	file, err := NewFileStateStore(filepath.Join(t.TempDir(), "states.json"))
+	if err != nil {
+		t.Fatalf("NewFileStateStore() error = %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store StateSweeper
+	}{
+		{"memory", NewMemoryStateStore()},
+		{"file", file},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tt.store.(StateStore)
+			key := StateKey{ChatID: 1, UserID: 1}
+			expiresAt := time.Now().Add(-time.Second)
+			_ = store.Set(ctx, key, State{Name: "old", ExpiresAt: expiresAt})
+
+			if ok, err := tt.store.DeleteExpired(ctx, key, expiresAt.Add(time.Second)); err != nil || ok {
+				t.Errorf("DeleteExpired() of a renewed state = %v, %v; want false", ok, err)
+			}
+			if ok, err := tt.store.DeleteExpired(ctx, key, expiresAt); err != nil || !ok {
+				t.Errorf("DeleteExpired() = %v, %v; want true", ok, err)
+			}
+			if ok, err := tt.store.DeleteExpired(ctx, key, expiresAt); err != nil || ok {
+				t.Errorf("DeleteExpired() again = %v, %v; want false", ok, err)
+			}
+		})
+	}
+}
+
+func TestStateSweep(t *testing.T) {
+	file, err := NewFileStateStore(filepath.Join(t.TempDir(), "states.json"))
+	if err != nil {
+		t.Fatalf("NewFileStateStore() error = %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store StateStore
+	}{
+		{"memory", NewMemoryStateStore()},
+		{"file", file},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			expired := StateKey{ChatID: 1, UserID: 1}
+			_ = tt.store.Set(ctx, expired, State{Name: "old", ExpiresAt: now.Add(-time.Second)})
+			_ = tt.store.Set(ctx, StateKey{ChatID: 1, UserID: 2}, State{Name: "live", ExpiresAt: now.Add(time.Hour)})
+			_ = tt.store.Set(ctx, StateKey{ChatID: 1, UserID: 3}, State{Name: "forever"})
+
+			b := &Bot{config: Config{StateStore: tt.store, StateSweepInterval: time.Millisecond, Logger: slog.Default()}}
+			swept := make(chan StateKey, 1)
+			b.OnState(StateDef{
+				Name: "old",
+				OnTimeout: func(_ context.Context, key StateKey, _ State) error {
+					swept <- key
+					return nil
+				},
+			}, func(*Context) error { return nil })
+
+			sweepCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				b.sweepStates(sweepCtx)
+			}()
+			select {
+			case key := <-swept:
+				if key != expired {
+					t.Errorf("OnTimeout key = %+v, want %+v", key, expired)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("sweep did not time out the expired state")
+			}
+			cancel()
+			<-done
+
+			if _, ok, _ := tt.store.Get(ctx, expired); ok {
+				t.Error("expired state is still stored after the sweep")
+			}
+			for _, user := range []int64{2, 3} {
+				if _, ok, _ := tt.store.Get(ctx, StateKey{ChatID: 1, UserID: user}); !ok {
+					t.Errorf("sweep removed the live state of user %d", user)
+				}
+			}
+		})
+	}
+}
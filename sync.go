@@ -29,7 +29,8 @@ func (b *Bot) SyncCommands(ctx context.Context) error {
 	scopes := make(map[string]CommandScope)
 
 	for _, h := range handlers {
-		if h.description == "" {
+		// role-restricted commands go to per-user menus
+		if h.description == "" || len(h.roles) > 0 {
 			continue
 		}
 
@@ -52,13 +53,14 @@ func (b *Bot) SyncCommands(ctx context.Context) error {
 		if !exists {
 			grouped[key] = append(grouped[key], tg.BotCommand{
 				Command:     h.name,
-				Description: h.description,
+				Description: menuDescription(h),
 			})
 		}
 	}
 
 	if len(grouped) == 0 {
 		b.config.Logger.Debug("no commands with descriptions to sync")
+		b.syncRoleMenus(ctx)
 		return nil
 	}
 
@@ -93,14 +95,41 @@ func (b *Bot) SyncCommands(ctx context.Context) error {
 		b.config.Logger.Debug("set commands for scope", "scope", key, "count", len(commands))
 	}
 
+	b.scopesMu.Lock()
 	if err := b.saveCommandScopes(scopeKeys); err != nil {
 		b.config.Logger.Warn("failed to save command scopes", "error", err)
 	}
+	b.scopesMu.Unlock()
+
+	b.syncRoleMenus(ctx)
 
 	b.config.Logger.Info("synced commands to Telegram", "scopes", len(grouped))
 	return nil
 }
 
+// maxCommandDescription is Telegram's limit for command descriptions.
+const maxCommandDescription = 256
+
+// menuDescription returns the menu description of a command. Subcommand
+// names are appended, since the menu itself has no notion of them.
+func menuDescription(h commandHandler) string {
+	desc := h.description
+	if len(h.subcommands) == 0 {
+		return desc
+	}
+
+	names := make([]string, len(h.subcommands))
+	for i, sub := range h.subcommands {
+		names[i] = sub.name
+	}
+	desc += " (" + strings.Join(names, ", ") + ")"
+
+	if runes := []rune(desc); len(runes) > maxCommandDescription {
+		desc = string(runes[:maxCommandDescription-1]) + "…"
+	}
+	return desc
+}
+
 // ResetCommands removes all bot commands from Telegram.
 // It resets commands for all scope+langCode combinations that were previously saved.
 func (b *Bot) ResetCommands(ctx context.Context) error {
@@ -0,0 +1,251 @@
+package telekit
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError describes a syntax error in command text.
+type ParseError struct {
+	// Pos is the 1-based character position of the error in the message text.
+	Pos int
+
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// token is a single shell-style word of the command line.
+type token struct {
+	value       string
+	pos         int  // 1-based position of the first character
+	eq          int  // byte offset of the first unquoted '=' in value, -1 if none
+	startQuoted bool // first character came from quotes or an escape
+	heredoc     bool // value ends with a <<DELIM marker
+	delim       string
+}
+
+// isFlag reports whether the token is an unquoted --name or --name=value word.
+func (t token) isFlag() bool {
+	return !t.startQuoted && len(t.value) > 2 && strings.HasPrefix(t.value, "--")
+}
+
+// isTerminator reports whether the token is a bare unquoted "--".
+func (t token) isTerminator() bool {
+	return !t.startQuoted && t.value == "--"
+}
+
+// tokenize splits command text into shell-style words.
+//
+// Words are separated by spaces and tabs on the first line. Single quotes
+// preserve everything literally, double quotes allow \" \\ \n and \t escapes,
+// and an unquoted backslash escapes the next character (a backslash before a
+// newline continues the line). Quoted words may span lines.
+//
+// Everything after the first unquoted newline is the body. A word of the form
+// <<DELIM or key=<<DELIM takes its value from the body lines up to a line
+// equal to DELIM, in order of appearance; a bare << takes the whole remaining
+// body. Whatever is left of the body is returned separately.
+func tokenize(text string) ([]token, string, error) {
+	runes := []rune(text)
+	var tokens []token
+	i := 0
+
+	for i < len(runes) {
+		r := runes[i]
+		if r == ' ' || r == '\t' || r == '\r' {
+			i++
+			continue
+		}
+		if r == '\n' {
+			i++
+			break
+		}
+
+		tok, next, err := readToken(runes, i)
+		if err != nil {
+			return nil, "", err
+		}
+		tokens = append(tokens, tok)
+		i = next
+	}
+
+	body := ""
+	if i < len(runes) {
+		body = string(runes[i:])
+	}
+
+	for idx := range tokens {
+		tok := &tokens[idx]
+		if !tok.heredoc {
+			continue
+		}
+		content, rest, ok := takeHeredoc(body, tok.delim)
+		if !ok {
+			return nil, "", &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("heredoc is missing terminating line %q", tok.delim)}
+		}
+		tok.value += content
+		body = rest
+	}
+
+	return tokens, body, nil
+}
+
+// splitFields splits the first line of command text at whitespace without
+// interpreting quotes, escapes or heredocs. It is used for commands without
+// declared parameters, whose text may contain unbalanced quotes such as
+// "/say don't". The remaining lines are returned as the body.
+func splitFields(text string) ([]token, string) {
+	line, body, _ := strings.Cut(text, "\n")
+	var tokens []token
+	pos := 0
+	for _, field := range strings.Fields(line) {
+		idx := strings.Index(line[pos:], field) + pos
+		pos = idx + len(field)
+		tokens = append(tokens, token{
+			value: field,
+			pos:   utf8.RuneCountInString(line[:idx]) + 1,
+			eq:    strings.IndexByte(field, '='),
+		})
+	}
+	return tokens, body
+}
+
+// readToken reads one word starting at runes[start].
+// It returns the token and the index right after it.
+func readToken(runes []rune, start int) (token, int, error) {
+	tok := token{pos: start + 1, eq: -1}
+	var b strings.Builder
+	heredocAt := -1
+	i := start
+
+	markQuoted := func() {
+		if b.Len() == 0 {
+			tok.startQuoted = true
+		}
+	}
+
+loop:
+	for i < len(runes) {
+		r := runes[i]
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			break loop
+
+		case '\\':
+			if i+1 >= len(runes) {
+				return tok, 0, &ParseError{Pos: i + 1, Msg: "trailing backslash"}
+			}
+			if runes[i+1] == '\n' {
+				i += 2
+				continue
+			}
+			markQuoted()
+			b.WriteRune(runes[i+1])
+			i += 2
+
+		case '\'':
+			markQuoted()
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return tok, 0, &ParseError{Pos: i + 1, Msg: "unterminated single quote"}
+			}
+			b.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+
+		case '"':
+			markQuoted()
+			open := i
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(runes) {
+					switch runes[i+1] {
+					case '"', '\\':
+						b.WriteRune(runes[i+1])
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case '\n':
+						// line continuation inside quotes
+					default:
+						b.WriteRune(c)
+						b.WriteRune(runes[i+1])
+					}
+					i += 2
+					continue
+				}
+				b.WriteRune(c)
+				i++
+			}
+			if !closed {
+				return tok, 0, &ParseError{Pos: open + 1, Msg: "unterminated double quote"}
+			}
+
+		case '=':
+			if tok.eq < 0 && heredocAt < 0 {
+				tok.eq = b.Len()
+			}
+			b.WriteRune(r)
+			i++
+
+		case '<':
+			if heredocAt < 0 && i+1 < len(runes) && runes[i+1] == '<' &&
+				(b.Len() == 0 || (tok.eq >= 0 && b.Len() == tok.eq+1)) {
+				heredocAt = b.Len()
+				i += 2
+				continue
+			}
+			b.WriteRune(r)
+			i++
+
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+
+	tok.value = b.String()
+	if heredocAt >= 0 {
+		tok.heredoc = true
+		tok.delim = tok.value[heredocAt:]
+		tok.value = tok.value[:heredocAt]
+	}
+	return tok, i, nil
+}
+
+// takeHeredoc extracts lines from body up to a line equal to delim.
+// An empty delim takes the whole body.
+func takeHeredoc(body, delim string) (content, rest string, ok bool) {
+	if delim == "" {
+		return body, "", true
+	}
+
+	remaining := body
+	var lines []string
+	for {
+		line, after, found := strings.Cut(remaining, "\n")
+		if strings.TrimSpace(line) == delim {
+			return strings.Join(lines, "\n"), after, true
+		}
+		if !found {
+			return "", body, false
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+		remaining = after
+	}
+}
@@ -0,0 +1,178 @@
+package telekit
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		want     []string
+		wantBody string
+	}{
+		{
+			name: "plain words",
+			text: "/cmd a b  c",
+			want: []string{"/cmd", "a", "b", "c"},
+		},
+		{
+			name: "double quoted value",
+			text: `/note title="hello world"`,
+			want: []string{"/note", "title=hello world"},
+		},
+		{
+			name: "single quotes are literal",
+			text: `/cmd 'a \"b\" $c'`,
+			want: []string{"/cmd", `a \"b\" $c`},
+		},
+		{
+			name: "double quote escapes",
+			text: `/cmd "say \"hi\"\n\\"`,
+			want: []string{"/cmd", "say \"hi\"\n\\"},
+		},
+		{
+			name: "backslash escapes space",
+			text: `/cmd hello\ world`,
+			want: []string{"/cmd", "hello world"},
+		},
+		{
+			name: "quoted value spans lines",
+			text: "/cmd text=\"line one\nline two\" next",
+			want: []string{"/cmd", "text=line one\nline two", "next"},
+		},
+		{
+			name:     "body after first line",
+			text:     "/cmd a\nfirst\nsecond",
+			want:     []string{"/cmd", "a"},
+			wantBody: "first\nsecond",
+		},
+		{
+			name: "line continuation",
+			text: "/cmd a \\\nb",
+			want: []string{"/cmd", "a", "b"},
+		},
+		{
+			name:     "heredoc with delimiter",
+			text:     "/note text=<<EOF tail\nline 1\nline 2\nEOF\nrest",
+			want:     []string{"/note", "text=line 1\nline 2", "tail"},
+			wantBody: "rest",
+		},
+		{
+			name: "bare heredoc takes whole body",
+			text: "/note --text <<\nline 1\nline 2",
+			want: []string{"/note", "--text", "line 1\nline 2"},
+		},
+		{
+			name:     "multiple heredocs in order",
+			text:     "/cmd a=<<A b=<<B\none\nA\ntwo\nB",
+			want:     []string{"/cmd", "a=one", "b=two"},
+			wantBody: "",
+		},
+		{
+			name: "quoted heredoc marker is literal",
+			text: `/cmd "<<EOF"`,
+			want: []string{"/cmd", "<<EOF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, body, err := tokenize(tt.text)
+			if err != nil {
+				t.Fatalf("tokenize() error = %v", err)
+			}
+			var got []string
+			for _, tok := range tokens {
+				got = append(got, tok.value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tokenize() tokens = %q, want %q", got, tt.want)
+			}
+			if body != tt.wantBody {
+				t.Errorf("tokenize() body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantPos int
+	}{
+		{"unterminated double quote", `/note title="hello`, 13},
+		{"unterminated single quote", `/cmd a 'b`, 8},
+		{"trailing backslash", `/cmd a\`, 7},
+		{"missing heredoc terminator", "/cmd x=<<END\nline", 6},
+		{"position counts characters not bytes", `/cmd ñ "x`, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tokenize(tt.text)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("tokenize() error = %v, want *ParseError", err)
+			}
+			if perr.Pos != tt.wantPos {
+				t.Errorf("ParseError.Pos = %d, want %d", perr.Pos, tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	schema := Params{
+		"title":   {Type: TypeString},
+		"limit":   {Type: TypeInt},
+		"verbose": {Type: TypeBool},
+	}
+
+	cmd, err := parseCommand(`/note@mybot title="hello world" --limit 5 --verbose first -- --second`, schema)
+	if err != nil {
+		t.Fatalf("parseCommand() error = %v", err)
+	}
+	if cmd.name != "note" || cmd.mention != "mybot" {
+		t.Errorf("name = %q, mention = %q", cmd.name, cmd.mention)
+	}
+	if got := cmd.params.String("title"); got != "hello world" {
+		t.Errorf("title = %q, want %q", got, "hello world")
+	}
+	if got := cmd.params.Int("limit"); got != 5 {
+		t.Errorf("limit = %d, want 5", got)
+	}
+	if !cmd.params.Bool("verbose") {
+		t.Error("verbose = false, want true")
+	}
+	if want := []string{"first", "--second"}; !slices.Equal(cmd.args, want) {
+		t.Errorf("args = %q, want %q", cmd.args, want)
+	}
+}
+
+func TestParseCommandFlagForms(t *testing.T) {
+	cmd, err := parseCommand(`/cmd --a=1 --b two --c --d key=value`, nil)
+	if err != nil {
+		t.Fatalf("parseCommand() error = %v", err)
+	}
+	want := map[string]string{"a": "1", "b": "two", "c": "true", "d": "true", "key": "value"}
+	for k, v := range want {
+		if got := cmd.params.String(k); got != v {
+			t.Errorf("param %q = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestParseCommandMissingName(t *testing.T) {
+	_, err := parseCommand(`/cmd ok=1 =bad`, nil)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("parseCommand() error = %v, want *ParseError", err)
+	}
+	if perr.Pos != 11 {
+		t.Errorf("ParseError.Pos = %d, want 11", perr.Pos)
+	}
+}
@@ -0,0 +1,447 @@
+package telekit
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationKind classifies a parameter validation failure.
+type ValidationKind string
+
+const (
+	ValidationRequired   ValidationKind = "required"
+	ValidationUnknown    ValidationKind = "unknown"
+	ValidationType       ValidationKind = "type"
+	ValidationEnum       ValidationKind = "enum"
+	ValidationMin        ValidationKind = "min"
+	ValidationMax        ValidationKind = "max"
+	ValidationMinLen     ValidationKind = "min_len"
+	ValidationMaxLen     ValidationKind = "max_len"
+	ValidationPattern    ValidationKind = "pattern"
+	ValidationNotFound   ValidationKind = "not_found"  // user or chat could not be resolved
+	ValidationSubcommand ValidationKind = "subcommand" // missing or unknown subcommand (Value holds the name)
+	ValidationCustom     ValidationKind = "custom"     // ParamSchema.Validate failed
+	ValidationCommand    ValidationKind = "command"    // a CommandValidator failed
+)
+
+// ParamError describes a single invalid parameter or parameter combination.
+type ParamError struct {
+	// Kind is the kind of failure.
+	Kind ValidationKind
+
+	// Param is the parameter name. Empty for command-level errors.
+	Param string
+
+	// Params lists the parameters involved in a command-level error.
+	Params []string
+
+	// Value is the raw value as given by the user, if any.
+	Value string
+
+	// Limit is the violated constraint: the bound for min/max, the length
+	// for min_len/max_len, the pattern, the enum values ([]string) or the
+	// expected ParamType for type errors.
+	Limit any
+
+	// Err is the underlying error from a custom validator.
+	Err error
+
+	// Msg is a human-readable English description.
+	Msg string
+}
+
+func (e *ParamError) Error() string {
+	return e.Msg
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors holds all parameter errors of a command invocation.
+type ValidationErrors []*ParamError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// CommandValidator checks relations between parsed parameters.
+// It may return a *ParamError, ValidationErrors or any other error,
+// which is reported as a ValidationCommand error.
+type CommandValidator func(params ParsedParams) error
+
+// ExactlyOneOf requires exactly one of the named parameters to be provided.
+func ExactlyOneOf(names ...string) CommandValidator {
+	return func(p ParsedParams) error {
+		if countProvided(p, names) == 1 {
+			return nil
+		}
+		return commandError(names, "exactly one of %s must be provided", quoteNames(names))
+	}
+}
+
+// AtLeastOneOf requires at least one of the named parameters to be provided.
+func AtLeastOneOf(names ...string) CommandValidator {
+	return func(p ParsedParams) error {
+		if countProvided(p, names) >= 1 {
+			return nil
+		}
+		return commandError(names, "at least one of %s must be provided", quoteNames(names))
+	}
+}
+
+// MutuallyExclusive allows at most one of the named parameters.
+func MutuallyExclusive(names ...string) CommandValidator {
+	return func(p ParsedParams) error {
+		if countProvided(p, names) <= 1 {
+			return nil
+		}
+		return commandError(names, "only one of %s may be provided", quoteNames(names))
+	}
+}
+
+// RequiredWith requires param whenever any of others is provided.
+func RequiredWith(param string, others ...string) CommandValidator {
+	return func(p ParsedParams) error {
+		if p.Has(param) || countProvided(p, others) == 0 {
+			return nil
+		}
+		return commandError(append([]string{param}, others...),
+			"parameter %q is required with %s", param, quoteNames(others))
+	}
+}
+
+// LessThan requires parameter a to be strictly less than parameter b when both are set.
+// It works for numbers, durations and times.
+func LessThan(a, b string) CommandValidator {
+	return func(p ParsedParams) error {
+		va, okA := p[a]
+		vb, okB := p[b]
+		if !okA || !okB {
+			return nil
+		}
+		if c, ok := compareValues(va, vb); ok && c < 0 {
+			return nil
+		}
+		return commandError([]string{a, b}, "parameter %q must be less than %q", a, b)
+	}
+}
+
+func countProvided(p ParsedParams, names []string) int {
+	n := 0
+	for _, name := range names {
+		if p.Has(name) {
+			n++
+		}
+	}
+	return n
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func commandError(params []string, format string, args ...any) *ParamError {
+	return &ParamError{
+		Kind:   ValidationCommand,
+		Params: params,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// validateParams checks constraints of each parameter and then runs the
+// command-level validators. Parameters taken from Default are not checked.
+func validateParams(params ParsedParams, raw map[string]string, schema Params, validators []CommandValidator) error {
+	var errs ValidationErrors
+
+	for _, name := range sortedKeys(schema) {
+		s := schema[name]
+		value, ok := params[name]
+		if _, provided := raw[name]; !ok || !provided {
+			continue
+		}
+		if err := checkConstraints(name, raw[name], s, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, validate := range validators {
+		err := validate(params)
+		if err == nil {
+			continue
+		}
+		var verrs ValidationErrors
+		var perr *ParamError
+		switch {
+		case errors.As(err, &verrs):
+			errs = append(errs, verrs...)
+		case errors.As(err, &perr):
+			errs = append(errs, perr)
+		default:
+			errs = append(errs, &ParamError{Kind: ValidationCommand, Err: err, Msg: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkConstraints(name, raw string, s ParamSchema, value any) *ParamError {
+	newErr := func(kind ValidationKind, limit any, format string, args ...any) *ParamError {
+		return &ParamError{
+			Kind:  kind,
+			Param: name,
+			Value: raw,
+			Limit: limit,
+			Msg:   fmt.Sprintf("parameter %q ", name) + fmt.Sprintf(format, args...),
+		}
+	}
+
+	if n, unit, ok := valueLen(value); ok {
+		if s.MinLen > 0 && n < s.MinLen {
+			return newErr(ValidationMinLen, s.MinLen, "must have at least %d %s", s.MinLen, unit)
+		}
+		if s.MaxLen > 0 && n > s.MaxLen {
+			return newErr(ValidationMaxLen, s.MaxLen, "must have at most %d %s", s.MaxLen, unit)
+		}
+	}
+
+	for _, item := range listItems(value) {
+		if s.Min != nil {
+			if c, ok := compareValues(item, s.Min); ok && c < 0 {
+				return newErr(ValidationMin, s.Min, "must be at least %v", s.Min)
+			}
+		}
+		if s.Max != nil {
+			if c, ok := compareValues(item, s.Max); ok && c > 0 {
+				return newErr(ValidationMax, s.Max, "must be at most %v", s.Max)
+			}
+		}
+		if str, ok := item.(string); ok && s.Pattern != "" {
+			re, err := compilePattern(s.Pattern)
+			if err != nil {
+				return newErr(ValidationPattern, s.Pattern, "has an invalid pattern: %v", err)
+			}
+			if !re.MatchString(str) {
+				return newErr(ValidationPattern, s.Pattern, "must match %s", s.Pattern)
+			}
+		}
+	}
+
+	if s.Validate != nil {
+		if err := s.Validate(value); err != nil {
+			perr := newErr(ValidationCustom, nil, "%v", err)
+			perr.Err = err
+			return perr
+		}
+	}
+
+	return nil
+}
+
+// valueLen returns the length of strings (in characters) and lists (in items).
+func valueLen(v any) (int, string, bool) {
+	switch x := v.(type) {
+	case string:
+		return utf8.RuneCountInString(x), "characters", true
+	case []string:
+		return len(x), "items", true
+	case []int64:
+		return len(x), "items", true
+	case []float64:
+		return len(x), "items", true
+	case []bool:
+		return len(x), "items", true
+	case []time.Duration:
+		return len(x), "items", true
+	case []time.Time:
+		return len(x), "items", true
+	case []Peer:
+		return len(x), "items", true
+	}
+	return 0, "", false
+}
+
+// listItems returns the elements of list values, or the value itself.
+func listItems(v any) []any {
+	switch x := v.(type) {
+	case []string:
+		return toAny(x)
+	case []int64:
+		return toAny(x)
+	case []float64:
+		return toAny(x)
+	case []time.Duration:
+		return toAny(x)
+	case []time.Time:
+		return toAny(x)
+	}
+	return []any{v}
+}
+
+func toAny[T any](values []T) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// compareValues compares numbers, durations and times.
+// Numeric values of different Go types are compared as float64.
+func compareValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			return cmp.Compare(x, y), true
+		}
+		return 0, false
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+		return 0, false
+	}
+
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return cmp.Compare(fa, fb), true
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// checkSchema rejects parameter constraints that could never apply: invalid
+// patterns, patterns on parameters that are not strings and Min or Max
+// values of another type than the parameter.
+func checkSchema(params Params) error {
+	for _, name := range sortedKeys(params) {
+		s := params[name]
+		typ := s.Type
+		if typ == TypeList {
+			typ = s.Elem
+		}
+
+		if s.Pattern != "" {
+			if !isStringType(typ) {
+				return fmt.Errorf("%w: parameter %q: Pattern requires a string type, got %s",
+					ErrInvalidParamSchema, name, s.Type)
+			}
+			if _, err := compilePattern(s.Pattern); err != nil {
+				return fmt.Errorf("%w: parameter %q: invalid Pattern: %v", ErrInvalidParamSchema, name, err)
+			}
+		}
+		if s.Min != nil && !limitMatches(typ, s.Min) {
+			return fmt.Errorf("%w: parameter %q: Min %v (%T) does not match type %s",
+				ErrInvalidParamSchema, name, s.Min, s.Min, s.Type)
+		}
+		if s.Max != nil && !limitMatches(typ, s.Max) {
+			return fmt.Errorf("%w: parameter %q: Max %v (%T) does not match type %s",
+				ErrInvalidParamSchema, name, s.Max, s.Max, s.Type)
+		}
+	}
+	return nil
+}
+
+// checkCommandSchema runs checkSchema on h and its subcommands.
+func checkCommandSchema(h commandHandler) error {
+	if err := checkSchema(h.params); err != nil {
+		return fmt.Errorf("command %q: %w", h.path, err)
+	}
+	for _, sub := range h.subcommands {
+		if err := checkCommandSchema(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStringType reports whether values of typ are converted to strings.
+func isStringType(typ ParamType) bool {
+	switch typ {
+	case TypeInt, TypeBool, TypeFloat, TypeDuration, TypeTime, TypeURL, TypeUser, TypeChat, TypeList:
+		return false
+	}
+	return true
+}
+
+// limitMatches reports whether a Min or Max value can be compared with
+// values of typ.
+func limitMatches(typ ParamType, limit any) bool {
+	switch typ {
+	case TypeInt, TypeFloat:
+		_, ok := toFloat(limit)
+		return ok
+	case TypeDuration:
+		_, ok := limit.(time.Duration)
+		return ok
+	case TypeTime:
+		_, ok := limit.(time.Time)
+		return ok
+	}
+	return false
+}
+
+var patternCache sync.Map // string -> *regexp.Regexp
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
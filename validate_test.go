@@ -0,0 +1,180 @@
+package telekit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateParamsConstraints(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   ParamSchema
+		value    string
+		wantKind ValidationKind
+	}{
+		{"min int", ParamSchema{Type: TypeInt, Min: 1}, "0", ValidationMin},
+		{"max float", ParamSchema{Type: TypeFloat, Max: 1.0}, "1.5", ValidationMax},
+		{"max duration", ParamSchema{Type: TypeDuration, Max: time.Hour}, "2h", ValidationMax},
+		{"min time", ParamSchema{Type: TypeTime, Min: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, "2023-12-31", ValidationMin},
+		{"min len", ParamSchema{Type: TypeString, MinLen: 3}, "ab", ValidationMinLen},
+		{"max len counts characters", ParamSchema{Type: TypeString, MaxLen: 2}, "äöü", ValidationMaxLen},
+		{"list max len", ParamSchema{Type: TypeList, MaxLen: 2}, "a,b,c", ValidationMaxLen},
+		{"list item max", ParamSchema{Type: TypeList, Elem: TypeInt, Max: 10}, "1,20", ValidationMax},
+		{"pattern", ParamSchema{Type: TypeString, Pattern: `^[a-z]+$`}, "abc1", ValidationPattern},
+		{"custom", ParamSchema{Type: TypeInt, Validate: func(v any) error {
+			if v.(int64)%2 != 0 {
+				return errors.New("must be even")
+			}
+			return nil
+		}}, "3", ValidationCustom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]string{"p": tt.value}
+			schema := Params{"p": tt.schema}
+			params, err := parseParams(raw, schema)
+			if err != nil {
+				t.Fatalf("parseParams() error = %v", err)
+			}
+
+			err = validateParams(params, raw, schema, nil)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("validateParams() error = %v, want one ParamError", err)
+			}
+			if errs[0].Kind != tt.wantKind || errs[0].Param != "p" || errs[0].Value != tt.value {
+				t.Errorf("ParamError = %+v, want kind %q", errs[0], tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestValidateParamsAccepts(t *testing.T) {
+	schema := Params{
+		"limit": {Type: TypeInt, Min: 1, Max: 100},
+		"name":  {Type: TypeString, MinLen: 1, MaxLen: 10, Pattern: `^\w+$`},
+		"page":  {Type: TypeInt, Min: 1, Default: int64(0)},
+	}
+	raw := map[string]string{"limit": "100", "name": "abc"}
+	params, err := parseParams(raw, schema)
+	if err != nil {
+		t.Fatalf("parseParams() error = %v", err)
+	}
+	if err := validateParams(params, raw, schema, nil); err != nil {
+		t.Errorf("validateParams() error = %v", err)
+	}
+}
+
+func TestParseParamsStructuredErrors(t *testing.T) {
+	schema := Params{
+		"a": {Type: TypeInt, Required: true},
+		"b": {Type: TypeInt},
+		"c": {Type: TypeEnum, Enum: []string{"x", "y"}},
+	}
+	_, err := parseParams(map[string]string{"b": "nope", "c": "z", "d": "1"}, schema)
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("parseParams() error = %v, want ValidationErrors", err)
+	}
+	want := []struct {
+		param string
+		kind  ValidationKind
+	}{
+		{"a", ValidationRequired},
+		{"b", ValidationType},
+		{"c", ValidationEnum},
+		{"d", ValidationUnknown},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), err)
+	}
+	for i, w := range want {
+		if errs[i].Param != w.param || errs[i].Kind != w.kind {
+			t.Errorf("errs[%d] = %s/%s, want %s/%s", i, errs[i].Param, errs[i].Kind, w.param, w.kind)
+		}
+	}
+}
+
+func TestCommandValidators(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator CommandValidator
+		params    ParsedParams
+		wantErr   bool
+	}{
+		{"exactly one ok", ExactlyOneOf("a", "b"), ParsedParams{"a": "1"}, false},
+		{"exactly one none", ExactlyOneOf("a", "b"), ParsedParams{}, true},
+		{"exactly one both", ExactlyOneOf("a", "b"), ParsedParams{"a": "1", "b": "2"}, true},
+		{"at least one", AtLeastOneOf("a", "b"), ParsedParams{}, true},
+		{"mutually exclusive", MutuallyExclusive("a", "b"), ParsedParams{"a": "1", "b": "2"}, true},
+		{"required with", RequiredWith("a", "b"), ParsedParams{"b": "2"}, true},
+		{"less than ok", LessThan("from", "to"), ParsedParams{"from": int64(1), "to": int64(2)}, false},
+		{"less than equal", LessThan("from", "to"), ParsedParams{"from": int64(2), "to": int64(2)}, true},
+		{"less than times", LessThan("from", "to"), ParsedParams{
+			"from": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			"to":   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, true},
+		{"less than missing", LessThan("from", "to"), ParsedParams{"from": int64(5)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateParams(tt.params, nil, nil, []CommandValidator{tt.validator})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var errs ValidationErrors
+			if err != nil && (!errors.As(err, &errs) || errs[0].Kind != ValidationCommand) {
+				t.Errorf("error = %#v, want ValidationCommand", err)
+			}
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema ParamSchema
+		ok     bool
+	}{
+		{"int limits", ParamSchema{Type: TypeInt, Min: 1, Max: int64(10)}, true},
+		{"float limit", ParamSchema{Type: TypeFloat, Max: 0.5}, true},
+		{"duration limit", ParamSchema{Type: TypeDuration, Min: time.Second}, true},
+		{"time limit", ParamSchema{Type: TypeTime, Max: time.Now()}, true},
+		{"list item limit", ParamSchema{Type: TypeList, Elem: TypeInt, Max: 10}, true},
+		{"string pattern", ParamSchema{Type: TypeString, Pattern: `^\w+$`}, true},
+		{"string list pattern", ParamSchema{Type: TypeList, Pattern: `^\w+$`}, true},
+		{"invalid pattern", ParamSchema{Type: TypeString, Pattern: `(`}, false},
+		{"pattern on int", ParamSchema{Type: TypeInt, Pattern: `^\d+$`}, false},
+		{"int limit on duration", ParamSchema{Type: TypeDuration, Min: 5}, false},
+		{"duration limit on int", ParamSchema{Type: TypeInt, Max: time.Minute}, false},
+		{"limit on string", ParamSchema{Type: TypeString, Min: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSchema(Params{"p": tt.schema})
+			if tt.ok && err != nil {
+				t.Errorf("checkSchema() error = %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidParamSchema) {
+				t.Errorf("checkSchema() error = %v, want %v", err, ErrInvalidParamSchema)
+			}
+		})
+	}
+}
+
+func TestCommandWithFilterRejectsInvalidSchema(t *testing.T) {
+	b := &Bot{}
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrInvalidParamSchema) {
+			t.Errorf("CommandWithFilter() panic = %v, want %v", err, ErrInvalidParamSchema)
+		}
+	}()
+	b.CommandWithFilter(CommandDef{Name: "cfg", Subcommands: []SubcommandDef{
+		{Name: "set", Params: Params{"key": {Type: TypeString, Pattern: "["}}, Handler: func(*Context) error { return nil }},
+	}}, Filter{}, func(*Context) error { return nil })
+}